@@ -0,0 +1,31 @@
+package loglang
+
+import (
+	"errors"
+	"net"
+	"net/http"
+)
+
+// the admin server is for operators (and Prometheus, and orchestrators) rather than for log events
+
+func (p *Pipeline) startAdmin(address string) (*http.Server, error) {
+	// listen right away, so a port conflict stops the pipeline from starting
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	log := ContextLogger(p.ctx)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", p.metrics)
+	mux.Handle("/health", p.healthHandler(func(r HealthReport) bool { return r.Healthy }))
+	mux.Handle("/ready", p.healthHandler(func(r HealthReport) bool { return r.Ready }))
+	server := &http.Server{Handler: mux}
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("admin server failed", "error", err)
+		}
+	}()
+	log.Info("admin server listening", "address", listener.Addr().String())
+	return server, nil
+}
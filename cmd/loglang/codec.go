@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/nicwaller/loglang"
+)
+
+func catCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("cat", flag.ContinueOnError)
+	framingNames := flags.String("framing", "lines", "framing stages, outermost first (eg. gzip,lines)")
+	codecName := flags.String("codec", "auto", "codec for decoding each frame")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	framings, err := buildFramings(*framingNames)
+	if err != nil {
+		return err
+	}
+	codec, err := loglang.BuildCodec(loglang.PluginSpec{Type: *codecName})
+	if err != nil {
+		return fmt.Errorf("codec: %w", err)
+	}
+
+	extractor := loglang.BaseInputPlugin{Framing: framings, Codec: codec}
+	template := loglang.NewEvent()
+	events := make(chan *loglang.Event)
+	extractErr := make(chan error, 1)
+	go func() {
+		extractErr <- extractor.Extract(context.Background(), &template, stdin, events)
+	}()
+
+	out := bufio.NewWriter(stdout)
+	printer := json.NewEncoder(out)
+	for evt := range events {
+		if err := printer.Encode(evt.Fields); err != nil {
+			return err
+		}
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	return <-extractErr
+}
+
+func encodeCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("encode", flag.ContinueOnError)
+	framingNames := flags.String("framing", "lines", "framing stages, outermost first (eg. gzip,lines)")
+	codecName := flags.String("codec", "json", "codec for encoding each event")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	framings, err := buildFramings(*framingNames)
+	if err != nil {
+		return err
+	}
+	codec, err := loglang.BuildCodec(loglang.PluginSpec{Type: *codecName})
+	if err != nil {
+		return fmt.Errorf("codec: %w", err)
+	}
+
+	ctx, stop := context.WithCancelCause(context.Background())
+	defer stop(nil)
+
+	// frameup happens in the opposite order of extraction
+	encoded := make(chan []byte)
+	var stages sync.WaitGroup
+	var input <-chan []byte = encoded
+	for i := len(framings) - 1; i >= 0; i-- {
+		framed := make(chan []byte)
+		stages.Add(1)
+		go func(stage loglang.FramingPlugin, input <-chan []byte, output chan<- []byte) {
+			defer stages.Done()
+			if err := stage.Frameup(ctx, input, output); err != nil {
+				stop(err)
+			}
+		}(framings[i], input, framed)
+		input = framed
+	}
+
+	writeErr := make(chan error, 1)
+	go func() {
+		out := bufio.NewWriter(stdout)
+		for chunk := range input {
+			if _, err := out.Write(chunk); err != nil {
+				stop(err)
+			}
+		}
+		writeErr <- out.Flush()
+	}()
+
+	scanner := bufio.NewScanner(stdin)
+	scanner.Buffer(make([]byte, loglang.MaxFrameSize), loglang.MaxFrameSize)
+	lineNumber := 0
+	for scanner.Scan() && ctx.Err() == nil {
+		lineNumber++
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		evt := loglang.NewEvent()
+		if err := json.Unmarshal(scanner.Bytes(), &evt.Fields); err != nil {
+			stop(fmt.Errorf("line %d: %w", lineNumber, err))
+			break
+		}
+		dat, err := codec.Encode(evt)
+		if err != nil {
+			stop(fmt.Errorf("line %d: %w", lineNumber, err))
+			break
+		}
+		select {
+		case encoded <- dat:
+		case <-ctx.Done():
+		}
+	}
+	close(encoded)
+	if err := scanner.Err(); err != nil {
+		stop(err)
+	}
+
+	stages.Wait()
+	if err := <-writeErr; err != nil {
+		return err
+	}
+	return context.Cause(ctx)
+}
+
+func buildFramings(names string) ([]loglang.FramingPlugin, error) {
+	var framings []loglang.FramingPlugin
+	for _, name := range strings.Split(names, ",") {
+		f, err := loglang.BuildFraming(loglang.PluginSpec{Type: strings.TrimSpace(name)})
+		if err != nil {
+			return nil, fmt.Errorf("framing: %w", err)
+		}
+		framings = append(framings, f)
+	}
+	return framings, nil
+}
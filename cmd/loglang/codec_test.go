@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestCatCommand(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		args     []string
+		stdin    string
+		expected string
+		err      string
+	}{
+		{name: "json", args: []string{"-codec", "json"}, stdin: "{\"a\":1}\n{\"a\":2}\n", expected: "{\"a\":1}\n{\"a\":2}\n"},
+		{name: "plain", args: []string{"-codec", "plain"}, stdin: "hello\n", expected: "{\"message\":\"hello\"}\n"},
+		{name: "empty", args: []string{"-codec", "json"}, stdin: "", expected: ""},
+		{name: "unknown framing", args: []string{"-framing", "nope"}, err: "framing: "},
+		{name: "unknown codec", args: []string{"-codec", "nope"}, err: "codec: "},
+		{name: "unknown flag", args: []string{"-bogus"}, err: "flag provided but not defined"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			err := catCommand(tc.args, strings.NewReader(tc.stdin), &stdout)
+			checkCommandError(t, err, tc.err)
+			if actual := stdout.String(); actual != tc.expected {
+				t.Errorf("expected %q but got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestEncodeCommand(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		args     []string
+		stdin    string
+		expected string
+		err      string
+	}{
+		{name: "json", stdin: "{\"a\":1}\n\n{\"b\":\"x\"}\n", expected: "{\"a\":1}\n{\"b\":\"x\"}\n"},
+		{name: "plain", args: []string{"-codec", "plain"}, stdin: "{\"message\":\"hello\"}\n", expected: "hello\n"},
+		{name: "bad json", stdin: "{\"a\":1}\nnope\n", expected: "{\"a\":1}\n", err: "line 2: "},
+		{name: "unknown framing", args: []string{"-framing", "lines,nope"}, err: "framing: "},
+		{name: "unknown codec", args: []string{"-codec", "nope"}, err: "codec: "},
+		{name: "unknown flag", args: []string{"-bogus"}, err: "flag provided but not defined"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			err := encodeCommand(tc.args, strings.NewReader(tc.stdin), &stdout)
+			checkCommandError(t, err, tc.err)
+			if actual := stdout.String(); actual != tc.expected {
+				t.Errorf("expected %q but got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestEncodeThenCat(t *testing.T) {
+	lines := "{\"message\":\"one\"}\n{\"message\":\"two\"}\n"
+	var compressed bytes.Buffer
+	if err := encodeCommand([]string{"-framing", "gzip,lines"}, strings.NewReader(lines), &compressed); err != nil {
+		t.Fatal(err)
+	}
+	var decoded bytes.Buffer
+	if err := catCommand([]string{"-framing", "gzip,lines", "-codec", "json"}, &compressed, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.String() != lines {
+		t.Errorf("expected %q but got %q", lines, decoded.String())
+	}
+}
+
+func TestHelpIsNotAnError(t *testing.T) {
+	var stdout bytes.Buffer
+	if err := catCommand([]string{"-h"}, strings.NewReader(""), &stdout); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("expected help to be requested but got %v", err)
+	}
+}
+
+func checkCommandError(t *testing.T, err error, expected string) {
+	t.Helper()
+	switch {
+	case expected == "" && err != nil:
+		t.Errorf("unexpected error: %v", err)
+	case expected != "" && err == nil:
+		t.Errorf("expected an error containing %q", expected)
+	case expected != "" && !strings.Contains(err.Error(), expected):
+		t.Errorf("expected an error containing %q but got %v", expected, err)
+	}
+}
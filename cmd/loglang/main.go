@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/lmittmann/tint"
+
+	// plugins register themselves by name, for use in config files
+	_ "github.com/nicwaller/loglang/codec"
+	_ "github.com/nicwaller/loglang/filter"
+	_ "github.com/nicwaller/loglang/framing"
+	_ "github.com/nicwaller/loglang/input"
+	_ "github.com/nicwaller/loglang/output"
+)
+
+const usage = `usage: loglang [-v] <command> [arguments]
+
+commands:
+  run <config>...       run the pipelines defined in YAML or JSON files
+  validate <config>...  check pipeline definitions without running them
+  cat                   decode stdin and print each event as a line of JSON
+  encode                read lines of JSON from stdin and encode them
+  plugins               list registered plugins and their options
+`
+
+func main() {
+	flag.Usage = func() {
+		_, _ = fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	verbose := flag.Bool("v", false, "debug logging")
+	flag.Parse()
+	setupLogging(*verbose)
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var err error
+	command, args := flag.Arg(0), flag.Args()[1:]
+	switch command {
+	case "run":
+		err = runCommand(args)
+	case "validate":
+		err = validateCommand(args, os.Stdout, os.Stderr)
+	case "cat":
+		err = catCommand(args, os.Stdin, os.Stdout)
+	case "encode":
+		err = encodeCommand(args, os.Stdin, os.Stdout)
+	case "plugins":
+		err = pluginsCommand(args, os.Stdout)
+	default:
+		_, _ = fmt.Fprintf(os.Stderr, "unknown command %q\n", command)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		// the command already printed its usage
+		os.Exit(0)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func setupLogging(verbose bool) {
+	// logs go to stderr so they don't get mixed up with events on stdout
+	level := slog.LevelWarn
+	if verbose {
+		level = slog.LevelDebug
+	}
+	slog.SetDefault(slog.New(
+		tint.NewHandler(os.Stderr, &tint.Options{
+			Level:      level,
+			TimeFormat: time.Kitchen,
+		}),
+	))
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nicwaller/loglang"
+)
+
+func pluginsCommand(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("plugins", flag.ContinueOnError)
+	kind := flags.String("kind", "", "only list one kind of plugin (input, filter, output, codec, framing)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	for _, plugin := range loglang.RegisteredPlugins() {
+		if *kind != "" && plugin.Kind != *kind {
+			continue
+		}
+		options := make([]string, 0, len(plugin.Options))
+		for _, opt := range plugin.Options {
+			options = append(options, fmt.Sprintf("%s (%s)", opt.Key, opt.Type))
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", plugin.Kind, plugin.Name, strings.Join(options, ", "))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/nicwaller/loglang"
+)
+
+func runCommand(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("usage: loglang run <config>...")
+	}
+	// several pipelines can run together, linked by pipeline inputs and outputs
+	paths := flags.Args()
+
+	r, err := loglang.LoadRuntime(paths...)
+	if err != nil {
+		return err
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for r != nil {
+		finished := make(chan error, 1)
+		go func(r *loglang.Runtime) {
+			finished <- r.Run()
+		}(r)
+		r, err = supervise(r, paths, signals, finished)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// supervise waits for the pipelines to finish, or for a signal.
+// It returns the next runtime to run after a reload, or nil when it's time to exit.
+func supervise(r *loglang.Runtime, paths []string, signals <-chan os.Signal, finished <-chan error) (*loglang.Runtime, error) {
+	for {
+		select {
+		case err := <-finished:
+			return nil, err
+		case sig := <-signals:
+			if sig != syscall.SIGHUP {
+				slog.Warn("stopping", "signal", sig)
+				r.Stop(sig.String())
+				return nil, <-finished
+			}
+
+			slog.Warn("reloading", "signal", sig, "paths", paths)
+			next, err := loglang.LoadRuntime(paths...)
+			if err != nil {
+				// a bad config shouldn't take down pipelines that are working
+				slog.Error("reload failed; keeping the current pipelines", "error", err)
+				continue
+			}
+			r.Stop("reload")
+			if err := <-finished; err != nil {
+				return nil, err
+			}
+			return next, nil
+		}
+	}
+}
+
+func validateCommand(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("usage: loglang validate <config>...")
+	}
+	failed := false
+	for _, path := range flags.Args() {
+		if _, err := loglang.LoadPipeline(path); err != nil {
+			_, _ = fmt.Fprintln(stderr, err)
+			failed = true
+			continue
+		}
+		_, _ = fmt.Fprintf(stdout, "%s: ok\n", path)
+	}
+	if failed {
+		return fmt.Errorf("invalid pipeline config")
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nicwaller/loglang"
+)
+
+const validConfig = `
+inputs:
+  - type: pipeline
+    options: {address: reload-test}
+outputs:
+  - type: stdout
+`
+
+func writeConfig(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestValidateCommand(t *testing.T) {
+	valid := writeConfig(t, "valid.yaml", validConfig)
+	invalid := writeConfig(t, "invalid.yaml", "inputs:\n  - type: nope\n")
+	for _, tc := range []struct {
+		name     string
+		args     []string
+		expected string
+		err      string
+	}{
+		{name: "valid", args: []string{valid}, expected: valid + ": ok\n"},
+		{name: "invalid", args: []string{valid, invalid}, expected: valid + ": ok\n", err: "invalid pipeline config"},
+		{name: "missing file", args: []string{filepath.Join(t.TempDir(), "missing.yaml")}, err: "invalid pipeline config"},
+		{name: "no files", err: "usage: "},
+		{name: "unknown flag", args: []string{"-bogus"}, err: "flag provided but not defined"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			err := validateCommand(tc.args, &stdout, &stderr)
+			checkCommandError(t, err, tc.err)
+			if actual := stdout.String(); actual != tc.expected {
+				t.Errorf("expected %q but got %q", tc.expected, actual)
+			}
+			// each bad file is described, not just the first
+			if tc.err == "invalid pipeline config" && stderr.Len() == 0 {
+				t.Error("expected the problem to be described on stderr")
+			}
+		})
+	}
+}
+
+// sends one event, then waits like a listener would
+type oneEventInput struct {
+	loglang.BaseInputPlugin
+}
+
+func (p *oneEventInput) Run(ctx context.Context, sender loglang.Sender) error {
+	evt := loglang.NewEvent()
+	evt.Field("message").SetString("hello")
+	sender.Send(&evt)
+	<-ctx.Done()
+	return nil
+}
+
+func (p *oneEventInput) Extract(_ context.Context, _ *loglang.Event, _ io.Reader, output chan *loglang.Event) error {
+	close(output)
+	return nil
+}
+
+// receives events, and with stuck set, never finishes sending them
+type signalOutput struct {
+	stuck    bool
+	received chan struct{}
+}
+
+func (p *signalOutput) Send(ctx context.Context, _ []*loglang.Event, _ loglang.CodecPlugin, _ loglang.FramingPlugin) error {
+	close(p.received)
+	if p.stuck {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+// starts a runtime, and waits for its output to get an event
+func startRuntime(t *testing.T, stuck bool) (*loglang.Runtime, <-chan error) {
+	t.Helper()
+	p := loglang.NewPipeline("test", loglang.PipelineOptions{DrainTimeout: 50 * time.Millisecond})
+	p.Input("one", &oneEventInput{})
+	out := &signalOutput{stuck: stuck, received: make(chan struct{})}
+	p.Output("signal", out, nil, nil)
+	r := loglang.NewRuntime()
+	r.Add(p)
+
+	finished := make(chan error, 1)
+	go func() {
+		finished <- r.Run()
+	}()
+	select {
+	case <-out.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the output never got an event")
+	}
+	return r, finished
+}
+
+func TestSupervise(t *testing.T) {
+	valid := writeConfig(t, "valid.yaml", validConfig)
+	invalid := writeConfig(t, "invalid.yaml", "inputs:\n  - type: nope\n")
+	for _, tc := range []struct {
+		name    string
+		config  string
+		stuck   bool
+		signals []os.Signal
+		reload  bool
+		err     string
+	}{
+		{name: "stop", config: valid, signals: []os.Signal{syscall.SIGTERM}},
+		{name: "reload", config: valid, signals: []os.Signal{syscall.SIGHUP}, reload: true},
+		// a bad config keeps the current pipelines running, until they're stopped
+		{name: "reload bad config", config: invalid, signals: []os.Signal{syscall.SIGHUP, syscall.SIGTERM}},
+		// the reload must not start new pipelines while the old ones might still have events
+		{name: "reload fails to drain", config: valid, stuck: true, signals: []os.Signal{syscall.SIGHUP}, err: "drain timed out"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r, finished := startRuntime(t, tc.stuck)
+			signals := make(chan os.Signal, len(tc.signals))
+			for _, sig := range tc.signals {
+				signals <- sig
+			}
+			next, err := supervise(r, []string{tc.config}, signals, finished)
+			checkCommandError(t, err, tc.err)
+			if (next != nil) != tc.reload {
+				t.Errorf("expected reload=%t but got %v", tc.reload, next)
+			}
+		})
+	}
+}
+
+func TestRunCommandNeedsConfig(t *testing.T) {
+	if err := runCommand(nil); err == nil || !strings.HasPrefix(err.Error(), "usage: ") {
+		t.Errorf("expected usage but got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package codec
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"github.com/nicwaller/loglang"
@@ -22,15 +23,18 @@ func (p *autoCodec) Decode(dat []byte) (loglang.Event, error) {
 	const magicNumberGzip = 0x1f8b
 	const magicNumberChunkedGelf = 0x1e0f
 
+	if len(dat) == 0 {
+		return loglang.Event{}, &loglang.DecodeError{Codec: "auto", Err: fmt.Errorf("empty frame")}
+	}
 	if dat[0] == '{' && dat[len(dat)-1] == '}' {
 		var c jsonCodec
 		return c.Decode(dat)
-	} else if magicNumberGzip == binary.BigEndian.Uint16(dat) {
-		return loglang.Event{}, fmt.Errorf("autoCodec doesn't support gzip; use framing for that")
-	} else if magicNumberChunkedGelf == binary.BigEndian.Uint16(dat) {
+	} else if len(dat) >= 2 && magicNumberGzip == binary.BigEndian.Uint16(dat) {
+		return loglang.Event{}, &loglang.DecodeError{Codec: "auto", Err: fmt.Errorf("autoCodec doesn't support gzip; use framing for that")}
+	} else if len(dat) >= 2 && magicNumberChunkedGelf == binary.BigEndian.Uint16(dat) {
 		// detected magic bytes for chunked GELF
-		return loglang.Event{}, fmt.Errorf("autoCodec doesn't support chunked GELF; use framing for that")
-	} else if dat[0] == '-' && dat[1] == '-' && dat[2] == '-' {
+		return loglang.Event{}, &loglang.DecodeError{Codec: "auto", Err: fmt.Errorf("autoCodec doesn't support chunked GELF; use framing for that")}
+	} else if bytes.HasPrefix(dat, []byte("---")) {
 		var c yamlCodec
 		return c.Decode(dat)
 	} else if apacheCommonLogPattern.Match(dat) {
@@ -2,22 +2,91 @@ package codec
 
 import (
 	"encoding/json"
+	"time"
+
 	"github.com/nicwaller/loglang"
 )
 
+type JsonOptions struct {
+	// TimestampFormat is how timestamps are written: rfc3339nano (the default), epoch_millis, or syslog
+	TimestampFormat loglang.TimeFormat `yaml:"timestampFormat"`
+	// IncludeMetadata encodes [@metadata] too, eg. to pass it to another loglang
+	IncludeMetadata bool `yaml:"includeMetadata"`
+}
+
 func Json() loglang.CodecPlugin {
-	return &jsonCodec{}
+	return &jsonCodec{timeFormat: loglang.TimeFormatRFC3339Nano}
+}
+
+func JsonWithOptions(opts JsonOptions) loglang.CodecPlugin {
+	if opts.TimestampFormat == "" {
+		opts.TimestampFormat = loglang.TimeFormatRFC3339Nano
+	}
+	return &jsonCodec{timeFormat: opts.TimestampFormat, includeMetadata: opts.IncludeMetadata}
 }
 
-type jsonCodec struct{}
+type jsonCodec struct {
+	timeFormat      loglang.TimeFormat
+	includeMetadata bool
+}
 
 func (p *jsonCodec) Encode(event loglang.Event) ([]byte, error) {
 	// TODO: maybe use a different JSON encoder with deterministic ordering?
-	return json.Marshal(event.Fields)
+	fields := event.Fields
+	if p.includeMetadata {
+		fields = event.FieldsWithMetadata()
+	}
+	if p.timeFormat == loglang.TimeFormatRFC3339Nano {
+		// encoding/json already writes time.Time this way
+		return json.Marshal(fields)
+	}
+	return json.Marshal(formatTimes(fields, p.timeFormat))
 }
 
 func (p *jsonCodec) Decode(dat []byte) (loglang.Event, error) {
 	evt := loglang.NewEvent()
-	err := json.Unmarshal(dat, &evt.Fields)
-	return evt, err
+	if err := json.Unmarshal(dat, &evt.Fields); err != nil {
+		return evt, &loglang.DecodeError{Codec: "json", Err: err}
+	}
+	parseTimestamp(&evt, p.timeFormat)
+	// @metadata is reserved, so it can't hide in the regular fields
+	if metadata, isMap := evt.Fields[loglang.MetadataField].(map[string]any); isMap {
+		delete(evt.Fields, loglang.MetadataField)
+		evt.Metadata = metadata
+	}
+	return evt, nil
+}
+
+// formatTimes copies fields, replacing each time.Time with its wire format
+func formatTimes(value any, format loglang.TimeFormat) any {
+	switch v := value.(type) {
+	case time.Time:
+		return format.Format(v)
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, inner := range v {
+			m[k] = formatTimes(inner, format)
+		}
+		return m
+	case []any:
+		a := make([]any, len(v))
+		for i, inner := range v {
+			a[i] = formatTimes(inner, format)
+		}
+		return a
+	}
+	return value
+}
+
+// parseTimestamp turns a decoded @timestamp back into a time.Time, so later stages don't have to parse it
+// a timestamp that doesn't parse is left alone
+func parseTimestamp(evt *loglang.Event, format loglang.TimeFormat) {
+	field := evt.Field("@timestamp")
+	value, err := field.Get()
+	if err != nil {
+		return
+	}
+	if t, err := format.Parse(value); err == nil {
+		field.SetTime(t)
+	}
 }
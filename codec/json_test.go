@@ -3,6 +3,7 @@ package codec
 import (
 	"github.com/nicwaller/loglang"
 	"testing"
+	"time"
 )
 
 func TestJsonCodec_Encode(t *testing.T) {
@@ -30,3 +31,68 @@ func TestJsonCodec_Decode(t *testing.T) {
 		t.Errorf(`Expected "%s" but got "%s"`, expected, actual)
 	}
 }
+
+func TestJsonCodec_Timestamps(t *testing.T) {
+	ts := time.Date(2024, time.March, 5, 9, 7, 3, 123456789, time.UTC)
+	evt := loglang.NewEvent()
+	evt.Field("@timestamp").SetTime(ts)
+
+	tests := []struct {
+		format    loglang.TimeFormat
+		expected  string
+		precision time.Duration
+	}{
+		{loglang.TimeFormatRFC3339Nano, `{"@timestamp":"2024-03-05T09:07:03.123456789Z"}`, time.Nanosecond},
+		{loglang.TimeFormatEpochMillis, `{"@timestamp":1709629623123}`, time.Millisecond},
+	}
+	for _, tt := range tests {
+		codec := JsonWithOptions(JsonOptions{TimestampFormat: tt.format})
+		actual, err := codec.Encode(evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(actual) != tt.expected {
+			t.Errorf("%s: expected %s but got %s", tt.format, tt.expected, actual)
+		}
+		decoded, err := codec.Decode(actual)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, isTime := decoded.Field("@timestamp").MustGet().(time.Time); !isTime {
+			t.Errorf("%s: expected @timestamp to be decoded as a time", tt.format)
+		}
+		if !decoded.Field("@timestamp").GetTime().Equal(ts.Truncate(tt.precision)) {
+			t.Errorf("%s: timestamp changed to %s", tt.format, decoded.Field("@timestamp").GetTime())
+		}
+	}
+}
+
+func TestJsonCodec_Metadata(t *testing.T) {
+	evt := loglang.NewEvent()
+	evt.Field("message").SetString("hi")
+	evt.Field(loglang.MetadataField, "route").SetString("alerts")
+
+	actual, err := Json().Encode(evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(actual) != `{"message":"hi"}` {
+		t.Errorf("expected metadata to be left out but got %s", actual)
+	}
+
+	withMetadata := JsonWithOptions(JsonOptions{IncludeMetadata: true})
+	actual, err = withMetadata.Encode(evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(actual) != `{"@metadata":{"route":"alerts"},"message":"hi"}` {
+		t.Errorf("expected metadata to be included but got %s", actual)
+	}
+	decoded, err := withMetadata.Decode(actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Field(loglang.MetadataField, "route").GetString() != "alerts" {
+		t.Errorf("expected metadata to be decoded but got %v", decoded.Metadata)
+	}
+}
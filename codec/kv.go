@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func Kv() loglang.CodecPlugin {
@@ -36,7 +37,7 @@ func (p *kvCodec) Encode(evt loglang.Event) ([]byte, error) {
 	evt.TraverseFields(func(field loglang.Field) {
 		// FIXME: ignoring errors, oooh dangerous
 		value, _ := field.Get()
-		sb.WriteString(strings.Join(field.Path, "."))
+		sb.WriteString(loglang.DottedPath(field.Path))
 		sb.WriteString(`=`)
 		if strVal, ok := value.(string); ok {
 			strVal = strings.ReplaceAll(strVal, `"`, `\"`)
@@ -49,9 +50,10 @@ func (p *kvCodec) Encode(evt loglang.Event) ([]byte, error) {
 			sb.WriteString(strconv.FormatFloat(floatVal, 'f', -1, 64))
 		} else if boolVal, ok := value.(bool); ok {
 			sb.WriteString(strconv.FormatBool(boolVal))
-		} else if _, ok := value.([]any); ok {
-			slog.Error("kv encoding doesn't handle arrays/slices")
-			sb.WriteString("[]")
+		} else if timeVal, ok := value.(time.Time); ok {
+			sb.WriteString(`"` + timeVal.Format(time.RFC3339Nano) + `"`)
+		} else if value == nil {
+			sb.WriteString("null")
 		} else {
 			slog.Error("kv encoding doesn't handle this type: " + field.String())
 		}
@@ -64,6 +66,8 @@ func (p *kvCodec) Encode(evt loglang.Event) ([]byte, error) {
 
 func (p *kvCodec) Decode(dat []byte) (loglang.Event, error) {
 	evt := loglang.NewEvent()
+	// keys are field references, the way Encode writes them,
+	// so http.method=GET is nested, and dotted\.key=1 is a key with a dot in it
 	// FIXME: this parser is very bad
 	for _, field := range bytes.Split(dat, []byte{' '}) {
 		if string(field) == "" {
@@ -89,13 +93,13 @@ func (p *kvCodec) Decode(dat []byte) (loglang.Event, error) {
 			//value = strings.Trim(value, `"`)
 			value = value[1 : len(value)-1]
 			value = strings.ReplaceAll(value, `\"`, `"`)
-			evt.Field(key).SetString(value)
+			evt.Ref(key).SetString(value)
 		} else {
 			if intVal, err := strconv.Atoi(value); err == nil {
-				evt.Field(key).SetInt(intVal)
+				evt.Ref(key).SetInt(intVal)
 			} else {
 				// FIXME: support float here?
-				evt.Field(key).SetString(value)
+				evt.Ref(key).SetString(value)
 			}
 		}
 		// FIXME: handle bool, null
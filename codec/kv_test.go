@@ -171,3 +171,35 @@ func TestKvDecodeBareString(t *testing.T) {
 //		t.Error("expected apple")
 //	}
 //}
+
+func TestKvEncodeArray(t *testing.T) {
+	evt := loglang.NewEvent()
+	evt.Field("tags").Set([]any{"a", 2, map[string]any{"deep": true}})
+	dat, err := Kv().Encode(evt)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(dat) != `tags.0="a" tags.1=2 tags.2.deep=true ` {
+		t.Errorf("unexpected %s", dat)
+	}
+}
+
+func TestKvRoundTripNestedKeys(t *testing.T) {
+	evt := loglang.NewEvent()
+	evt.Field("http", "method").SetString("GET")
+	evt.Field("dotted.key").SetInt(1)
+	dat, err := Kv().Encode(evt)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(dat) != `dotted\.key=1 http.method="GET" ` {
+		t.Errorf("unexpected %s", dat)
+	}
+	decoded, err := Kv().Decode(dat)
+	if err != nil {
+		t.Error(err)
+	}
+	if decoded.Field("http", "method").GetString() != "GET" || decoded.Field("dotted.key").GetInt() != 1 {
+		t.Errorf("unexpected %v", decoded.Fields)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/nicwaller/loglang"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // NCSA Common Log format
@@ -15,6 +16,9 @@ func NCSACommonLog() loglang.CodecPlugin {
 	return &ncsaCommonLog{}
 }
 
+// eg. 10/Oct/2000:13:55:36 -0700
+const ncsaTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
 type ncsaCommonLog struct {
 	schema loglang.SchemaModel
 }
@@ -98,7 +102,7 @@ func (p *ncsaCommonLog) Decode(dat []byte) (loglang.Event, error) {
 		evt.Field("agent").SetString(useragent)
 
 	case loglang.SchemaFlat:
-		evt.Field("@timestamp").SetString(datestamp)
+		setNcsaTimestamp(&evt, datestamp)
 		evt.Field("http_version").SetString(httpVersion)
 		evt.Field("http_referrer").SetString(referrer)
 		evt.Field("http_method").SetString(method)
@@ -112,7 +116,7 @@ func (p *ncsaCommonLog) Decode(dat []byte) (loglang.Event, error) {
 
 	case loglang.SchemaLogstashECS:
 		//	https://www.elastic.co/guide/en/elasticsearch/reference/current/common-log-format-example.html
-		evt.Field("@timestamp").SetString(datestamp)
+		setNcsaTimestamp(&evt, datestamp)
 		evt.Field("http", "version").SetString(httpVersion)
 		evt.Field("http", "request", "referrer").SetString(referrer)
 		evt.Field("http", "request", "method").SetString(method)
@@ -125,7 +129,7 @@ func (p *ncsaCommonLog) Decode(dat []byte) (loglang.Event, error) {
 		evt.Field("url", "original").SetString(path)
 
 	case loglang.SchemaECS:
-		evt.Field("@timestamp").SetString(datestamp)
+		setNcsaTimestamp(&evt, datestamp)
 		evt.Field("http", "version").SetString(httpVersion)
 		evt.Field("http", "request", "referrer").SetString(referrer)
 		evt.Field("http", "request", "method").SetString(method)
@@ -141,6 +145,13 @@ func (p *ncsaCommonLog) Decode(dat []byte) (loglang.Event, error) {
 	return evt, nil
 }
 
+// a timestamp that can't be parsed is left out, so the pipeline uses the time it was received
+func setNcsaTimestamp(evt *loglang.Event, datestamp string) {
+	if t, err := time.Parse(ncsaTimeLayout, datestamp); err == nil {
+		evt.Field("@timestamp").SetTime(t)
+	}
+}
+
 var (
 	splitNcsa = func(data []byte, atEOF bool) (advance int, token []byte, err error) {
 		if atEOF && len(data) == 0 {
@@ -0,0 +1,31 @@
+package codec
+
+import (
+	"github.com/nicwaller/loglang"
+	"testing"
+	"time"
+)
+
+func TestNCSACommonLog_DecodeTimestamp(t *testing.T) {
+	line := `127.0.0.1 user-identifier frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`
+	codec := &ncsaCommonLog{schema: loglang.SchemaECS}
+	evt, err := codec.Decode([]byte(line))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := time.Date(2000, time.October, 10, 20, 55, 36, 0, time.UTC)
+	if actual, isTime := evt.Field("@timestamp").MustGet().(time.Time); !isTime || !actual.Equal(expected) {
+		t.Errorf("expected %s but got %#v", expected, evt.Field("@timestamp").MustGet())
+	}
+	if actual := evt.Field("http", "response", "status_code").GetInt(); actual != 200 {
+		t.Errorf("expected 200 but got %d", actual)
+	}
+
+	evt, err = codec.Decode([]byte(`127.0.0.1 - - [yesterday] "GET / HTTP/1.0" 200 1`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := evt.Field("@timestamp").Get(); err == nil {
+		t.Error("expected a bad timestamp to be left out")
+	}
+}
@@ -5,18 +5,20 @@ import (
 	"log/slog"
 )
 
+// Plain puts the whole message in one field, named by a reference like [log][original] or log.original
+//
 //goland:noinspection GoUnusedExportedFunction
 func Plain(fieldName string) loglang.CodecPlugin {
 	// TODO: fieldName should be optional and default to "message"
-	return &plainCodec{fieldName: fieldName}
+	return &plainCodec{path: loglang.MustParseFieldPath(fieldName)}
 }
 
 type plainCodec struct {
-	fieldName string
+	path []string
 }
 
 func (p *plainCodec) Encode(event loglang.Event) ([]byte, error) {
-	if v, err := event.Field(p.fieldName).Get(); err == nil {
+	if v, err := event.Field(p.path...).Get(); err == nil {
 		s := v.(string)
 		return []byte(s), nil
 	} else {
@@ -28,7 +30,7 @@ func (p *plainCodec) Encode(event loglang.Event) ([]byte, error) {
 }
 func (p *plainCodec) Decode(dat []byte) (loglang.Event, error) {
 	evt := loglang.NewEvent()
-	evt.Field(p.fieldName).Set(string(dat))
+	evt.Field(p.path...).Set(string(dat))
 	return evt, nil
 
 }
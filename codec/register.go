@@ -0,0 +1,60 @@
+package codec
+
+import (
+	"fmt"
+
+	"github.com/nicwaller/loglang"
+)
+
+type PlainOptions struct {
+	// Field holds the whole message (eg. "[log][original]"); defaults to "message"
+	Field string `yaml:"field"`
+}
+
+func init() {
+	loglang.RegisterCodec("auto", func(struct{}) (loglang.CodecPlugin, error) { return Auto(), nil })
+	loglang.RegisterCodec("json", func(opts JsonOptions) (loglang.CodecPlugin, error) {
+		format, err := loglang.ParseTimeFormat(string(opts.TimestampFormat))
+		if err != nil {
+			return nil, err
+		}
+		opts.TimestampFormat = format
+		return JsonWithOptions(opts), nil
+	})
+	loglang.RegisterCodec("kv", func(struct{}) (loglang.CodecPlugin, error) { return Kv(), nil })
+	loglang.RegisterCodec("ncsa", func(struct{}) (loglang.CodecPlugin, error) { return NCSACommonLog(), nil })
+	loglang.RegisterCodec("syslog", func(opts SyslogV0Options) (loglang.CodecPlugin, error) {
+		if err := loglang.CheckSchema(opts.Schema); err != nil {
+			return nil, err
+		}
+		if _, err := syslogLocation(opts.Timezone); err != nil {
+			return nil, fmt.Errorf("timezone: %w", err)
+		}
+		return SyslogV0WithOptions(opts), nil
+	})
+	loglang.RegisterCodec("syslog_auto", func(opts SyslogV0Options) (loglang.CodecPlugin, error) {
+		if err := loglang.CheckSchema(opts.Schema); err != nil {
+			return nil, err
+		}
+		if _, err := syslogLocation(opts.Timezone); err != nil {
+			return nil, fmt.Errorf("timezone: %w", err)
+		}
+		return SyslogAutoWithOptions(opts), nil
+	})
+	loglang.RegisterCodec("syslog5424", func(opts SyslogV1Options) (loglang.CodecPlugin, error) {
+		if err := loglang.CheckSchema(opts.Schema); err != nil {
+			return nil, err
+		}
+		return SyslogV1WithOptions(opts), nil
+	})
+	loglang.RegisterCodec("yaml", func(struct{}) (loglang.CodecPlugin, error) { return Yaml(), nil })
+	loglang.RegisterCodec("plain", func(opts PlainOptions) (loglang.CodecPlugin, error) {
+		if opts.Field == "" {
+			opts.Field = "message"
+		}
+		if _, err := loglang.ParseFieldPath(opts.Field); err != nil {
+			return nil, err
+		}
+		return Plain(opts.Field), nil
+	})
+}
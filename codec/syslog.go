@@ -0,0 +1,52 @@
+package codec
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/nicwaller/loglang"
+)
+
+// SyslogAuto decodes RFC 5424 or RFC 3164, deciding for each message, since one server often
+// receives both. It encodes RFC 5424.
+//
+//goland:noinspection GoUnusedExportedFunction
+func SyslogAuto() loglang.CodecPlugin {
+	return SyslogAutoWithOptions(SyslogV0Options{})
+}
+
+// SyslogAutoWithOptions panics if the timezone is unknown; the timezone is only for RFC 3164
+func SyslogAutoWithOptions(opts SyslogV0Options) loglang.CodecPlugin {
+	return &syslogAuto{
+		v0: SyslogV0WithOptions(opts).(*syslogV0),
+		v1: SyslogV1WithOptions(SyslogV1Options{Schema: opts.Schema}).(*syslogV1),
+	}
+}
+
+type syslogAuto struct {
+	v0 *syslogV0
+	v1 *syslogV1
+}
+
+func (p *syslogAuto) WithSchema(schema loglang.SchemaModel) loglang.CodecPlugin {
+	return &syslogAuto{
+		v0: p.v0.WithSchema(schema).(*syslogV0),
+		v1: p.v1.WithSchema(schema).(*syslogV1),
+	}
+}
+
+// RFC 5424 has VERSION right after PRI
+var syslogV1Prefix = regexp.MustCompile(`^<\d{1,3}>1 `)
+
+func (p *syslogAuto) Decode(dat []byte) (loglang.Event, error) {
+	// senders often end datagrams with a linefeed (or even a NUL) that isn't part of the message
+	dat = bytes.TrimRight(dat, "\r\n\x00")
+	if syslogV1Prefix.Match(dat) {
+		return p.v1.Decode(dat)
+	}
+	return p.v0.Decode(dat)
+}
+
+func (p *syslogAuto) Encode(event loglang.Event) ([]byte, error) {
+	return p.v1.Encode(event)
+}
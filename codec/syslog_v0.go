@@ -5,22 +5,60 @@ import (
 	"fmt"
 	"github.com/nicwaller/loglang"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Syslog V0
 // https://datatracker.ietf.org/doc/html/rfc3164
 // I hate this format - NW
 
-// Syslog V1
-// https://datatracker.ietf.org/doc/html/rfc5424
+type SyslogV0Options struct {
+	// Schema decides where decoded fields go; by default it's the pipeline schema
+	Schema loglang.SchemaModel `yaml:"schema"`
+	// Timezone is for timestamps that don't have one (eg. "UTC" or "America/Vancouver"); defaults to local time
+	Timezone string `yaml:"timezone"`
+}
 
 //goland:noinspection GoUnusedExportedFunction
 func SyslogV0() loglang.CodecPlugin {
-	return &syslogV0{}
+	return &syslogV0{location: time.Local, now: time.Now}
+}
+
+// SyslogV0WithOptions panics if the timezone is unknown
+func SyslogV0WithOptions(opts SyslogV0Options) loglang.CodecPlugin {
+	location, err := syslogLocation(opts.Timezone)
+	if err != nil {
+		panic(err)
+	}
+	return &syslogV0{schema: opts.Schema, location: location, now: time.Now}
+}
+
+func syslogLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(timezone)
+}
+
+type syslogV0 struct {
+	schema   loglang.SchemaModel
+	location *time.Location
+	// now is for guessing the year
+	now func() time.Time
 }
 
-type syslogV0 struct{}
+// WithSchema is how inputs pass down the pipeline schema; a configured schema wins
+func (p *syslogV0) WithSchema(schema loglang.SchemaModel) loglang.CodecPlugin {
+	if p.schema != loglang.SchemaNotDefined {
+		return p
+	}
+	withSchema := *p
+	withSchema.schema = schema
+	return &withSchema
+}
 
 func (p *syslogV0) Encode(event loglang.Event) ([]byte, error) {
 	buf := bytes.NewBufferString("")
@@ -29,17 +67,27 @@ func (p *syslogV0) Encode(event loglang.Event) ([]byte, error) {
 	// TODO: maybe also look at [level]? coalesce?
 	facility := int8(1) // user-level
 	// TODO: read from [log][syslog][facility][code]
-	severityStr := loglang.Coalesce(
+	severityStr := loglang.CoalesceStr(
+		event.Field("log", "level").GetString(),
 		event.Field("log.level").GetString(),
 		event.Field("level").GetString(),
-	).(string)
+	)
 	severityStr = strings.ToLower(severityStr)
 	severity, _ := syslogSeverityReverse[severityStr]
 	priority := syslogPriority(facility, severity)
 	buf.WriteString(fmt.Sprintf("<%d>", priority))
 
 	// PART 2 - HEADER
-	buf.WriteString(syslogHeader(event.Field("@timestamp").GetString()))
+	timestamp := event.Field("@timestamp").GetTime()
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	hostname := loglang.CoalesceStr(
+		event.Field("host", "name").GetString(),
+		event.Field("host").GetString(),
+		event.Field("hostname").GetString(),
+	)
+	buf.WriteString(syslogHeader(timestamp, hostname))
 
 	// PART 3 - MESSAGE
 	tag := "" // is there a best field for tag?
@@ -53,9 +101,133 @@ func (p *syslogV0) Encode(event loglang.Event) ([]byte, error) {
 
 }
 
-func (p *syslogV0) Decode(_ []byte) (loglang.Event, error) {
-	panic("I hate this format")
+// Decode is tolerant, because RFC 3164 only describes what devices used to do.
+// Only the PRI is certain; anything that doesn't parse ends up in the message.
+func (p *syslogV0) Decode(dat []byte) (loglang.Event, error) {
+	if len(dat) == 0 {
+		return loglang.Event{}, &loglang.DecodeError{Codec: "syslog", Err: fmt.Errorf("empty message")}
+	}
+	msg := p.parse(string(dat))
+
+	evt := loglang.NewEvent()
+	layout := syslogLayoutFor(p.schema)
+	facility, severity := msg.priority/8, msg.priority%8
+	evt.Field(layout.priority...).SetInt(msg.priority)
+	evt.Field(layout.facility...).SetInt(facility)
+	evt.Field(layout.facilityName...).SetString(syslogFacility[facility])
+	evt.Field(layout.severity...).SetInt(severity)
+	evt.Field(layout.severityName...).SetString(syslogSeverity[int8(severity)])
+	if msg.sequence >= 0 {
+		evt.Field(layout.sequence...).SetInt(msg.sequence)
+	}
+	if !msg.timestamp.IsZero() {
+		evt.Field("@timestamp").SetTime(msg.timestamp)
+	}
+	setUnlessEmpty := func(path []string, value string) {
+		if value != "" {
+			evt.Field(path...).SetString(value)
+		}
+	}
+	setUnlessEmpty(layout.hostname, msg.hostname)
+	setUnlessEmpty(layout.appName, msg.tag)
+	setUnlessEmpty(layout.procID, msg.pid)
+	evt.Field("message").SetString(msg.content)
+	return evt, nil
+}
+
+type syslogV0Message struct {
+	priority int
+	// sequence is -1 when there isn't one
+	sequence  int
+	timestamp time.Time
+	hostname  string
+	tag       string
+	pid       string
+	content   string
+}
+
+var (
+	// Cisco sends a sequence number before the timestamp, like "<189>123: "
+	syslogSequencePattern = regexp.MustCompile(`^(\d+): `)
+	// Mmm dd hh:mm:ss, with the variations seen in the wild: a * or . when the clock isn't synced,
+	// a single space before the day, milliseconds, a year, a time zone, and a colon after it all
+	syslogBSDTimePattern = regexp.MustCompile(`^[*.]?([A-Z][a-z]{2}) +(\d{1,2}) (?:(\d{4}) )?(\d{2}:\d{2}:\d{2}(?:\.\d{1,9})?)(?: ([A-Z]{1,5}))?:? `)
+	syslogISOTimePattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d{1,9})?)(Z|[+-]\d{2}:?\d{2})?:? `)
+	// TAG[PID]: is the usual start of the content, but TAG is often more than the alphanumerics RFC 3164 allows
+	syslogTagPattern = regexp.MustCompile(`^([^\s\[\]:]{1,48})(?:\[([^\]\s]*)\])?: ?`)
+)
+
+func (p *syslogV0) parse(s string) syslogV0Message {
+	msg := syslogV0Message{sequence: -1}
+
+	// an invalid PRI means the whole thing is content, from user-level with notice severity
+	priority, rest, err := parseSyslogPriority(s)
+	if err != nil {
+		priority, rest = 13, s
+	}
+	msg.priority = priority
+	s = rest
+
+	if match := syslogSequencePattern.FindStringSubmatch(s); match != nil {
+		msg.sequence, _ = strconv.Atoi(match[1])
+		s = s[len(match[0]):]
+	}
+
+	var found bool
+	if msg.timestamp, s, found = p.parseTimestamp(s); found {
+		// the hostname is optional; if the next word looks like a TAG, it isn't there
+		if host, rest, hasSpace := strings.Cut(s, " "); hasSpace && host != "" && !syslogTagPattern.MatchString(s) {
+			msg.hostname = host
+			s = rest
+		}
+	}
+
+	if match := syslogTagPattern.FindStringSubmatch(s); match != nil {
+		msg.tag, msg.pid = match[1], match[2]
+		s = s[len(match[0]):]
+	}
+	msg.content = s
+	return msg
+}
+
+// parseTimestamp reads a timestamp and the space after it; the time is zero if there isn't one
+func (p *syslogV0) parseTimestamp(s string) (time.Time, string, bool) {
+	now := p.now().In(p.location)
+
+	if match := syslogBSDTimePattern.FindStringSubmatch(s); match != nil {
+		location := p.location
+		if zone := match[5]; zone == "UTC" || zone == "GMT" || zone == "Z" {
+			location = time.UTC
+		}
+		var t time.Time
+		var err error
+		if year := match[3]; year != "" {
+			t, err = time.ParseInLocation("Jan _2 2006 15:04:05", match[1]+" "+match[2]+" "+year+" "+match[4], location)
+		} else {
+			t, err = loglang.ParseSyslogTime(match[1]+" "+match[2]+" "+match[4], now.In(location))
+		}
+		if err == nil {
+			return t, s[len(match[0]):], true
+		}
+	}
+
+	if match := syslogISOTimePattern.FindStringSubmatch(s); match != nil {
+		var t time.Time
+		var err error
+		switch zone := match[2]; {
+		case zone == "":
+			t, err = time.ParseInLocation("2006-01-02T15:04:05", match[1], p.location)
+		case strings.Contains(zone, ":") || zone == "Z":
+			t, err = time.Parse(time.RFC3339Nano, match[1]+zone)
+		default:
+			t, err = time.Parse("2006-01-02T15:04:05-0700", match[1]+zone)
+		}
+		if err == nil {
+			return t, s[len(match[0]):], true
+		}
+	}
 
+	return time.Time{}, s, false
 }
 
 // The Priority value is calculated by first multiplying the Facility
@@ -68,20 +240,26 @@ func syslogPriority(facility int8, severity int8) int8 {
 	return facility*8 + severity
 }
 
-func syslogHeader(timestamp string) string {
+func syslogHeader(timestamp time.Time, hostname string) string {
 	//Timestamp = Mmm dd hh:mm:ss
-	//timestamp := time.Now().Format("Jan _2 15:04:05")
-	hostname, err := os.Hostname()
-	if err != nil {
-		// TODO: discover IPv4 or IPv6 address
-		hostname = "127.0.0.1"
+	if hostname == "" {
+		hostname = localHostname()
 	}
 
 	// hostname must not contain any embedded spaces
 	hostname = strings.ReplaceAll(hostname, " ", "-")
 
 	//A single space character MUST also follow the HOSTNAME field.
-	return fmt.Sprintf("%s %s ", timestamp, hostname)
+	return fmt.Sprintf("%s %s ", loglang.TimeFormatSyslog.Format(timestamp), hostname)
+}
+
+func localHostname() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		// TODO: discover IPv4 or IPv6 address
+		return "127.0.0.1"
+	}
+	return hostname
 }
 
 // The MSG part has two fields known as the TAG field and the CONTENT
@@ -103,23 +281,32 @@ func syslogMessage(tag string, content string) string {
 	return fmt.Sprintf("%s: %s", tag, content)
 }
 
+// facility keywords, as used by syslog.conf and ECS [log][syslog][facility][name]
 var syslogFacility = map[int]string{
-	0:  "kernel",
+	0:  "kern",
 	1:  "user",
 	2:  "mail",
-	3:  "system",
-	4:  "security",
-	5:  "syslogd",
-	6:  "line printer",
-	7:  "network news",
+	3:  "daemon",
+	4:  "auth",
+	5:  "syslog",
+	6:  "lpr",
+	7:  "news",
 	8:  "uucp",
-	9:  "clock",
-	10: "security",
+	9:  "cron",
+	10: "authpriv",
 	11: "ftp",
 	12: "ntp",
-	13: "audit",
-	14: "alert",
-	15: "clock",
+	13: "security", // log audit
+	14: "console",  // log alert
+	15: "solaris-cron",
+	16: "local0",
+	17: "local1",
+	18: "local2",
+	19: "local3",
+	20: "local4",
+	21: "local5",
+	22: "local6",
+	23: "local7",
 }
 
 var syslogSeverity = map[int8]string{
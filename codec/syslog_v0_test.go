@@ -3,6 +3,7 @@ package codec
 import (
 	"github.com/nicwaller/loglang"
 	"testing"
+	"time"
 )
 
 func TestSyslogV0_Encode(t *testing.T) {
@@ -10,6 +11,7 @@ func TestSyslogV0_Encode(t *testing.T) {
 	evt.Field("@timestamp").SetString("Oct 15 21:27:56")
 	evt.Field("message").SetString("Hello, World!")
 	evt.Field("level").SetString("143")
+	evt.Field("host", "name").SetString("serendipity.local")
 	actual, err := SyslogV0().Encode(evt)
 	if err != nil {
 		t.Error(err)
@@ -19,3 +21,151 @@ func TestSyslogV0_Encode(t *testing.T) {
 		t.Errorf(`Expected "%s" but got "%s"`, expected, actual)
 	}
 }
+
+func TestSyslogV0_EncodeTime(t *testing.T) {
+	evt := loglang.NewEvent()
+	evt.Field("@timestamp").SetTime(time.Date(2024, time.March, 5, 9, 7, 3, 123456789, time.Local))
+	evt.Field("message").SetString("Hello")
+	evt.Field("host").SetString("web 1")
+	actual, err := SyslogV0().Encode(evt)
+	if err != nil {
+		t.Error(err)
+	}
+	// single-digit days are padded with a space
+	expected := `<8>Mar  5 09:07:03 web-1 Hello`
+	if string(actual) != expected {
+		t.Errorf(`Expected "%s" but got "%s"`, expected, actual)
+	}
+}
+
+// decodes with a fixed clock, so the year can be guessed
+func decodeSyslogV0(t *testing.T, opts SyslogV0Options, line string) loglang.Event {
+	t.Helper()
+	codec := SyslogV0WithOptions(opts).(*syslogV0)
+	codec.now = func() time.Time { return time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC) }
+	evt, err := codec.Decode([]byte(line))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return evt
+}
+
+func TestSyslogV0_Decode(t *testing.T) {
+	evt := decodeSyslogV0(t, SyslogV0Options{Timezone: "UTC"},
+		"<34>Oct 11 22:14:15 mymachine su[230]: 'su root' failed for lonvick on /dev/pts/8")
+
+	expected := map[string]any{
+		"[log][syslog][priority]":       34,
+		"[log][syslog][facility][code]": 4,
+		"[log][syslog][facility][name]": "auth",
+		"[log][syslog][severity][code]": 2,
+		"[log][syslog][severity][name]": "critical",
+		"[log][syslog][hostname]":       "mymachine",
+		"[log][syslog][appname]":        "su",
+		"[log][syslog][procid]":         "230",
+		"message":                       "'su root' failed for lonvick on /dev/pts/8",
+	}
+	for ref, value := range expected {
+		if actual := evt.Ref(ref).MustGet(); actual != value {
+			t.Errorf("%s: expected %v but got %v", ref, value, actual)
+		}
+	}
+	// October is in the future, so it must be from last year
+	if actual := evt.Field("@timestamp").GetTime(); !actual.Equal(time.Date(2023, time.October, 11, 22, 14, 15, 0, time.UTC)) {
+		t.Errorf("unexpected timestamp %v", actual)
+	}
+}
+
+func TestSyslogV0_DecodeVariations(t *testing.T) {
+	vancouver, err := time.LoadLocation("America/Vancouver")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		name      string
+		line      string
+		timestamp time.Time
+		hostname  string
+		tag       string
+		message   string
+	}{
+		{
+			name:      "missing hostname",
+			line:      "<13>Mar  1 08:00:00 sshd[42]: Accepted publickey",
+			timestamp: time.Date(2024, time.March, 1, 8, 0, 0, 0, vancouver),
+			tag:       "sshd",
+			message:   "Accepted publickey",
+		},
+		{
+			name:      "ISO timestamp",
+			line:      "<13>2024-03-05T09:07:03.250+01:00 fw01 kernel: dropped packet",
+			timestamp: time.Date(2024, time.March, 5, 8, 7, 3, 250000000, time.UTC),
+			hostname:  "fw01",
+			tag:       "kernel",
+			message:   "dropped packet",
+		},
+		{
+			name:      "Cisco sequence number",
+			line:      "<189>117: *Mar  1 18:46:11.123 UTC: %SYS-5-CONFIG_I: Configured from console by vty0",
+			timestamp: time.Date(2024, time.March, 1, 18, 46, 11, 123000000, time.UTC),
+			tag:       "%SYS-5-CONFIG_I",
+			message:   "Configured from console by vty0",
+		},
+		{
+			name:      "year in timestamp",
+			line:      "<13>Dec 24 2019 10:00:00 switch3 stp: topology change",
+			timestamp: time.Date(2019, time.December, 24, 10, 0, 0, 0, vancouver),
+			hostname:  "switch3",
+			tag:       "stp",
+			message:   "topology change",
+		},
+		{
+			name:    "no timestamp",
+			line:    "<13>just some words",
+			message: "just some words",
+		},
+		{
+			name:    "no PRI",
+			line:    "myapp: started",
+			tag:     "myapp",
+			message: "started",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evt := decodeSyslogV0(t, SyslogV0Options{Timezone: "America/Vancouver", Schema: loglang.SchemaFlat}, tt.line)
+			if actual := evt.Field("@timestamp").GetTime(); !actual.Equal(tt.timestamp) {
+				t.Errorf("expected timestamp %v but got %v", tt.timestamp, actual)
+			}
+			if actual := evt.Field("logsource").GetString(); actual != tt.hostname {
+				t.Errorf("expected hostname %q but got %q", tt.hostname, actual)
+			}
+			if actual := evt.Field("program").GetString(); actual != tt.tag {
+				t.Errorf("expected tag %q but got %q", tt.tag, actual)
+			}
+			if actual := evt.Field("message").GetString(); actual != tt.message {
+				t.Errorf("expected message %q but got %q", tt.message, actual)
+			}
+		})
+	}
+
+	// and the sequence number is kept
+	evt := decodeSyslogV0(t, SyslogV0Options{}, tests[2].line)
+	if actual := evt.Field("event", "sequence").GetInt(); actual != 117 {
+		t.Errorf("expected sequence 117 but got %d", actual)
+	}
+	if actual := evt.Field("log", "syslog", "priority").GetInt(); actual != 189 {
+		t.Errorf("expected priority 189 but got %d", actual)
+	}
+}
+
+func TestSyslogV0_DecodeMissingPRI(t *testing.T) {
+	evt := decodeSyslogV0(t, SyslogV0Options{}, "<999>hello")
+	// RFC 3164 says to treat the whole thing as content from user.notice
+	if actual := evt.Field("log", "syslog", "priority").GetInt(); actual != 13 {
+		t.Errorf("expected priority 13 but got %d", actual)
+	}
+	if actual := evt.Field("message").GetString(); actual != "<999>hello" {
+		t.Errorf("expected the whole line as the message but got %q", actual)
+	}
+}
@@ -1,4 +1,476 @@
 package codec
 
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nicwaller/loglang"
+)
+
 // Syslog V1
 // https://datatracker.ietf.org/doc/html/rfc5424
+//
+//	<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3"] BOMAn application event
+
+type SyslogV1Options struct {
+	// Schema decides where decoded fields go; by default it's the pipeline schema
+	Schema loglang.SchemaModel `yaml:"schema"`
+}
+
+func SyslogV1() loglang.CodecPlugin {
+	return &syslogV1{}
+}
+
+func SyslogV1WithOptions(opts SyslogV1Options) loglang.CodecPlugin {
+	return &syslogV1{schema: opts.Schema}
+}
+
+type syslogV1 struct {
+	schema loglang.SchemaModel
+}
+
+// WithSchema is how inputs pass down the pipeline schema; a configured schema wins
+func (p *syslogV1) WithSchema(schema loglang.SchemaModel) loglang.CodecPlugin {
+	if p.schema != loglang.SchemaNotDefined {
+		return p
+	}
+	return &syslogV1{schema: schema}
+}
+
+// the MSG is UTF-8 when it starts with a byte order mark
+const syslogBOM = "\xEF\xBB\xBF"
+
+// maximum lengths of the header fields
+const (
+	syslogMaxHostname = 255
+	syslogMaxAppName  = 48
+	syslogMaxProcID   = 128
+	syslogMaxMsgID    = 32
+	syslogMaxSDName   = 32
+)
+
+// syslogLayout is where each part of a syslog message goes in an event
+type syslogLayout struct {
+	priority       []string
+	facility       []string
+	facilityName   []string
+	severity       []string
+	severityName   []string
+	version        []string
+	hostname       []string
+	appName        []string
+	procID         []string
+	msgID          []string
+	structuredData []string
+	// sequence is the message counter some devices send (eg. Cisco); RFC 3164 only
+	sequence []string
+}
+
+var syslogLayoutECS = syslogLayout{
+	priority:       []string{"log", "syslog", "priority"},
+	facility:       []string{"log", "syslog", "facility", "code"},
+	facilityName:   []string{"log", "syslog", "facility", "name"},
+	severity:       []string{"log", "syslog", "severity", "code"},
+	severityName:   []string{"log", "syslog", "severity", "name"},
+	version:        []string{"log", "syslog", "version"},
+	hostname:       []string{"log", "syslog", "hostname"},
+	appName:        []string{"log", "syslog", "appname"},
+	procID:         []string{"log", "syslog", "procid"},
+	msgID:          []string{"log", "syslog", "msgid"},
+	structuredData: []string{"log", "syslog", "structured_data"},
+	sequence:       []string{"event", "sequence"},
+}
+
+// flat names are the same as the Logstash syslog input
+var syslogLayoutFlat = syslogLayout{
+	priority:       []string{"priority"},
+	facility:       []string{"facility"},
+	facilityName:   []string{"facility_label"},
+	severity:       []string{"severity"},
+	severityName:   []string{"severity_label"},
+	version:        []string{"syslog_version"},
+	hostname:       []string{"logsource"},
+	appName:        []string{"program"},
+	procID:         []string{"pid"},
+	msgID:          []string{"msgid"},
+	structuredData: []string{"structured_data"},
+	sequence:       []string{"sequence"},
+}
+
+func syslogLayoutFor(schema loglang.SchemaModel) syslogLayout {
+	switch schema {
+	case loglang.SchemaNone, loglang.SchemaFlat, loglang.SchemaLogstashFlat:
+		return syslogLayoutFlat
+	}
+	return syslogLayoutECS
+}
+
+func (p *syslogV1) Encode(event loglang.Event) ([]byte, error) {
+	ecs, flat := syslogLayoutECS, syslogLayoutFlat
+	buf := bytes.NewBufferString("")
+
+	// HEADER
+	timestamp := event.Field("@timestamp").GetTime()
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	hostname := loglang.CoalesceStr(
+		event.Field(ecs.hostname...).GetString(),
+		event.Field(flat.hostname...).GetString(),
+		event.Field("host", "name").GetString(),
+		event.Field("host", "hostname").GetString(),
+		event.Field("host").GetString(),
+		event.Field("hostname").GetString(),
+	)
+	if hostname == "" {
+		hostname = localHostname()
+	}
+	appName := loglang.CoalesceStr(
+		event.Field(ecs.appName...).GetString(),
+		event.Field(flat.appName...).GetString(),
+		event.Field("process", "name").GetString(),
+		event.Field("service", "name").GetString(),
+	)
+	procID := syslogProcID(event, ecs.procID, flat.procID, []string{"process", "pid"})
+	msgID := loglang.CoalesceStr(
+		event.Field(ecs.msgID...).GetString(),
+		event.Field(flat.msgID...).GetString(),
+	)
+	// TIME-SECFRAC has at most 6 digits
+	buf.WriteString(fmt.Sprintf("<%d>1 %s %s %s %s %s ",
+		syslogV1Priority(event),
+		timestamp.Format("2006-01-02T15:04:05.999999Z07:00"),
+		syslogHeaderField(hostname, syslogMaxHostname),
+		syslogHeaderField(appName, syslogMaxAppName),
+		syslogHeaderField(procID, syslogMaxProcID),
+		syslogHeaderField(msgID, syslogMaxMsgID),
+	))
+
+	// STRUCTURED-DATA
+	sd := event.Field(ecs.structuredData...).GetMap()
+	if sd == nil {
+		sd = event.Field(flat.structuredData...).GetMap()
+	}
+	writeStructuredData(buf, sd)
+
+	// MSG
+	if message := event.Field("message").GetString(); message != "" {
+		buf.WriteString(" " + syslogBOM + message)
+	}
+	return buf.Bytes(), nil
+}
+
+// syslogV1Priority prefers the facility and severity codes, since filters are more likely to change those
+func syslogV1Priority(event loglang.Event) int {
+	ecs, flat := syslogLayoutECS, syslogLayoutFlat
+	facility := 1 // user-level
+	severity := 6 // informational
+	if pri, ok := syslogInt(event, ecs.priority, flat.priority); ok && pri >= 0 && pri <= 191 {
+		facility, severity = pri/8, pri%8
+	}
+	if code, ok := syslogInt(event, ecs.facility, flat.facility); ok && code >= 0 && code <= 23 {
+		facility = code
+	}
+	if code, ok := syslogInt(event, ecs.severity, flat.severity); ok && code >= 0 && code <= 7 {
+		severity = code
+	} else {
+		level := strings.ToLower(loglang.CoalesceStr(
+			event.Field("log", "level").GetString(),
+			event.Field("log.level").GetString(),
+			event.Field("level").GetString(),
+		))
+		if code, known := syslogSeverityReverse[level]; known {
+			severity = int(code)
+		}
+	}
+	return facility*8 + severity
+}
+
+func syslogInt(event loglang.Event, paths ...[]string) (int, bool) {
+	for _, path := range paths {
+		switch v := event.Field(path...).MustGet().(type) {
+		case int:
+			return v, true
+		case int64:
+			return int(v), true
+		case float64:
+			return int(v), true
+		case string:
+			if i, err := strconv.Atoi(v); err == nil {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// syslogProcID prefers numbers, so a pid decoded from JSON (a float) is written as an integer
+// but a PROCID can be any string too (eg. a thread name)
+func syslogProcID(event loglang.Event, paths ...[]string) string {
+	for _, path := range paths {
+		if pid, ok := syslogInt(event, path); ok {
+			return strconv.Itoa(pid)
+		}
+		if procID := event.Field(path...).GetString(); procID != "" {
+			return procID
+		}
+	}
+	return ""
+}
+
+// syslogHeaderField uses the NILVALUE for empty fields, and replaces anything that isn't printable ASCII
+func syslogHeaderField(value string, maxLen int) string {
+	if value == "" {
+		return "-"
+	}
+	value = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '-'
+		}
+		return r
+	}, value)
+	return value[:min(len(value), maxLen)]
+}
+
+// SD-NAMEs are printable ASCII except = ] " and space
+func syslogSDName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 || r == '=' || r == ']' || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	return name[:min(len(name), syslogMaxSDName)]
+}
+
+var syslogParamEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// writeStructuredData writes each SD-ELEMENT in order, so the output is the same every time.
+// An array is written as the same param repeated, which RFC 5424 allows.
+func writeStructuredData(buf *bytes.Buffer, sd map[string]any) {
+	ids := make([]string, 0, len(sd))
+	for id := range sd {
+		if syslogSDName(id) != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		buf.WriteString("-")
+		return
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		buf.WriteString("[" + syslogSDName(id))
+		params, _ := sd[id].(map[string]any)
+		names := make([]string, 0, len(params))
+		for name := range params {
+			if syslogSDName(name) != "" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			values, isArray := params[name].([]any)
+			if !isArray {
+				values = []any{params[name]}
+			}
+			for _, value := range values {
+				text := fmt.Sprint(value)
+				if t, isTime := value.(time.Time); isTime {
+					text = t.Format(time.RFC3339Nano)
+				}
+				buf.WriteString(fmt.Sprintf(` %s="%s"`, syslogSDName(name), syslogParamEscaper.Replace(text)))
+			}
+		}
+		buf.WriteString("]")
+	}
+}
+
+func (p *syslogV1) Decode(dat []byte) (loglang.Event, error) {
+	msg, err := parseSyslogV1(string(dat))
+	if err != nil {
+		return loglang.Event{}, &loglang.DecodeError{Codec: "syslog5424", Err: err}
+	}
+
+	evt := loglang.NewEvent()
+	layout := syslogLayoutFor(p.schema)
+	facility, severity := msg.priority/8, msg.priority%8
+	evt.Field(layout.priority...).SetInt(msg.priority)
+	evt.Field(layout.facility...).SetInt(facility)
+	evt.Field(layout.facilityName...).SetString(syslogFacility[facility])
+	evt.Field(layout.severity...).SetInt(severity)
+	evt.Field(layout.severityName...).SetString(syslogSeverity[int8(severity)])
+	evt.Field(layout.version...).SetString(msg.version)
+	if !msg.timestamp.IsZero() {
+		evt.Field("@timestamp").SetTime(msg.timestamp)
+	}
+	// NILVALUE fields are left out
+	setUnlessNil := func(path []string, value string) {
+		if value != "" {
+			evt.Field(path...).SetString(value)
+		}
+	}
+	setUnlessNil(layout.hostname, msg.hostname)
+	setUnlessNil(layout.appName, msg.appName)
+	setUnlessNil(layout.procID, msg.procID)
+	setUnlessNil(layout.msgID, msg.msgID)
+	if len(msg.structuredData) > 0 {
+		evt.Field(layout.structuredData...).Set(msg.structuredData)
+	}
+	if msg.message != "" {
+		evt.Field("message").SetString(msg.message)
+	}
+	return evt, nil
+}
+
+// syslogV1Message is one parsed message; fields that were the NILVALUE (-) are empty
+type syslogV1Message struct {
+	priority       int
+	version        string
+	timestamp      time.Time
+	hostname       string
+	appName        string
+	procID         string
+	msgID          string
+	structuredData map[string]any
+	message        string
+}
+
+var syslogV1HeaderNames = []string{"VERSION", "TIMESTAMP", "HOSTNAME", "APP-NAME", "PROCID", "MSGID"}
+
+func parseSyslogV1(s string) (syslogV1Message, error) {
+	var msg syslogV1Message
+	var err error
+	if msg.priority, s, err = parseSyslogPriority(s); err != nil {
+		return msg, err
+	}
+
+	// each header field is followed by one space, including the last one before STRUCTURED-DATA
+	header := make([]string, len(syslogV1HeaderNames))
+	for i, name := range syslogV1HeaderNames {
+		var found bool
+		header[i], s, found = strings.Cut(s, " ")
+		if !found || header[i] == "" {
+			return msg, fmt.Errorf("missing %s", name)
+		}
+	}
+	nilValue := func(field string) string {
+		if field == "-" {
+			return ""
+		}
+		return field
+	}
+
+	if msg.version = header[0]; msg.version != "1" {
+		return msg, fmt.Errorf("unsupported syslog version %q", msg.version)
+	}
+	if header[1] != "-" {
+		if msg.timestamp, err = time.Parse(time.RFC3339Nano, header[1]); err != nil {
+			return msg, fmt.Errorf("bad TIMESTAMP %q", header[1])
+		}
+	}
+	msg.hostname = nilValue(header[2])
+	msg.appName = nilValue(header[3])
+	msg.procID = nilValue(header[4])
+	msg.msgID = nilValue(header[5])
+
+	if msg.structuredData, s, err = parseStructuredData(s); err != nil {
+		return msg, err
+	}
+	if s != "" {
+		if s[0] != ' ' {
+			return msg, fmt.Errorf("expected a space after STRUCTURED-DATA")
+		}
+		msg.message = strings.TrimPrefix(s[1:], syslogBOM)
+	}
+	return msg, nil
+}
+
+// parseSyslogPriority reads <PRI> and returns the rest
+func parseSyslogPriority(s string) (int, string, error) {
+	end := strings.IndexByte(s, '>')
+	if !strings.HasPrefix(s, "<") || end < 2 || end > 4 {
+		return 0, s, fmt.Errorf("missing PRI")
+	}
+	priority, err := strconv.Atoi(s[1:end])
+	if err != nil || priority < 0 || priority > 191 {
+		return 0, s, fmt.Errorf("bad PRI %q", s[1:end])
+	}
+	return priority, s[end+1:], nil
+}
+
+// parseStructuredData reads SD-ELEMENTs into a map of SD-ID to params, and returns the rest.
+// A param that appears more than once in an element becomes an array.
+func parseStructuredData(s string) (map[string]any, string, error) {
+	if strings.HasPrefix(s, "-") {
+		return nil, s[1:], nil
+	}
+	if !strings.HasPrefix(s, "[") {
+		return nil, s, fmt.Errorf("missing STRUCTURED-DATA")
+	}
+	sd := make(map[string]any)
+	for strings.HasPrefix(s, "[") {
+		s = s[1:]
+		end := strings.IndexAny(s, " ]")
+		if end <= 0 {
+			return nil, s, fmt.Errorf("bad SD-ID")
+		}
+		id := s[:end]
+		s = s[end:]
+		if _, exists := sd[id]; exists {
+			return nil, s, fmt.Errorf("SD-ID %q appears more than once", id)
+		}
+
+		params := make(map[string]any)
+		for strings.HasPrefix(s, " ") {
+			name, rest, found := strings.Cut(s[1:], `="`)
+			if !found || name == "" || strings.ContainsAny(name, ` ]"`) {
+				return nil, s, fmt.Errorf("bad SD-PARAM in [%s]", id)
+			}
+			var value string
+			var err error
+			if value, s, err = parseParamValue(rest); err != nil {
+				return nil, s, fmt.Errorf("SD-PARAM %s in [%s]: %w", name, id, err)
+			}
+			switch existing := params[name].(type) {
+			case nil:
+				params[name] = value
+			case string:
+				params[name] = []any{existing, value}
+			case []any:
+				params[name] = append(existing, value)
+			}
+		}
+		if !strings.HasPrefix(s, "]") {
+			return nil, s, fmt.Errorf("unterminated SD-ELEMENT [%s]", id)
+		}
+		s = s[1:]
+		sd[id] = params
+	}
+	return sd, s, nil
+}
+
+// parseParamValue reads up to the closing quote; only \" \\ and \] are escapes
+func parseParamValue(s string) (string, string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return sb.String(), s[i+1:], nil
+		case '\\':
+			if i+1 < len(s) && strings.IndexByte(`"\]`, s[i+1]) >= 0 {
+				i++
+				sb.WriteByte(s[i])
+				continue
+			}
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", s, fmt.Errorf("unterminated value")
+}
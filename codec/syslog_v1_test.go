@@ -0,0 +1,190 @@
+package codec
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nicwaller/loglang"
+)
+
+func TestSyslogV1_Decode(t *testing.T) {
+	line := `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 ` +
+		`[exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high"] ` +
+		syslogBOM + `An application event log entry...`
+	evt, err := SyslogV1().Decode([]byte(line))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]any{
+		"[log][syslog][priority]":                                      165,
+		"[log][syslog][facility][code]":                                20,
+		"[log][syslog][facility][name]":                                "local4",
+		"[log][syslog][severity][code]":                                5,
+		"[log][syslog][severity][name]":                                "notice",
+		"[log][syslog][version]":                                       "1",
+		"[log][syslog][hostname]":                                      "mymachine.example.com",
+		"[log][syslog][appname]":                                       "evntslog",
+		"[log][syslog][msgid]":                                         "ID47",
+		"[log][syslog][structured_data][exampleSDID@32473][eventID]":   "1011",
+		"[log][syslog][structured_data][examplePriority@32473][class]": "high",
+		"message": "An application event log entry...",
+	}
+	for ref, value := range expected {
+		if actual := evt.Ref(ref).MustGet(); actual != value {
+			t.Errorf("%s: expected %v but got %v", ref, value, actual)
+		}
+	}
+	if _, err := evt.Ref("[log][syslog][procid]").Get(); err == nil {
+		t.Error("expected the NILVALUE procid to be left out")
+	}
+	if actual := evt.Field("@timestamp").GetTime(); !actual.Equal(time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC)) {
+		t.Errorf("unexpected timestamp %v", actual)
+	}
+}
+
+func TestSyslogV1_DecodeFlat(t *testing.T) {
+	line := `<34>1 - host su 123 - [meta key="a" key="b\"\]\\c"]`
+	evt, err := SyslogV1WithOptions(SyslogV1Options{Schema: loglang.SchemaFlat}).Decode([]byte(line))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := evt.Get("facility_label"); actual != "auth" {
+		t.Errorf("expected auth but got %v", actual)
+	}
+	if actual := evt.Get("pid"); actual != "123" {
+		t.Errorf("expected 123 but got %v", actual)
+	}
+	// repeated params become an array, and escapes are undone
+	if actual := evt.Field("structured_data", "meta", "key").GetStrings(); !reflect.DeepEqual(actual, []string{"a", `b"]\c`}) {
+		t.Errorf("unexpected params %q", actual)
+	}
+	if _, err := evt.Field("message").Get(); err == nil {
+		t.Error("expected no message")
+	}
+	if _, err := evt.Field("@timestamp").Get(); err == nil {
+		t.Error("expected no timestamp")
+	}
+}
+
+func TestSyslogV1_DecodeErrors(t *testing.T) {
+	for _, line := range []string{
+		"",
+		"hello",
+		"<192>1 - - - - - -",
+		"<13>2 - - - - - -",
+		"<13>1 yesterday - - - - -",
+		"<13>1 - - - - -",
+		"<13>1 - - - - - [unterminated",
+		`<13>1 - - - - - [id key="value]`,
+		`<13>1 - - - - - [id][id]`,
+		"<13>1 - - - - - -message",
+	} {
+		_, err := SyslogV1().Decode([]byte(line))
+		if err == nil {
+			t.Errorf("expected %q to be rejected", line)
+			continue
+		}
+		if !strings.Contains(err.Error(), "syslog5424") {
+			t.Errorf("expected a syslog5424 DecodeError but got %v", err)
+		}
+	}
+}
+
+func TestSyslogV1_Encode(t *testing.T) {
+	evt := loglang.NewEvent()
+	evt.Field("@timestamp").SetTime(time.Date(2024, time.March, 5, 9, 7, 3, 123456789, time.UTC))
+	evt.Field("message").SetString("Hello")
+	evt.Field("log", "level").SetString("error")
+	evt.Field("host", "name").SetString("web 1")
+	evt.Field("process", "name").SetString("api")
+	evt.Field("process", "pid").SetInt(42)
+	evt.Field("log", "syslog", "structured_data").Set(map[string]any{
+		"origin": map[string]any{"ip": []any{"10.0.0.1", "10.0.0.2"}},
+		"meta":   map[string]any{"note": `a "quoted" [value]`},
+	})
+	actual, err := SyslogV1().Encode(evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<11>1 2024-03-05T09:07:03.123456Z web-1 api 42 - ` +
+		`[meta note="a \"quoted\" [value\]"][origin ip="10.0.0.1" ip="10.0.0.2"] ` + syslogBOM + `Hello`
+	if string(actual) != expected {
+		t.Errorf("Expected\n%q\nbut got\n%q", expected, actual)
+	}
+}
+
+func TestSyslogV1_EncodeFromJson(t *testing.T) {
+	// JSON numbers are decoded as floats
+	evt, err := Json().Decode([]byte(`{"@timestamp":"2024-03-05T09:07:03Z","message":"Hello","host":{"name":"web-1"},"process":{"name":"api","pid":1234},"log":{"syslog":{"severity":{"code":3}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := SyslogV1().Encode(evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<11>1 2024-03-05T09:07:03Z web-1 api 1234 - - ` + syslogBOM + `Hello`
+	if string(actual) != expected {
+		t.Errorf("Expected\n%q\nbut got\n%q", expected, actual)
+	}
+}
+
+func TestSyslogV1_RoundTrip(t *testing.T) {
+	for _, schema := range []loglang.SchemaModel{loglang.SchemaECS, loglang.SchemaFlat} {
+		line := `<190>1 2024-03-05T09:07:03.5+01:00 db-2 postgres 77 CHECKPOINT [stats@1 buffers="12"] ` + syslogBOM + `checkpoint complete`
+		codec := SyslogV1WithOptions(SyslogV1Options{Schema: schema})
+		evt, err := codec.Decode([]byte(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded, err := codec.Encode(evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(encoded) != line {
+			t.Errorf("%s: expected\n%q\nbut got\n%q", schema, line, encoded)
+		}
+	}
+}
+
+func TestSyslogV1_PipelineSchema(t *testing.T) {
+	codec := SyslogV1().(loglang.SchemaCodec).WithSchema(loglang.SchemaLogstashFlat)
+	evt, err := codec.Decode([]byte("<13>1 - myhost - - - -"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := evt.Get("logsource"); actual != "myhost" {
+		t.Errorf("expected the flat layout but got %v", evt.Fields)
+	}
+
+	// a schema from the codec's own options wins
+	configured := SyslogV1WithOptions(SyslogV1Options{Schema: loglang.SchemaECS})
+	if configured.(loglang.SchemaCodec).WithSchema(loglang.SchemaFlat) != configured {
+		t.Error("expected the configured schema to be kept")
+	}
+}
+
+func TestSyslogAuto_Decode(t *testing.T) {
+	codec := SyslogAutoWithOptions(SyslogV0Options{Timezone: "UTC"})
+	rfc5424, err := codec.Decode([]byte("<13>1 - host5424 app - - - hello\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := rfc5424.Ref("[log][syslog][version]").MustGet(); actual != "1" {
+		t.Errorf("expected RFC 5424 but got %v", rfc5424.Fields)
+	}
+	if actual := rfc5424.Get("message"); actual != "hello" {
+		t.Errorf("expected the trailing linefeed to be trimmed but got %q", actual)
+	}
+
+	rfc3164, err := codec.Decode([]byte("<13>Mar  1 08:00:00 host3164 app: hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := rfc3164.Ref("[log][syslog][hostname]").MustGet(); actual != "host3164" {
+		t.Errorf("expected RFC 3164 but got %v", rfc3164.Fields)
+	}
+}
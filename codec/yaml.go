@@ -17,6 +17,8 @@ func (p *yamlCodec) Encode(event loglang.Event) ([]byte, error) {
 
 func (p *yamlCodec) Decode(dat []byte) (loglang.Event, error) {
 	evt := loglang.NewEvent()
-	err := yaml.Unmarshal(dat, &evt.Fields)
-	return evt, err
+	if err := yaml.Unmarshal(dat, &evt.Fields); err != nil {
+		return evt, &loglang.DecodeError{Codec: "yaml", Err: err}
+	}
+	return evt, nil
 }
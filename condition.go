@@ -0,0 +1,31 @@
+package loglang
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Condition decides something about an event; eg. whether it should go to an output
+type Condition func(event *Event) bool
+
+// ParseCondition compiles an expression (see Expr) for use as a Condition, so conditions can be written in config files.
+//
+//	log.level >= "error"
+//	[http][response][status] == 503
+//	message =~ /^timeout/
+//	exists([error][message])
+//
+// An expression that fails to evaluate is false.
+func ParseCondition(source string) (Condition, error) {
+	if strings.TrimSpace(source) == "" {
+		return nil, fmt.Errorf("empty condition")
+	}
+	expr, err := CompileExpr(source)
+	if err != nil {
+		return nil, err
+	}
+	return func(event *Event) bool {
+		ok, _ := expr.Test(event)
+		return ok
+	}, nil
+}
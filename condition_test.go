@@ -0,0 +1,57 @@
+package loglang
+
+import "testing"
+
+func TestParseCondition(t *testing.T) {
+	evt := NewEvent()
+	evt.Field("log", "level").SetString("warning")
+	evt.Field("http", "response", "status").SetInt(503)
+	evt.Field("message").SetString("timeout: upstream")
+	evt.Field("dotted.key").SetString("literal")
+	evt.Field("error").SetString("upstream closed")
+
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{`log.level >= "warn"`, true},
+		{`log.level >= "error"`, false},
+		{`log.level == "warning"`, true},
+		// level names must be quoted; bare words are fields, even when they look like a level
+		{"log.level == error", false},
+		{`error == "upstream closed"`, true},
+		{`[error] == "upstream closed"`, true},
+		{`[log][level] < "ERROR"`, true},
+		{"[http][response][status] >= 500", true},
+		{"[http][response][status] == 503", true},
+		{"[http][response][status] != 503", false},
+		{"http.response.status > 1000", false},
+		{`message =~ "^timeout"`, true},
+		{`message =~ /^timeout/`, true},
+		{"message == 'timeout: upstream'", true},
+		{"message", true},
+		{`[dotted.key] == "literal"`, true},
+		{"missing", false},
+		{`missing == "x"`, false},
+		{`missing != "x"`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			cond, err := ParseCondition(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := cond(&evt); actual != tt.expected {
+				t.Errorf("expected %t but got %t", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseCondition_Errors(t *testing.T) {
+	for _, expr := range []string{"", "level >=", "message =~ '('", "[log][level"} {
+		if _, err := ParseCondition(expr); err == nil {
+			t.Errorf("expected %q to be rejected", expr)
+		}
+	}
+}
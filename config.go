@@ -0,0 +1,448 @@
+package loglang
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Pipelines can also be defined in a YAML (or JSON) file, for when recompiling isn't an option.
+// The plugins are looked up by type in the registry, so the plugin packages must be imported.
+//
+//	name: demo
+//	options:
+//	  schema: loglang/flat
+//	inputs:
+//	  - type: heartbeat
+//	    options: {interval: 1s}
+//	  - type: tcp
+//	    options: {port: 5000}
+//	    codec: json
+//	    framing: lines
+//	filters:
+//	  - type: remove
+//	    options: {field: host}
+//	outputs:
+//	  - name: out1
+//	    type: stdout
+//	    codec: json
+//	    framing: lines
+//	    batch: {maxEvents: 100, linger: 1s}
+//	  - name: alerts
+//	    type: slack
+//	    route: log.level >= "error"
+
+type PipelineConfig struct {
+	Name       string          `yaml:"name"`
+	Options    PipelineOptions `yaml:"options"`
+	Inputs     []InputSpec     `yaml:"inputs"`
+	Filters    []PluginSpec    `yaml:"filters"`
+	Outputs    []OutputSpec    `yaml:"outputs"`
+	DeadLetter *OutputSpec     `yaml:"deadLetter"`
+	// StallOutput receives stall warnings instead of the pipeline
+	StallOutput *OutputSpec `yaml:"stallOutput"`
+
+	file string
+}
+
+// PluginSpec chooses a registered plugin by type, and gives it options.
+// A plugin without options can be written as just the type. (eg. `codec: json`)
+type PluginSpec struct {
+	Name    string    `yaml:"name"`
+	Type    string    `yaml:"type"`
+	Options yaml.Node `yaml:"options"`
+
+	line int
+}
+
+type InputSpec struct {
+	Name    string    `yaml:"name"`
+	Type    string    `yaml:"type"`
+	Options yaml.Node `yaml:"options"`
+	// Codec and Framing replace the input's own; only for inputs that decode byte streams
+	Codec   *PluginSpec  `yaml:"codec"`
+	Framing *PluginSpec  `yaml:"framing"`
+	Filters []PluginSpec `yaml:"filters"`
+
+	line int
+}
+
+type OutputSpec struct {
+	Name    string       `yaml:"name"`
+	Type    string       `yaml:"type"`
+	Options yaml.Node    `yaml:"options"`
+	Codec   *PluginSpec  `yaml:"codec"`
+	Framing *PluginSpec  `yaml:"framing"`
+	Filters []PluginSpec `yaml:"filters"`
+	Batch   BatchOptions `yaml:"batch"`
+	Retry   RetryOptions `yaml:"retry"`
+	// Route is a condition (eg. `log.level >= "error"`) for which events go to this output
+	Route string `yaml:"route"`
+
+	line int
+}
+
+func (s *PluginSpec) UnmarshalYAML(node *yaml.Node) error {
+	s.line = node.Line
+	if node.Kind == yaml.ScalarNode {
+		s.Type = node.Value
+		return nil
+	}
+	type plain PluginSpec
+	return node.Decode((*plain)(s))
+}
+
+func (s *InputSpec) UnmarshalYAML(node *yaml.Node) error {
+	s.line = node.Line
+	type plain InputSpec
+	return node.Decode((*plain)(s))
+}
+
+func (s *OutputSpec) UnmarshalYAML(node *yaml.Node) error {
+	s.line = node.Line
+	type plain OutputSpec
+	return node.Decode((*plain)(s))
+}
+
+// ConfigError points at the part of a pipeline config that's wrong
+type ConfigError struct {
+	File string
+	Line int
+	// Key is the path to the offending key (eg. outputs[1].codec)
+	Key string
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	var sb strings.Builder
+	if e.File != "" {
+		sb.WriteString(e.File)
+		sb.WriteString(":")
+	}
+	if e.Line > 0 {
+		sb.WriteString(fmt.Sprintf("%d:", e.Line))
+	}
+	if sb.Len() > 0 {
+		sb.WriteString(" ")
+	}
+	if e.Key != "" {
+		sb.WriteString(e.Key)
+		sb.WriteString(": ")
+	}
+	sb.WriteString(e.Err.Error())
+	return sb.String()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// nestConfigError adds a parent key to the path of a ConfigError
+func nestConfigError(parent string, err error) error {
+	cfgErr, isConfigErr := err.(*ConfigError)
+	if !isConfigErr {
+		return err
+	}
+	nested := *cfgErr
+	switch {
+	case nested.Key == "":
+		nested.Key = parent
+	case strings.HasPrefix(nested.Key, "["):
+		nested.Key = parent + nested.Key
+	default:
+		nested.Key = parent + "." + nested.Key
+	}
+	return &nested
+}
+
+// LoadPipeline reads a pipeline definition from a YAML or JSON file and builds it
+func LoadPipeline(path string) (*Pipeline, error) {
+	cfg, err := LoadPipelineConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Build()
+}
+
+// LoadRuntime builds a pipeline from each file, so that they can be linked together
+func LoadRuntime(paths ...string) (*Runtime, error) {
+	r := NewRuntime()
+	var problems []error
+	for _, path := range paths {
+		p, err := LoadPipeline(path)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		r.Add(p)
+	}
+	if len(problems) > 0 {
+		return nil, errors.Join(problems...)
+	}
+	return r, nil
+}
+
+func LoadPipelineConfig(path string) (*PipelineConfig, error) {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := ParsePipelineConfig(dat)
+	if err != nil {
+		var cfgErr *ConfigError
+		if errors.As(err, &cfgErr) {
+			cfgErr.File = path
+		}
+		return nil, err
+	}
+	cfg.file = path
+	if cfg.Name == "" {
+		cfg.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return cfg, nil
+}
+
+// ParsePipelineConfig reads a pipeline definition; JSON works too since it's (mostly) a subset of YAML
+func ParsePipelineConfig(dat []byte) (*PipelineConfig, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(dat, &doc); err != nil {
+		return nil, &ConfigError{Err: err}
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, &ConfigError{Err: fmt.Errorf("empty pipeline config")}
+	}
+	var cfg PipelineConfig
+	if err := decodeStrict(doc.Content[0], &cfg); err != nil {
+		var cfgErr *ConfigError
+		if !errors.As(err, &cfgErr) {
+			err = &ConfigError{Err: err}
+		}
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Build checks the whole config and makes a Pipeline that's ready to Run().
+// All problems are reported together, not just the first.
+func (c *PipelineConfig) Build() (*Pipeline, error) {
+	var problems []error
+	fail := func(key string, line int, err error) {
+		problems = append(problems, c.configError(key, line, err))
+	}
+
+	if err := CheckSchema(c.Options.Schema); err != nil {
+		fail("options.schema", 0, err)
+	}
+	if _, err := ParseDecodeFailurePolicy(string(c.Options.DecodeFailure)); err != nil {
+		fail("options.decodeFailure", 0, err)
+	}
+	if len(c.Inputs) == 0 {
+		fail("inputs", 0, fmt.Errorf("at least one input is required"))
+	}
+	if len(c.Outputs) == 0 {
+		fail("outputs", 0, fmt.Errorf("at least one output is required"))
+	}
+
+	inputs := make([]NamedEntity[inputDetail], 0, len(c.Inputs))
+	inputNames := make(map[string]bool)
+	for i, spec := range c.Inputs {
+		key := fmt.Sprintf("inputs[%d]", i)
+		name := defaultName(spec.Name, spec.Type)
+		if inputNames[name] {
+			fail(key+".name", spec.line, fmt.Errorf("duplicate input name %q", name))
+		}
+		inputNames[name] = true
+		plugin, err := inputRegistry.build(spec.Type, &spec.Options)
+		if err != nil {
+			fail(key, spec.line, err)
+		} else {
+			problems = append(problems, c.buildDecoding(key, plugin, spec)...)
+		}
+		filters, errs := c.buildFilters(key+".filters", spec.Filters)
+		problems = append(problems, errs...)
+		inputs = append(inputs, NamedEntity[inputDetail]{
+			Name:  name,
+			Value: inputDetail{plugin: plugin, filterChain: filters},
+		})
+	}
+
+	filters, errs := c.buildFilters("filters", c.Filters)
+	problems = append(problems, errs...)
+
+	outputs := make([]NamedEntity[OutputConfig], 0, len(c.Outputs))
+	outputNames := make(map[string]bool)
+	for i, spec := range c.Outputs {
+		key := fmt.Sprintf("outputs[%d]", i)
+		output, errs := c.buildOutput(key, spec)
+		problems = append(problems, errs...)
+		if outputNames[output.Name] {
+			fail(key+".name", spec.line, fmt.Errorf("duplicate output name %q", output.Name))
+		}
+		outputNames[output.Name] = true
+		outputs = append(outputs, output)
+	}
+
+	var deadLetter *NamedEntity[OutputConfig]
+	if c.DeadLetter != nil {
+		if len(c.DeadLetter.Filters) > 0 {
+			fail("deadLetter.filters", c.DeadLetter.line, fmt.Errorf("not supported for the dead letter output"))
+		}
+		if c.DeadLetter.Route != "" {
+			fail("deadLetter.route", c.DeadLetter.line, fmt.Errorf("not supported for the dead letter output"))
+		}
+		output, errs := c.buildOutput("deadLetter", *c.DeadLetter)
+		problems = append(problems, errs...)
+		deadLetter = &output
+	}
+
+	var stallOutput *NamedEntity[OutputConfig]
+	if c.StallOutput != nil {
+		if len(c.StallOutput.Filters) > 0 {
+			fail("stallOutput.filters", c.StallOutput.line, fmt.Errorf("not supported for the stall output"))
+		}
+		if c.StallOutput.Route != "" {
+			fail("stallOutput.route", c.StallOutput.line, fmt.Errorf("not supported for the stall output"))
+		}
+		output, errs := c.buildOutput("stallOutput", *c.StallOutput)
+		problems = append(problems, errs...)
+		stallOutput = &output
+	}
+
+	if len(problems) > 0 {
+		return nil, errors.Join(problems...)
+	}
+
+	name := c.Name
+	if name == "" {
+		name = "pipeline"
+	}
+	p := NewPipeline(name, c.Options)
+	for _, f := range filters {
+		p.Filter(f.Name, f.Value)
+	}
+	for _, input := range inputs {
+		p.Input(input.Name, input.Value.plugin, input.Value.filterChain...)
+	}
+	for _, output := range outputs {
+		cfg := output.Value
+		p.OutputWithOptions(output.Name, cfg.output, cfg.codec, cfg.framing, cfg.opts)
+	}
+	if deadLetter != nil {
+		p.DeadLetter(deadLetter.Name, deadLetter.Value.output, deadLetter.Value.codec, deadLetter.Value.framing)
+	}
+	if stallOutput != nil {
+		p.StallOutput(stallOutput.Name, stallOutput.Value.output, stallOutput.Value.codec, stallOutput.Value.framing)
+	}
+	return p, nil
+}
+
+func (c *PipelineConfig) buildFilters(key string, specs []PluginSpec) ([]NamedEntity[FilterPlugin], []error) {
+	var problems []error
+	filters := make([]NamedEntity[FilterPlugin], 0, len(specs))
+	for i, spec := range specs {
+		plugin, err := filterRegistry.build(spec.Type, &spec.Options)
+		if err != nil {
+			problems = append(problems, c.configError(fmt.Sprintf("%s[%d]", key, i), spec.line, err))
+			continue
+		}
+		filters = append(filters, NamedEntity[FilterPlugin]{
+			Name:  defaultName(spec.Name, spec.Type),
+			Value: plugin,
+		})
+	}
+	return filters, problems
+}
+
+// buildDecoding swaps in the codec and framing chosen for an input
+func (c *PipelineConfig) buildDecoding(key string, plugin InputPlugin, spec InputSpec) []error {
+	if spec.Codec == nil && spec.Framing == nil {
+		return nil
+	}
+	decoding, isDecoding := plugin.(DecodingInput)
+	if !isDecoding {
+		return []error{c.configError(key, spec.line, fmt.Errorf("input type %q doesn't decode byte streams, so it can't have a codec or framing", spec.Type))}
+	}
+	var problems []error
+	if spec.Codec != nil {
+		codec, err := BuildCodec(*spec.Codec)
+		if err != nil {
+			problems = append(problems, c.configError(key+".codec", spec.Codec.line, err))
+		} else {
+			decoding.SetCodec(codec)
+		}
+	}
+	if spec.Framing != nil {
+		framing, err := BuildFraming(*spec.Framing)
+		if err != nil {
+			problems = append(problems, c.configError(key+".framing", spec.Framing.line, err))
+		} else {
+			decoding.SetFraming(framing)
+		}
+	}
+	return problems
+}
+
+func (c *PipelineConfig) buildOutput(key string, spec OutputSpec) (NamedEntity[OutputConfig], []error) {
+	var problems []error
+	output := NamedEntity[OutputConfig]{Name: defaultName(spec.Name, spec.Type)}
+
+	plugin, err := outputRegistry.build(spec.Type, &spec.Options)
+	if err != nil {
+		problems = append(problems, c.configError(key, spec.line, err))
+	}
+	output.Value.output = plugin
+
+	if spec.Codec != nil {
+		output.Value.codec, err = BuildCodec(*spec.Codec)
+		if err != nil {
+			problems = append(problems, c.configError(key+".codec", spec.Codec.line, err))
+		}
+	}
+	if spec.Framing != nil {
+		output.Value.framing, err = BuildFraming(*spec.Framing)
+		if err != nil {
+			problems = append(problems, c.configError(key+".framing", spec.Framing.line, err))
+		}
+	}
+
+	var route Condition
+	if spec.Route != "" {
+		route, err = ParseCondition(spec.Route)
+		if err != nil {
+			problems = append(problems, c.configError(key+".route", spec.line, err))
+		}
+	}
+
+	filters, errs := c.buildFilters(key+".filters", spec.Filters)
+	problems = append(problems, errs...)
+	output.Value.opts = OutputOptions{
+		Filters: filters,
+		Batch:   spec.Batch,
+		Retry:   spec.Retry,
+		Route:   route,
+	}
+	return output, problems
+}
+
+func (c *PipelineConfig) configError(key string, line int, err error) error {
+	cfgErr, isConfigErr := nestConfigError(key, err).(*ConfigError)
+	if !isConfigErr {
+		cfgErr = &ConfigError{Key: key, Err: err}
+	}
+	if cfgErr.Line == 0 {
+		cfgErr.Line = line
+	}
+	cfgErr.File = c.file
+	return cfgErr
+}
+
+func defaultName(name string, pluginType string) string {
+	if name != "" {
+		return name
+	}
+	return pluginType
+}
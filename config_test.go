@@ -0,0 +1,268 @@
+package loglang
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type sliceInputOptions struct {
+	Messages []string `yaml:"messages"`
+}
+
+// outputs built from config can't be reached from tests, so they share this one
+var configTestOutput = &memoryOutput{}
+
+// the real codecs and framing live in other packages; these only show which one was chosen
+type configTestCodec struct{ CodecPlugin }
+type configTestFraming struct{ FramingPlugin }
+
+func init() {
+	RegisterInput("test-slice", func(opts sliceInputOptions) (InputPlugin, error) {
+		return &sliceInput{events: messageEvents(opts.Messages...)}, nil
+	})
+	RegisterOutput("test-memory", func(struct{}) (OutputPlugin, error) {
+		return configTestOutput, nil
+	})
+	RegisterCodec("test-codec", func(struct{}) (CodecPlugin, error) {
+		return configTestCodec{}, nil
+	})
+	RegisterFraming("test-framing", func(struct{}) (FramingPlugin, error) {
+		return configTestFraming{}, nil
+	})
+}
+
+func writeConfig(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPipeline_Yaml(t *testing.T) {
+	path := writeConfig(t, "demo.yaml", `
+options:
+  schema: loglang/flat
+inputs:
+  - type: test-slice
+    options:
+      messages: [one, two]
+outputs:
+  - name: memory
+    type: test-memory
+    batch:
+      maxEvents: 10
+      linger: 10ms
+`)
+	p, err := LoadPipeline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "demo" {
+		t.Errorf("expected pipeline name from file name but got %q", p.Name)
+	}
+	if p.opts.Schema != SchemaFlat {
+		t.Errorf("expected schema to be set but got %q", p.opts.Schema)
+	}
+	if len(p.outputs) != 1 || p.outputs[0].Value.opts.Batch.MaxEvents != 10 {
+		t.Errorf("expected batch options on output")
+	}
+	if len(p.inputs) != 1 || p.inputs[0].Name != "test-slice" {
+		t.Errorf("expected input to be named after its type")
+	}
+}
+
+func TestLoadPipeline_Json(t *testing.T) {
+	path := writeConfig(t, "pipeline.json", `{
+	"name": "from-json",
+	"inputs": [{"type": "test-slice", "options": {"messages": ["hello"]}}],
+	"outputs": [{"type": "test-memory", "retry": {"maxAttempts": 3, "initialBackoff": "1s"}}]
+}`)
+	p, err := LoadPipeline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "from-json" {
+		t.Errorf("expected name from config but got %q", p.Name)
+	}
+	if p.outputs[0].Value.opts.Retry.MaxAttempts != 3 {
+		t.Errorf("expected retry options on output")
+	}
+}
+
+func TestLoadPipeline_InputDecoding(t *testing.T) {
+	path := writeConfig(t, "decoding.yaml", `
+inputs:
+  - type: test-slice
+    codec: test-codec
+    framing: test-framing
+outputs:
+  - type: test-memory
+`)
+	p, err := LoadPipeline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := p.inputs[0].Value.plugin.(*sliceInput)
+	if _, isChosen := in.Codec.(configTestCodec); !isChosen {
+		t.Errorf("expected the input to use the configured codec but got %T", in.Codec)
+	}
+	if len(in.Framing) != 1 {
+		t.Fatalf("expected one framing stage but got %d", len(in.Framing))
+	}
+	if _, isChosen := in.Framing[0].(configTestFraming); !isChosen {
+		t.Errorf("expected the input to use the configured framing but got %T", in.Framing[0])
+	}
+}
+
+func TestLoadPipeline_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		expected []string
+	}{
+		{
+			name: "unknown plugin type",
+			config: `
+inputs:
+  - type: test-slice
+outputs:
+  - type: test-memory
+  - type: nope
+`,
+			expected: []string{"6: outputs[1].type: unknown output type \"nope\""},
+		},
+		{
+			name: "misspelled option",
+			config: `
+inputs:
+  - type: test-slice
+    options:
+      mesages: [one]
+outputs:
+  - type: test-memory
+`,
+			expected: []string{"5: inputs[0].options.mesages: unknown key"},
+		},
+		{
+			name: "misspelled pipeline option",
+			config: `
+options:
+  queue:
+    pth: /tmp
+inputs:
+  - type: test-slice
+outputs:
+  - type: test-memory
+`,
+			expected: []string{"4: options.queue.pth: unknown key"},
+		},
+		{
+			name: "bad route",
+			config: `
+inputs:
+  - type: test-slice
+outputs:
+  - type: test-memory
+    route: "message =~ '('"
+`,
+			expected: []string{"5: outputs[0].route: bad regular expression"},
+		},
+		{
+			name: "unknown decode failure policy",
+			config: `
+options:
+  decodeFailure: ignore
+inputs:
+  - type: test-slice
+outputs:
+  - type: test-memory
+`,
+			expected: []string{"options.decodeFailure: unknown decode failure policy \"ignore\""},
+		},
+		{
+			name: "unknown input codec",
+			config: `
+inputs:
+  - type: test-slice
+    codec: nope
+    framing: {type: test-framing, options: {size: 1}}
+outputs:
+  - type: test-memory
+`,
+			expected: []string{
+				"4: inputs[0].codec.type: unknown codec type \"nope\"",
+				"5: inputs[0].framing.options.size: unknown key",
+			},
+		},
+		{
+			name: "codec for an input that doesn't decode",
+			config: `
+inputs:
+  - type: pipeline
+    options: {address: upstream}
+    codec: test-codec
+outputs:
+  - type: test-memory
+`,
+			expected: []string{"3: inputs[0]: input type \"pipeline\" doesn't decode byte streams"},
+		},
+		{
+			name: "every problem is reported",
+			config: `
+options:
+  schema: nonsense
+inputs:
+  - type: test-slice
+  - type: test-slice
+outputs:
+  - type: test-memory
+    codec: nope
+`,
+			expected: []string{
+				"options.schema: unknown schema",
+				"inputs[1].name: duplicate input name",
+				"outputs[0].codec.type: unknown codec type",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, "bad.yaml", tt.config)
+			_, err := LoadPipeline(path)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("expected error to contain %q but got:\n%s", expected, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadPipeline_Run(t *testing.T) {
+	path := writeConfig(t, "run.yaml", `
+inputs:
+  - type: test-slice
+    options:
+      messages: [one, two, three]
+outputs:
+  - type: test-memory
+`)
+	p, err := LoadPipeline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := p.inputs[0].Value.plugin.(*sliceInput)
+	if err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if in.result == nil || in.result.SuccessCount != 3 {
+		t.Errorf("expected all events to be delivered but got %v", in.result)
+	}
+}
@@ -0,0 +1,92 @@
+package loglang
+
+import (
+	"context"
+	"time"
+)
+
+// DeadLetter configures where events go when they fail a filter,
+// or when an output gives up on them after exhausting retries.
+// Each dead letter is a copy of the event as the input sent it (before any filters),
+// plus a [dead_letter] object describing the failure, so it can be replayed later.
+func (p *Pipeline) DeadLetter(name string, op OutputPlugin, cp CodecPlugin, fp FramingPlugin) {
+	p.deadLetter = &NamedEntity[OutputConfig]{
+		Name: name,
+		Value: OutputConfig{
+			output:  op,
+			codec:   cp,
+			framing: fp,
+		},
+	}
+}
+
+type deadLetterFailure struct {
+	// Stage is "filter" or "output"
+	Stage string
+	// Plugin is the name of the filter or output that failed
+	Plugin   string
+	Err      error
+	Attempts int
+}
+
+// sendDeadLetter returns false if there is no dead letter output configured, or it couldn't take the event
+func (p *Pipeline) sendDeadLetter(event *Event, failure deadLetterFailure) bool {
+	if p.deadLetter == nil {
+		return false
+	}
+
+	// replaying the dead letter will run the filters again, so it must not have been filtered already
+	// events made by filters (eg. split) have no original, so they're sent as they are
+	source := event
+	if event.original != nil {
+		source = event.original
+	}
+	// the dead letter must not be linked to the original E2E batch
+	dead := source.Copy()
+	dead.Field("dead_letter", "stage").SetString(failure.Stage)
+	dead.Field("dead_letter", "plugin").SetString(failure.Plugin)
+	dead.Field("dead_letter", "error").SetString(failure.Err.Error())
+	dead.Field("dead_letter", "attempts").SetInt(failure.Attempts)
+	dead.Field("dead_letter", "timestamp").SetTime(time.Now())
+
+	// dead letters are sent synchronously, so that E2E acknowledgement
+	// doesn't happen until the dead letter has been written somewhere
+	// outputs aren't expected to be thread-safe, so only one at a time
+	p.deadLetterMutex.Lock()
+	defer p.deadLetterMutex.Unlock()
+	outputCfg := p.deadLetter.Value
+	ctx := context.WithValue(p.ctx, ContextKeyPluginName, p.deadLetter.Name)
+	err := outputCfg.output.Send(ctx, []*Event{&dead}, outputCfg.codec, outputCfg.framing)
+	if err != nil {
+		// the event fails instead, so it isn't acknowledged
+		ContextLogger(ctx).Error("lost a dead letter", "error", err, "cause", failure.Err)
+		return false
+	}
+	return true
+}
+
+// wraps a filter chain reporter so that failed events go to the dead letter output
+// instead of continuing down the filter chain
+func (p *Pipeline) deadLetterReporter(report filterReporter) filterReporter {
+	return func(event *Event, filter string, dropped bool, err error) bool {
+		if report != nil && report(event, filter, dropped, err) {
+			return true
+		}
+		if err == nil {
+			return false
+		}
+		if !p.sendDeadLetter(event, deadLetterFailure{
+			Stage:    "filter",
+			Plugin:   filter,
+			Err:      err,
+			Attempts: 1,
+		}) {
+			return false
+		}
+		// the event goes no further, so it's finished as far as E2E is concerned
+		if event.batch != nil {
+			event.batch.reportDeadLetter()
+		}
+		return true
+	}
+}
@@ -2,12 +2,17 @@ package loglang
 
 import (
 	"sort"
+	"strconv"
 	"sync/atomic"
 )
 
 type Event struct {
 	Fields map[string]interface{}
 
+	// Metadata is reached with Field("@metadata", ...); codecs don't encode it (see MetadataField)
+	// it's nil until something is stored there
+	Metadata map[string]any
+
 	// the event may have been received as part of a batch with other events
 	// and we want to support end-to-end acknowledgement for the whole batch
 	batch *publishingBatch
@@ -16,12 +21,34 @@ type Event struct {
 	// when this reaches len(outputs) the event is fully delivered
 	// and the batch can be notified
 	finishedOutputs *atomic.Uint32
+
+	// outputs can drop the event with their own filter chain
+	// if every output drops it, the batch sees a drop instead of a success
+	droppedOutputs *atomic.Uint32
+
+	// outputs that gave up on the event sent it to the dead letter output instead
+	// if any did, the batch sees a dead letter instead of a success
+	deadLetteredOutputs *atomic.Uint32
+
+	// outputs that gave up on the event with nowhere else to put it
+	// if any did, the batch sees a failure, so the event isn't acknowledged
+	failedOutputs *atomic.Uint32
+
+	// how many outputs the event was routed to by fan-out
+	routedOutputs uint32
+
+	// the event as the input sent it, before any filters; only kept when there's a dead letter output
+	// so a dead letter can be replayed without running the filters over it twice
+	original *Event
 }
 
 func NewEvent() Event {
 	var newEvt Event
 	newEvt.Fields = make(map[string]interface{})
 	newEvt.finishedOutputs = &atomic.Uint32{}
+	newEvt.droppedOutputs = &atomic.Uint32{}
+	newEvt.deadLetteredOutputs = &atomic.Uint32{}
+	newEvt.failedOutputs = &atomic.Uint32{}
 	return newEvt
 }
 
@@ -38,6 +65,17 @@ func (evt *Event) Field(path ...string) *Field {
 	}
 }
 
+// Ref is the field named by a reference like [http][request][method] or http.request.method (see ParseFieldPath).
+// A reference that can't be parsed is taken as a single literal key.
+func (evt *Event) Ref(ref string) *Field {
+	path, err := ParseFieldPath(ref)
+	if err != nil {
+		return evt.Field(ref)
+	}
+	return evt.Field(path...)
+}
+
+// Set and Get take a single top-level key, literally (see the warning in Field); use Ref for a reference
 func (evt *Event) Set(field string, value any) {
 	evt.Field(field).Set(value)
 }
@@ -52,57 +90,179 @@ func (evt *Event) Get(field string) any {
 //	return evt.touchOrder
 //}
 
-// depth first
+// depth first; object keys are sorted and array elements are visited in order,
+// so each element of an array is a field with its index in the path (eg. [tags][0])
+// metadata isn't visited, because it isn't part of the event's content
 // might be better with channel or callback or iterator?
 type fieldCb func(field Field)
 
 func (evt *Event) TraverseFields(cb fieldCb) {
-	evt.traverseFields(true, cb, []string{}, &evt.Fields)
+	evt.traverseFields(true, true, cb, []string{}, evt.Fields)
 }
 
-func (evt *Event) traverseFields(inOrder bool, cb fieldCb, prefix []string, from *map[string]any) {
-	// getting an ordered set of keys is essential for deterministic encoding, especially for the kv codec
-	keys := make([]string, 0, len(*from))
-	for k := range *from {
-		keys = append(keys, k)
-	}
-	if inOrder {
-		sort.Strings(keys)
-	}
-	// now traverse the keys in order
-	for _, k := range keys {
-		v := (*from)[k]
-		if z, isMap := v.(map[string]any); isMap {
-			evt.traverseFields(inOrder, cb, append(prefix, k), &z)
-		} else {
+// with intoArrays false, a whole array is one field
+func (evt *Event) traverseFields(inOrder bool, intoArrays bool, cb fieldCb, prefix []string, from any) {
+	visit := func(key string, v any) {
+		// copy the prefix, so paths given to the callback never share a backing array
+		path := make([]string, 0, len(prefix)+1)
+		path = append(append(path, prefix...), key)
+		_, isMap := v.(map[string]any)
+		_, isArray := v.([]any)
+		switch {
+		case isMap, isArray && intoArrays:
+			evt.traverseFields(inOrder, intoArrays, cb, path, v)
+		default:
 			cb(Field{
-				Path:     append(prefix, k),
+				Path:     path,
 				original: evt,
 			})
 		}
 	}
+
+	switch container := from.(type) {
+	case map[string]any:
+		// getting an ordered set of keys is essential for deterministic encoding, especially for the kv codec
+		keys := make([]string, 0, len(container))
+		for k := range container {
+			keys = append(keys, k)
+		}
+		if inOrder {
+			sort.Strings(keys)
+		}
+		// now traverse the keys in order
+		for _, k := range keys {
+			visit(k, container[k])
+		}
+	case []any:
+		for i, v := range container {
+			visit(strconv.Itoa(i), v)
+		}
+	}
 }
 
+// Copy makes a deep copy of all the fields (and metadata), including nested maps and slices.
+// The copy is a brand new event; it is not part of any E2E batch,
+// so it's suitable for injecting new events from a filter.
 func (evt *Event) Copy() Event {
 	newEvt := NewEvent()
-	for k, v := range evt.Fields {
-		newEvt.Fields[k] = v
+	newEvt.Fields = deepCopy(evt.Fields).(map[string]any)
+	if evt.Metadata != nil {
+		newEvt.Metadata = deepCopy(evt.Metadata).(map[string]any)
 	}
 	//newEvt.touchOrder = make([]string, len(evt.touchOrder))
 	//copy(newEvt.touchOrder, evt.touchOrder)
 	return newEvt
 }
 
+// replica makes a deep copy that is still linked to the same E2E batch.
+// Used for fan-out, so each output gets a private copy it can mutate.
+func (evt *Event) replica() *Event {
+	newEvt := evt.Copy()
+	newEvt.batch = evt.batch
+	newEvt.finishedOutputs = evt.finishedOutputs
+	newEvt.droppedOutputs = evt.droppedOutputs
+	newEvt.deadLetteredOutputs = evt.deadLetteredOutputs
+	newEvt.failedOutputs = evt.failedOutputs
+	newEvt.routedOutputs = evt.routedOutputs
+	newEvt.original = evt.original
+	return &newEvt
+}
+
+// keepOriginal remembers the event as it is now, before any filters change it
+func (evt *Event) keepOriginal() {
+	original := evt.Copy()
+	evt.original = &original
+}
+
+func deepCopy(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, inner := range v {
+			m[k] = deepCopy(inner)
+		}
+		return m
+	case []any:
+		a := make([]any, len(v))
+		for i, inner := range v {
+			a[i] = deepCopy(inner)
+		}
+		return a
+	default:
+		// everything else is a scalar, which is already copied by value
+		return v
+	}
+}
+
+// how an output finished with an event
+type outputOutcome int
+
+const (
+	outputSent outputOutcome = iota
+	// the output's own filters dropped it
+	outputDropped
+	// the output (or its filters) failed, and the event went to the dead letter output
+	outputDeadLettered
+	// the output failed, and there was no dead letter output to take the event
+	outputFailed
+)
+
+// when an output is finished with an event (sent, dropped, dead lettered, or failed)
+// it must be counted exactly once for end-to-end acknowledgement
+// only the outputs that the event was routed to are counted
+func (evt *Event) finishOutput(outcome outputOutcome) {
+	if evt.batch == nil {
+		return
+	}
+	switch outcome {
+	case outputDropped:
+		evt.droppedOutputs.Add(1)
+	case outputDeadLettered:
+		evt.deadLetteredOutputs.Add(1)
+	case outputFailed:
+		evt.failedOutputs.Add(1)
+	}
+	// the last output to finish sees what every other output did
+	if evt.routedOutputs == evt.finishedOutputs.Add(1) {
+		switch {
+		case evt.droppedOutputs.Load() == evt.routedOutputs:
+			evt.batch.reportDrop()
+		case evt.failedOutputs.Load() > 0:
+			evt.batch.reportFailed()
+		case evt.deadLetteredOutputs.Load() > 0:
+			evt.batch.reportDeadLetter()
+		default:
+			evt.batch.reportOutput()
+		}
+	}
+}
+
 func (evt *Event) Merge(template *Event, overwrite bool) {
-	template.traverseFields(false, func(field Field) {
-		v := field.MustGet()
+	merge := func(field Field) {
+		// arrays must not be shared between events
+		v := deepCopy(field.MustGet())
 		field.original = evt
 		if overwrite {
 			field.Set(v)
 		} else {
 			field.Default(v)
 		}
-	}, []string{}, &evt.Fields)
+	}
+	template.traverseFields(false, false, merge, []string{}, template.Fields)
+	template.traverseFields(false, false, merge, []string{MetadataField}, template.Metadata)
+}
+
+// FieldsWithMetadata is for codecs that are explicitly asked to encode metadata too;
+// it's a shallow copy of the fields, with the metadata under "@metadata"
+func (evt *Event) FieldsWithMetadata() map[string]any {
+	fields := make(map[string]any, len(evt.Fields)+1)
+	for k, v := range evt.Fields {
+		fields[k] = v
+	}
+	if len(evt.Metadata) > 0 {
+		fields[MetadataField] = evt.Metadata
+	}
+	return fields
 }
 
 //func (evt *Event) touch(field string) {
@@ -0,0 +1,42 @@
+package loglang
+
+import (
+	"testing"
+)
+
+func TestEvent_CopyIsDeep(t *testing.T) {
+	evt := NewEvent()
+	evt.Field("http", "request", "method").SetString("GET")
+	evt.Fields["tags"] = []any{"a", map[string]any{"b": "c"}}
+
+	cp := evt.Copy()
+	cp.Field("http", "request", "method").SetString("POST")
+	cp.Fields["tags"].([]any)[0] = "z"
+	cp.Fields["tags"].([]any)[1].(map[string]any)["b"] = "z"
+
+	if actual := evt.Field("http", "request", "method").GetString(); actual != "GET" {
+		t.Errorf(`Expected "GET" but got "%s"`, actual)
+	}
+	tags := evt.Fields["tags"].([]any)
+	if tags[0] != "a" || tags[1].(map[string]any)["b"] != "c" {
+		t.Errorf("nested slice was modified through the copy: %v", tags)
+	}
+	if cp.batch != nil {
+		t.Error("Copy() must not be linked to a batch")
+	}
+}
+
+func TestEvent_ReplicaKeepsBatch(t *testing.T) {
+	evt := NewEvent()
+	evt.batch = newBatch()
+	evt.Field("message").SetString("hello")
+
+	r := evt.replica()
+	if r.batch != evt.batch || r.finishedOutputs != evt.finishedOutputs {
+		t.Error("replica must stay linked to the original batch")
+	}
+	r.Field("message").SetString("changed")
+	if evt.Field("message").GetString() != "hello" {
+		t.Error("replica must not share fields with the original")
+	}
+}
@@ -0,0 +1,1094 @@
+package loglang
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Expressions are evaluated against an event, so that conditions and computed values
+// can be written without Go (eg. in config files). The syntax is close to Logstash conditionals.
+//
+//	[http][response][status] >= 500 and [url][path] =~ /^\/api/
+//	log.level in ["error", "critical"] || !exists([user][id])
+//	lower([host][name]) + ":" + string([server][port])
+//
+// Fields are written as [nested][names], or as dotted.names with \. for a dot within a name (see ParseFieldPath).
+// A missing field is null. Comparisons understand numbers, strings, timestamps, and log levels (by severity).
+// Operators: == != < <= > >= =~ !~ in, not in, and (&&), or (||), not (!), + - * / %
+// Functions: exists lower upper trim len contains startsWith endsWith replace split join
+// concat coalesce string number int
+type Expr struct {
+	source string
+	root   exprNode
+}
+
+func CompileExpr(source string) (*Expr, error) {
+	tokens, err := lexExpr(source)
+	if err != nil {
+		return nil, err
+	}
+	parser := exprParser{source: source, tokens: tokens}
+	root, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := parser.peek(); tok.kind != tokEOF {
+		return nil, parser.errorAt(tok, "unexpected %q", tok.text)
+	}
+	return &Expr{source: source, root: root}, nil
+}
+
+// MustCompileExpr is like CompileExpr but panics if the expression is invalid.
+// It's for expressions written in Go code, which can't be wrong at runtime.
+func MustCompileExpr(source string) *Expr {
+	expr, err := CompileExpr(source)
+	if err != nil {
+		panic(err)
+	}
+	return expr
+}
+
+func (e *Expr) String() string {
+	return e.source
+}
+
+// Eval computes the value of the expression for an event
+func (e *Expr) Eval(event *Event) (any, error) {
+	return e.root.eval(event)
+}
+
+// Test evaluates the expression as a condition.
+// null, false, zero, and empty strings or lists are false; everything else is true.
+func (e *Expr) Test(event *Event) (bool, error) {
+	value, err := e.root.eval(event)
+	if err != nil {
+		return false, err
+	}
+	return truthy(value), nil
+}
+
+// --- lexer ---
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokRegex
+	tokIdent
+	tokField
+	tokOp
+	tokLBracket
+	tokRBracket
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type exprToken struct {
+	kind tokenKind
+	text string
+	pos  int
+	path []string
+}
+
+// longer operators first, so that >= isn't mistaken for >
+var exprOperators = []string{"==", "!=", "<=", ">=", "=~", "!~", "&&", "||", "<", ">", "!", "+", "-", "*", "/", "%"}
+
+func lexExpr(source string) ([]exprToken, error) {
+	var tokens []exprToken
+	fail := func(pos int, format string, args ...any) error {
+		return fmt.Errorf("%s at column %d in %q", fmt.Sprintf(format, args...), pos+1, source)
+	}
+	afterMatch := func() bool {
+		if len(tokens) == 0 {
+			return false
+		}
+		last := tokens[len(tokens)-1]
+		return last.kind == tokOp && (last.text == "=~" || last.text == "!~")
+	}
+
+	i := 0
+	for i < len(source) {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, exprToken{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, exprToken{kind: tokRParen, text: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, exprToken{kind: tokComma, text: ",", pos: i})
+			i++
+		case c == ']':
+			tokens = append(tokens, exprToken{kind: tokRBracket, text: "]", pos: i})
+			i++
+		case c == '[':
+			if startsList(source[i+1:]) {
+				tokens = append(tokens, exprToken{kind: tokLBracket, text: "[", pos: i})
+				i++
+				continue
+			}
+			// a field reference: [one][or][more][names]
+			start := i
+			for i < len(source) && source[i] == '[' {
+				end := strings.IndexByte(source[i:], ']')
+				if end < 0 {
+					return nil, fail(i, "missing ]")
+				}
+				i += end + 1
+			}
+			path, err := ParseFieldPath(source[start:i])
+			if err != nil {
+				return nil, fail(start, "%s", err)
+			}
+			tokens = append(tokens, exprToken{kind: tokField, text: source[start:i], pos: start, path: path})
+		case c == '"' || c == '\'':
+			text, n, err := lexString(source[i:])
+			if err != nil {
+				return nil, fail(i, "%s", err)
+			}
+			tokens = append(tokens, exprToken{kind: tokString, text: text, pos: i})
+			i += n
+		case c == '/' && afterMatch():
+			// a regular expression literal, with \/ for a literal slash
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(source) && source[j] != '/'; j++ {
+				if source[j] == '\\' && j+1 < len(source) && source[j+1] == '/' {
+					j++
+				}
+				sb.WriteByte(source[j])
+			}
+			if j >= len(source) {
+				return nil, fail(i, "unterminated regular expression")
+			}
+			tokens = append(tokens, exprToken{kind: tokRegex, text: sb.String(), pos: i})
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(source) && (isDigit(source[j]) || source[j] == '.' || source[j] == 'e' || source[j] == 'E' ||
+				((source[j] == '-' || source[j] == '+') && (source[j-1] == 'e' || source[j-1] == 'E'))) {
+				j++
+			}
+			tokens = append(tokens, exprToken{kind: tokNumber, text: source[i:j], pos: i})
+			i = j
+		case isIdentStart(rune(c)):
+			// a dotted field reference can have escapes (eg. a.b\.c), so \ takes the next character with it
+			j := i
+			for j < len(source) && (isIdentPart(rune(source[j])) || source[j] == '\\') {
+				if source[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			j = min(j, len(source))
+			tokens = append(tokens, exprToken{kind: tokIdent, text: source[i:j], pos: i})
+			i = j
+		default:
+			matched := false
+			for _, op := range exprOperators {
+				if strings.HasPrefix(source[i:], op) {
+					tokens = append(tokens, exprToken{kind: tokOp, text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fail(i, "unexpected character %q", c)
+			}
+		}
+	}
+	return append(tokens, exprToken{kind: tokEOF, pos: len(source)}), nil
+}
+
+// a [ starts a list (rather than a field) when it's followed by a value
+func startsList(rest string) bool {
+	rest = strings.TrimLeft(rest, " \t")
+	if rest == "" {
+		return true
+	}
+	switch c := rest[0]; {
+	case c == '"' || c == '\'' || c == ']' || c == '[' || c == '-' || isDigit(c):
+		return true
+	}
+	for _, keyword := range []string{"true", "false", "null"} {
+		if after, found := strings.CutPrefix(rest, keyword); found {
+			after = strings.TrimLeft(after, " \t")
+			if after == "" || after[0] == ',' || after[0] == ']' {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func lexString(s string) (string, int, error) {
+	quote := s[0]
+	var sb strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote:
+			return sb.String(), i + 1, nil
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			default:
+				sb.WriteByte(s[i])
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(r rune) bool {
+	return unicode.IsLetter(r) || r == '_' || r == '@'
+}
+
+func isIdentPart(r rune) bool {
+	return isIdentStart(r) || unicode.IsDigit(r) || r == '.'
+}
+
+// --- parser ---
+
+type exprParser struct {
+	source string
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *exprParser) errorAt(tok exprToken, format string, args ...any) error {
+	if tok.kind == tokEOF {
+		return fmt.Errorf("%s at end of %q", fmt.Sprintf(format, args...), p.source)
+	}
+	return fmt.Errorf("%s at column %d in %q", fmt.Sprintf(format, args...), tok.pos+1, p.source)
+}
+
+func (p *exprParser) isOp(tok exprToken, ops ...string) bool {
+	if tok.kind != tokOp && tok.kind != tokIdent {
+		return false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp(p.peek(), "||", "or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicNode{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp(p.peek(), "&&", "and") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicNode{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if p.isOp(p.peek(), "!", "not") {
+		p.next()
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{inner: inner}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	tok := p.peek()
+	switch {
+	case p.isOp(tok, "==", "!=", "<", "<=", ">", ">="):
+		p.next()
+		right, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		return &compareNode{op: tok.text, left: left, right: right}, nil
+	case p.isOp(tok, "=~", "!~"):
+		p.next()
+		right, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		return newMatchNode(left, right, tok.text == "!~")
+	case p.isOp(tok, "in"):
+		p.next()
+		right, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		return &inNode{left: left, right: right}, nil
+	case p.isOp(tok, "not") && p.isOp(p.tokens[p.pos+1], "in"):
+		p.next()
+		p.next()
+		right, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{inner: &inNode{left: left, right: right}}, nil
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseSum() (exprNode, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp(p.peek(), "+", "-") {
+		op := p.next().text
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseProduct() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp(p.peek(), "*", "/", "%") {
+		op := p.next().text
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.isOp(p.peek(), "-") {
+		p.next()
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &arithNode{op: "-", left: &literalNode{value: 0}, right: inner}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNumber:
+		if i, err := strconv.Atoi(tok.text); err == nil {
+			return &literalNode{value: i}, nil
+		}
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorAt(tok, "bad number %q", tok.text)
+		}
+		return &literalNode{value: f}, nil
+	case tokString:
+		return &literalNode{value: tok.text}, nil
+	case tokRegex:
+		re, err := regexp.Compile(tok.text)
+		if err != nil {
+			return nil, p.errorAt(tok, "bad regular expression: %s", err)
+		}
+		return &literalNode{value: re}, nil
+	case tokField:
+		return &fieldNode{path: tok.path}, nil
+	case tokLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, p.errorAt(closing, "expected )")
+		}
+		return inner, nil
+	case tokLBracket:
+		list := &listNode{}
+		if p.peek().kind == tokRBracket {
+			p.next()
+			return list, nil
+		}
+		for {
+			item, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			list.items = append(list.items, item)
+			sep := p.next()
+			if sep.kind == tokRBracket {
+				return list, nil
+			}
+			if sep.kind != tokComma {
+				return nil, p.errorAt(sep, "expected , or ]")
+			}
+		}
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		}
+		if p.peek().kind == tokLParen {
+			return p.parseCall(tok)
+		}
+		path, err := ParseFieldPath(tok.text)
+		if err != nil {
+			return nil, p.errorAt(tok, "%s", err)
+		}
+		return &fieldNode{path: path}, nil
+	case tokEOF:
+		return nil, p.errorAt(tok, "expected a value")
+	}
+	return nil, p.errorAt(tok, "unexpected %q", tok.text)
+}
+
+func (p *exprParser) parseCall(name exprToken) (exprNode, error) {
+	p.next() // (
+	var args []exprNode
+	if p.peek().kind == tokRParen {
+		p.next()
+	} else {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			sep := p.next()
+			if sep.kind == tokRParen {
+				break
+			}
+			if sep.kind != tokComma {
+				return nil, p.errorAt(sep, "expected , or )")
+			}
+		}
+	}
+
+	if name.text == "exists" {
+		field, isField := firstArg(args).(*fieldNode)
+		if len(args) != 1 || !isField {
+			return nil, p.errorAt(name, "exists() takes a single field")
+		}
+		return &existsNode{path: field.path}, nil
+	}
+	fn, known := exprFunctions[name.text]
+	if !known {
+		return nil, p.errorAt(name, "unknown function %s()", name.text)
+	}
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, p.errorAt(name, "wrong number of arguments to %s()", name.text)
+	}
+	return &callNode{name: name.text, fn: fn.call, args: args}, nil
+}
+
+func firstArg(args []exprNode) exprNode {
+	if len(args) == 0 {
+		return nil
+	}
+	return args[0]
+}
+
+// --- evaluation ---
+
+type exprNode interface {
+	eval(event *Event) (any, error)
+}
+
+type literalNode struct {
+	value any
+}
+
+func (n *literalNode) eval(_ *Event) (any, error) {
+	return n.value, nil
+}
+
+type fieldNode struct {
+	path []string
+}
+
+func (n *fieldNode) eval(event *Event) (any, error) {
+	value, err := event.Field(n.path...).Get()
+	if err != nil {
+		// missing fields are null
+		return nil, nil
+	}
+	return value, nil
+}
+
+type existsNode struct {
+	path []string
+}
+
+func (n *existsNode) eval(event *Event) (any, error) {
+	_, err := event.Field(n.path...).Get()
+	return err == nil, nil
+}
+
+type listNode struct {
+	items []exprNode
+}
+
+func (n *listNode) eval(event *Event) (any, error) {
+	list := make([]any, 0, len(n.items))
+	for _, item := range n.items {
+		value, err := item.eval(event)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+	}
+	return list, nil
+}
+
+type notNode struct {
+	inner exprNode
+}
+
+func (n *notNode) eval(event *Event) (any, error) {
+	value, err := n.inner.eval(event)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(value), nil
+}
+
+type logicNode struct {
+	and   bool
+	left  exprNode
+	right exprNode
+}
+
+func (n *logicNode) eval(event *Event) (any, error) {
+	left, err := n.left.eval(event)
+	if err != nil {
+		return nil, err
+	}
+	// short circuit
+	if truthy(left) != n.and {
+		return !n.and, nil
+	}
+	right, err := n.right.eval(event)
+	if err != nil {
+		return nil, err
+	}
+	return truthy(right), nil
+}
+
+type compareNode struct {
+	op    string
+	left  exprNode
+	right exprNode
+}
+
+func (n *compareNode) eval(event *Event) (any, error) {
+	left, right, err := evalPair(event, n.left, n.right)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return equalValues(left, right), nil
+	case "!=":
+		return !equalValues(left, right), nil
+	}
+	c, comparable := compareValues(left, right)
+	if !comparable {
+		return false, nil
+	}
+	switch n.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	case ">=":
+		return c >= 0, nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", n.op)
+}
+
+type matchNode struct {
+	left   exprNode
+	right  exprNode
+	re     *regexp.Regexp
+	negate bool
+}
+
+// a constant pattern is compiled once, up front
+func newMatchNode(left exprNode, right exprNode, negate bool) (exprNode, error) {
+	n := &matchNode{left: left, right: right, negate: negate}
+	if literal, isLiteral := right.(*literalNode); isLiteral {
+		switch pattern := literal.value.(type) {
+		case *regexp.Regexp:
+			n.re = pattern
+		case string:
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("bad regular expression: %w", err)
+			}
+			n.re = re
+		}
+	}
+	return n, nil
+}
+
+func (n *matchNode) eval(event *Event) (any, error) {
+	left, err := n.left.eval(event)
+	if err != nil {
+		return nil, err
+	}
+	re := n.re
+	if re == nil {
+		pattern, err := n.right.eval(event)
+		if err != nil {
+			return nil, err
+		}
+		re, err = regexp.Compile(toText(pattern))
+		if err != nil {
+			return nil, fmt.Errorf("bad regular expression: %w", err)
+		}
+	}
+	if left == nil {
+		return n.negate, nil
+	}
+	return re.MatchString(toText(left)) != n.negate, nil
+}
+
+type inNode struct {
+	left  exprNode
+	right exprNode
+}
+
+func (n *inNode) eval(event *Event) (any, error) {
+	left, right, err := evalPair(event, n.left, n.right)
+	if err != nil {
+		return nil, err
+	}
+	switch container := right.(type) {
+	case []any:
+		for _, item := range container {
+			if equalValues(left, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case map[string]any:
+		_, exists := container[toText(left)]
+		return exists, nil
+	case string:
+		return left != nil && strings.Contains(container, toText(left)), nil
+	case nil:
+		return false, nil
+	}
+	return nil, fmt.Errorf("cannot look in %T", right)
+}
+
+type arithNode struct {
+	op    string
+	left  exprNode
+	right exprNode
+}
+
+func (n *arithNode) eval(event *Event) (any, error) {
+	left, right, err := evalPair(event, n.left, n.right)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "+" {
+		// + joins strings, if either side is a string
+		_, leftIsText := left.(string)
+		_, rightIsText := right.(string)
+		if leftIsText || rightIsText {
+			return toText(left) + toText(right), nil
+		}
+	}
+	a, aIsInt, aOk := toNumber(left)
+	b, bIsInt, bOk := toNumber(right)
+	if !aOk || !bOk {
+		return nil, fmt.Errorf("cannot %s %T and %T", n.op, left, right)
+	}
+	var result float64
+	switch n.op {
+	case "+":
+		result = a + b
+	case "-":
+		result = a - b
+	case "*":
+		result = a * b
+	case "/":
+		if b == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		result = a / b
+	case "%":
+		if b == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		result = math.Mod(a, b)
+	}
+	// integers stay integers, unless division makes a fraction
+	if aIsInt && bIsInt && result == math.Trunc(result) {
+		return int(result), nil
+	}
+	return result, nil
+}
+
+type callNode struct {
+	name string
+	fn   func(args []any) (any, error)
+	args []exprNode
+}
+
+func (n *callNode) eval(event *Event) (any, error) {
+	args := make([]any, 0, len(n.args))
+	for _, arg := range n.args {
+		value, err := arg.eval(event)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value)
+	}
+	result, err := n.fn(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s(): %w", n.name, err)
+	}
+	return result, nil
+}
+
+func evalPair(event *Event, left exprNode, right exprNode) (any, any, error) {
+	l, err := left.eval(event)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := right.eval(event)
+	if err != nil {
+		return nil, nil, err
+	}
+	return l, r, nil
+}
+
+type exprFunction struct {
+	minArgs int
+	// -1 means any number
+	maxArgs int
+	call    func(args []any) (any, error)
+}
+
+var exprFunctions = map[string]exprFunction{
+	"lower": {1, 1, func(args []any) (any, error) { return strings.ToLower(toText(args[0])), nil }},
+	"upper": {1, 1, func(args []any) (any, error) { return strings.ToUpper(toText(args[0])), nil }},
+	"trim":  {1, 1, func(args []any) (any, error) { return strings.TrimSpace(toText(args[0])), nil }},
+	"len": {1, 1, func(args []any) (any, error) {
+		switch v := args[0].(type) {
+		case nil:
+			return 0, nil
+		case []any:
+			return len(v), nil
+		case map[string]any:
+			return len(v), nil
+		}
+		return len([]rune(toText(args[0]))), nil
+	}},
+	"contains": {2, 2, func(args []any) (any, error) {
+		if list, isList := args[0].([]any); isList {
+			for _, item := range list {
+				if equalValues(item, args[1]) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return args[0] != nil && strings.Contains(toText(args[0]), toText(args[1])), nil
+	}},
+	"startsWith": {2, 2, func(args []any) (any, error) {
+		return args[0] != nil && strings.HasPrefix(toText(args[0]), toText(args[1])), nil
+	}},
+	"endsWith": {2, 2, func(args []any) (any, error) {
+		return args[0] != nil && strings.HasSuffix(toText(args[0]), toText(args[1])), nil
+	}},
+	"replace": {3, 3, func(args []any) (any, error) {
+		return strings.ReplaceAll(toText(args[0]), toText(args[1]), toText(args[2])), nil
+	}},
+	"split": {2, 2, func(args []any) (any, error) {
+		parts := strings.Split(toText(args[0]), toText(args[1]))
+		list := make([]any, len(parts))
+		for i, part := range parts {
+			list[i] = part
+		}
+		return list, nil
+	}},
+	"join": {2, 2, func(args []any) (any, error) {
+		list, isList := args[0].([]any)
+		if !isList {
+			return nil, fmt.Errorf("expected a list but got %T", args[0])
+		}
+		parts := make([]string, len(list))
+		for i, item := range list {
+			parts[i] = toText(item)
+		}
+		return strings.Join(parts, toText(args[1])), nil
+	}},
+	"concat": {0, -1, func(args []any) (any, error) {
+		var sb strings.Builder
+		for _, arg := range args {
+			sb.WriteString(toText(arg))
+		}
+		return sb.String(), nil
+	}},
+	"coalesce": {1, -1, func(args []any) (any, error) {
+		for _, arg := range args {
+			if arg != nil {
+				return arg, nil
+			}
+		}
+		return nil, nil
+	}},
+	"string": {1, 1, func(args []any) (any, error) { return toText(args[0]), nil }},
+	"int": {1, 1, func(args []any) (any, error) {
+		n, _, ok := toNumber(args[0])
+		if !ok {
+			return nil, fmt.Errorf("%q is not a number", toText(args[0]))
+		}
+		return int(n), nil
+	}},
+	"number": {1, 1, func(args []any) (any, error) {
+		n, isInt, ok := toNumber(args[0])
+		if !ok {
+			return nil, fmt.Errorf("%q is not a number", toText(args[0]))
+		}
+		if isInt {
+			return int(n), nil
+		}
+		return n, nil
+	}},
+}
+
+func truthy(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []any:
+		return len(v) > 0
+	}
+	if n, _, isNumber := toNumber(value); isNumber {
+		return n != 0
+	}
+	return true
+}
+
+func toText(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(value)
+}
+
+// toNumber understands every numeric type, and strings that look like numbers
+func toNumber(value any) (n float64, isInt bool, ok bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true, true
+	case int32:
+		return float64(v), true, true
+	case int64:
+		return float64(v), true, true
+	case uint:
+		return float64(v), true, true
+	case uint32:
+		return float64(v), true, true
+	case uint64:
+		return float64(v), true, true
+	case float32:
+		return float64(v), false, true
+	case float64:
+		return v, v == math.Trunc(v), true
+	case string:
+		if i, err := strconv.Atoi(v); err == nil {
+			return float64(i), true, true
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		return f, false, err == nil
+	}
+	return 0, false, false
+}
+
+func isNumeric(value any) bool {
+	switch value.(type) {
+	case int, int32, int64, uint, uint32, uint64, float32, float64:
+		return true
+	}
+	return false
+}
+
+func equalValues(a any, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if c, areTimes := compareTimes(a, b); areTimes {
+		return c == 0
+	}
+	// a number equals a string that spells the same number, since fields often arrive as text
+	if isNumeric(a) || isNumeric(b) {
+		x, _, xOk := toNumber(a)
+		y, _, yOk := toNumber(b)
+		if xOk && yOk {
+			return x == y
+		}
+	}
+	if x, isText := a.(string); isText {
+		y, isText := b.(string)
+		return isText && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// a timestamp can be compared with another, or with a string like "2024-01-02T03:04:05Z"
+func compareTimes(a any, b any) (int, bool) {
+	_, aIsTime := a.(time.Time)
+	_, bIsTime := b.(time.Time)
+	if !aIsTime && !bIsTime {
+		return 0, false
+	}
+	x, xOk := toTime(a)
+	y, yOk := toTime(b)
+	if !xOk || !yOk {
+		return 0, false
+	}
+	return x.Compare(y), true
+}
+
+// severity of each log level name, so that levels can be compared
+var logLevels = map[string]int{
+	"trace":     0,
+	"debug":     1,
+	"info":      2,
+	"notice":    3,
+	"warn":      4,
+	"warning":   4,
+	"err":       5,
+	"error":     5,
+	"crit":      6,
+	"critical":  6,
+	"alert":     7,
+	"emerg":     8,
+	"emergency": 8,
+	"fatal":     8,
+	"panic":     8,
+}
+
+// compareValues orders numbers, log levels and strings; anything else is not comparable
+func compareValues(a any, b any) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	if c, areTimes := compareTimes(a, b); areTimes {
+		return c, true
+	}
+	if isNumeric(a) || isNumeric(b) {
+		x, _, xOk := toNumber(a)
+		y, _, yOk := toNumber(b)
+		if !xOk || !yOk {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	x, xIsText := a.(string)
+	y, yIsText := b.(string)
+	if !xIsText || !yIsText {
+		return 0, false
+	}
+	xLevel, xIsLevel := logLevels[strings.ToLower(x)]
+	yLevel, yIsLevel := logLevels[strings.ToLower(y)]
+	if xIsLevel && yIsLevel {
+		return xLevel - yLevel, true
+	}
+	return strings.Compare(x, y), true
+}
@@ -0,0 +1,139 @@
+package loglang
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExpr_Eval(t *testing.T) {
+	evt := NewEvent()
+	evt.Field("http", "response", "status").SetInt(503)
+	evt.Field("url", "path").SetString("/api/users")
+	evt.Field("host", "name").SetString(" Web-1 ")
+	evt.Field("log", "level").SetString("error")
+	evt.Fields["tags"] = []any{"prod", "eu"}
+	evt.Field("ratio").Set(0.5)
+	evt.Field("labels", "app.kubernetes.io").SetString("api")
+	evt.Field("@timestamp").SetTime(time.Date(2024, time.March, 5, 9, 7, 3, 0, time.UTC))
+
+	tests := []struct {
+		expr     string
+		expected any
+	}{
+		{"[http][response][status]", 503},
+		{"[http][response][status] >= 500 and [url][path] =~ /^\\/api/", true},
+		{"[http][response][status] >= 500 && [url][path] !~ /^\\/api/", false},
+		{"[http][response][status] == 200 or [log][level] == 'error'", true},
+		{"not exists([user][id])", true},
+		{"!exists([url][path])", false},
+		{`log.level in ["warn", "error"]`, true},
+		{`log.level not in ["warn", "error"]`, false},
+		{`"prod" in [tags]`, true},
+		{`"path" in [url]`, true},
+		{`"api" in [url][path]`, true},
+		{`log.level >= "warn"`, true},
+		{"[http][response][status] / 100", 5.03},
+		{"[http][response][status] / 503", 1},
+		{"int([http][response][status] / 100)", 5},
+		{"[http][response][status] % 100 + 1", 4},
+		{"ratio * 3", 1.5},
+		{"-[http][response][status]", -503},
+		{"(1 + 2) * 3", 9},
+		{"7 / 2", 3.5},
+		{`lower(trim([host][name]))`, "web-1"},
+		{`upper("a") + ":" + string([http][response][status])`, "A:503"},
+		{`len([tags])`, 2},
+		{`contains([tags], "eu")`, true},
+		{`startsWith([url][path], "/api")`, true},
+		{`endsWith([url][path], "users")`, true},
+		{`replace([url][path], "/", ".")`, ".api.users"},
+		{`join(split("a,b", ","), "-")`, "a-b"},
+		{`concat("x", 1, true)`, "x1true"},
+		{`coalesce([missing], "default")`, "default"},
+		{`number("42") + 1`, 43},
+		{`[@timestamp] > "2024-01-01T00:00:00Z"`, true},
+		{`[@timestamp] < "2024-01-01T00:00:00Z"`, false},
+		{`labels.app\.kubernetes\.io`, "api"},
+		{`exists(labels.app\.kubernetes\.io) and [labels][app.kubernetes.io] == "api"`, true},
+		{"[missing]", nil},
+		{"[missing] == null", true},
+		{"[missing] > 1", false},
+		{`"503" == [http][response][status]`, true},
+		{`[]`, []any{}},
+		{`[1, "two", [http][response][status]]`, []any{1, "two", 503}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := CompileExpr(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual, err := expr.Eval(&evt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("expected %#v but got %#v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestExpr_Test(t *testing.T) {
+	evt := NewEvent()
+	evt.Field("empty").SetString("")
+	evt.Field("zero").SetInt(0)
+	evt.Field("message").SetString("hello")
+
+	for expr, expected := range map[string]bool{
+		"message": true,
+		"empty":   false,
+		"zero":    false,
+		"missing": false,
+		"[]":      false,
+		"null":    false,
+		"true":    true,
+	} {
+		ok, err := MustCompileExpr(expr).Test(&evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != expected {
+			t.Errorf("%s: expected %t but got %t", expr, expected, ok)
+		}
+	}
+}
+
+func TestExpr_RuntimeErrors(t *testing.T) {
+	evt := NewEvent()
+	evt.Field("message").SetString("hello")
+	for _, expr := range []string{"1 / 0", "[message] - 1", `number("x")`, `"a" in 5`} {
+		if _, err := MustCompileExpr(expr).Eval(&evt); err == nil {
+			t.Errorf("expected %q to fail", expr)
+		}
+	}
+}
+
+func TestCompileExpr_Errors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"1 +",
+		"(1",
+		"[a][b",
+		"[a][]",
+		`"unterminated`,
+		"a =~ /(/",
+		"a =~ /unterminated",
+		"nope(1)",
+		"lower()",
+		`exists("a")`,
+		"1 2",
+		"a # b",
+		`a\`,
+	} {
+		if _, err := CompileExpr(expr); err == nil {
+			t.Errorf("expected %q to be rejected", expr)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // represents exactly one (possibly nested) field
@@ -15,6 +16,98 @@ type Field struct {
 	original *Event
 }
 
+// ParseFieldPath reads a reference to a (possibly nested) field, so fields can be named with one string.
+// Both Logstash-style [http][request][method] and dotted http.request.method work.
+// In the dotted form, \. is a literal dot and \\ a literal backslash;
+// so a key containing a dot can be written as [dotted.key] or dotted\.key.
+// Array elements are numbers: [tags][0] or tags.0
+func ParseFieldPath(ref string) ([]string, error) {
+	if ref == "" {
+		return nil, fmt.Errorf("empty field reference")
+	}
+	if strings.HasPrefix(ref, "[") {
+		var path []string
+		for rest := ref; rest != ""; {
+			if rest[0] != '[' {
+				return nil, fmt.Errorf("bad field reference %q: expected [ before %q", ref, rest)
+			}
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("bad field reference %q: missing ]", ref)
+			}
+			name := rest[1:end]
+			if name == "" || strings.ContainsRune(name, '[') {
+				return nil, fmt.Errorf("bad field reference %q: empty or nested []", ref)
+			}
+			path = append(path, name)
+			rest = rest[end+1:]
+		}
+		return path, nil
+	}
+
+	var path []string
+	var sb strings.Builder
+	for i := 0; i < len(ref); i++ {
+		switch c := ref[i]; c {
+		case '\\':
+			if i+1 == len(ref) {
+				return nil, fmt.Errorf("bad field reference %q: trailing \\", ref)
+			}
+			i++
+			sb.WriteByte(ref[i])
+		case '.':
+			if sb.Len() == 0 {
+				return nil, fmt.Errorf("bad field reference %q: empty name", ref)
+			}
+			path = append(path, sb.String())
+			sb.Reset()
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	if sb.Len() == 0 {
+		return nil, fmt.Errorf("bad field reference %q: empty name", ref)
+	}
+	return append(path, sb.String()), nil
+}
+
+// MustParseFieldPath is like ParseFieldPath, but panics if the reference is invalid.
+// It's for references written in Go code; check references from users with ParseFieldPath.
+func MustParseFieldPath(ref string) []string {
+	path, err := ParseFieldPath(ref)
+	if err != nil {
+		panic(err)
+	}
+	return path
+}
+
+// DottedPath writes a path the way ParseFieldPath reads it, escaping dots within names
+func DottedPath(path []string) string {
+	escaped := make([]string, len(path))
+	for i, name := range path {
+		name = strings.ReplaceAll(name, `\`, `\\`)
+		escaped[i] = strings.ReplaceAll(name, ".", `\.`)
+	}
+	return strings.Join(escaped, ".")
+}
+
+// MetadataField holds fields that are never encoded by codecs (eg. routing hints, or the original bytes).
+// Anything under [@metadata] is kept in Event.Metadata instead of Event.Fields,
+// so it's visible to filters, conditions and outputs, but doesn't reach the wire.
+const MetadataField = "@metadata"
+
+// root finds the map that the path starts in, and the rest of the path within it
+func (fld *Field) root(create bool) (map[string]any, []string) {
+	evt := fld.original
+	if fld.Path[0] != MetadataField {
+		return evt.Fields, fld.Path
+	}
+	if evt.Metadata == nil && create {
+		evt.Metadata = make(map[string]any)
+	}
+	return evt.Metadata, fld.Path[1:]
+}
+
 func (fld *Field) MustGet() any {
 	v, _ := fld.Get()
 	return v
@@ -28,24 +121,109 @@ func (fld *Field) Get() (any, error) {
 		return nil, fmt.Errorf("cannot traverse empty Path")
 	}
 
-	var level map[string]any
-	level = fld.original.Fields
-	for depth, key := range fld.Path {
-		inner, keyExists := level[key]
-		if !keyExists {
-			return nil, fmt.Errorf("tried to Field.Get() on non-existent key")
-		}
-		if depth == len(fld.Path)-1 {
-			return inner, nil
-		}
-		if innerMap, isMap := inner.(map[string]any); isMap {
-			level = innerMap
-		} else {
-			// TODO: write good test cases for this. this is tricky.
+	root, path := fld.root(false)
+	if root == nil && len(path) == 0 {
+		return nil, fmt.Errorf("tried to Field.Get() on non-existent key")
+	}
+	var level any = root
+	for _, key := range path {
+		switch container := level.(type) {
+		case map[string]any:
+			inner, keyExists := container[key]
+			if !keyExists {
+				return nil, fmt.Errorf("tried to Field.Get() on non-existent key")
+			}
+			level = inner
+		case []any:
+			i, err := arrayIndex(container, key)
+			if err != nil {
+				return nil, err
+			}
+			level = container[i]
+		default:
 			return nil, fmt.Errorf("path not present")
 		}
 	}
-	panic("impossible")
+	return level, nil
+}
+
+// arrayIndex reads a path segment as an index into an array; negative indexes count from the end
+func arrayIndex(array []any, key string) (int, error) {
+	i, err := strconv.Atoi(key)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an array index", key)
+	}
+	if i < 0 {
+		i += len(array)
+	}
+	if i < 0 || i >= len(array) {
+		return 0, fmt.Errorf("array index %s out of range (length %d)", key, len(array))
+	}
+	return i, nil
+}
+
+// Index is the field for one element of an array; eg. Field("tags").Index(0)
+func (fld *Field) Index(i int) *Field {
+	path := make([]string, 0, len(fld.Path)+1)
+	path = append(path, fld.Path...)
+	return &Field{
+		Path:     append(path, strconv.Itoa(i)),
+		original: fld.original,
+	}
+}
+
+// Len is the number of elements in an array (or keys in an object), or zero if the field is missing
+func (fld *Field) Len() int {
+	switch v := fld.MustGet().(type) {
+	case []any:
+		return len(v)
+	case map[string]any:
+		return len(v)
+	}
+	return 0
+}
+
+// Append adds values to the end of an array, creating the array if the field doesn't exist yet
+func (fld *Field) Append(values ...any) error {
+	for i, value := range values {
+		normalized, err := normalizeValue(value)
+		if err != nil {
+			return fmt.Errorf("failed Append(); %w", err)
+		}
+		values[i] = normalized
+	}
+	current, err := fld.Get()
+	if err != nil {
+		return fld.set(values, true)
+	}
+	array, isArray := current.([]any)
+	if !isArray {
+		return fmt.Errorf("cannot Append() to %s because it is %T, not an array", fld, current)
+	}
+	return fld.set(append(array, values...), true)
+}
+
+func (fld *Field) GetArray() []any {
+	array, _ := fld.MustGet().([]any)
+	return array
+}
+
+// GetStrings converts each element of an array to a string, like GetString does
+func (fld *Field) GetStrings() []string {
+	array := fld.GetArray()
+	if array == nil {
+		return nil
+	}
+	strs := make([]string, 0, len(array))
+	for i := range array {
+		strs = append(strs, fld.Index(i).GetString())
+	}
+	return strs
+}
+
+func (fld *Field) GetMap() map[string]any {
+	m, _ := fld.MustGet().(map[string]any)
+	return m
 }
 
 func (fld *Field) Default(value any) {
@@ -73,50 +251,141 @@ func (fld *Field) set(value any, overwrite bool) error {
 	if len(fld.Path) == 0 {
 		return fmt.Errorf("cannot traverse empty Path")
 	}
+	value, err := normalizeValue(value)
+	if err != nil {
+		return fmt.Errorf("failed Set(); %w", err)
+	}
 
-	var level map[string]any
-	level = fld.original.Fields
-	for i := 0; i < len(fld.Path)-1; i++ {
-		key := fld.Path[i]
-		inner, keyExists := level[key]
-		if !keyExists {
-			level[key] = make(map[string]any)
-		} else if keyExists {
-			if _, isMap := inner.(map[string]any); isMap {
+	root, path := fld.root(true)
+	if len(path) == 0 {
+		// replacing the whole metadata namespace
+		m, isMap := value.(map[string]any)
+		if !isMap {
+			return fmt.Errorf("failed Set(); %s must be an object", MetadataField)
+		}
+		fld.original.Metadata = m
+		return nil
+	}
+	// warnings name the whole path, so they need to know how much of it was the root
+	skipped := len(fld.Path) - len(path)
+
+	var level any = root
+	for i := 0; i < len(path)-1; i++ {
+		key := path[i]
+		switch container := level.(type) {
+		case map[string]any:
+			inner, keyExists := container[key]
+			switch inner.(type) {
+			case map[string]any, []any:
 				// good
-			} else {
-				// damn
-				slog.Warn(strings.Join(fld.Path[:i+1], ".") + " is getting implicitly overwritten; make sure to delete it first")
-				// do we want to preserve the original Value like this?
-				// level["_"+key] = level[key]
-				level[key] = make(map[string]any)
+			default:
+				if keyExists {
+					// damn
+					slog.Warn(strings.Join(fld.Path[:skipped+i+1], ".") + " is getting implicitly overwritten; make sure to delete it first")
+					// do we want to preserve the original Value like this?
+					// level["_"+key] = level[key]
+				}
+				inner = make(map[string]any)
+				container[key] = inner
 			}
+			level = inner
+		case []any:
+			// arrays don't grow implicitly; use Append()
+			j, err := arrayIndex(container, key)
+			if err != nil {
+				return err
+			}
+			switch container[j].(type) {
+			case map[string]any, []any:
+				// good
+			default:
+				slog.Warn(strings.Join(fld.Path[:skipped+i+1], ".") + " is getting implicitly overwritten; make sure to delete it first")
+				container[j] = make(map[string]any)
+			}
+			level = container[j]
 		}
-		level = level[key].(map[string]any)
 	}
 
-	leafKey := fld.Path[len(fld.Path)-1]
-	if _, exists := level[leafKey]; exists && !overwrite {
-		// being quiet is okay if we explicitly do not want overwrites
-		return nil
+	leafKey := path[len(path)-1]
+	switch container := level.(type) {
+	case map[string]any:
+		if _, exists := container[leafKey]; exists && !overwrite {
+			// being quiet is okay if we explicitly do not want overwrites
+			return nil
+		}
+		container[leafKey] = value
+	case []any:
+		i, err := arrayIndex(container, leafKey)
+		if err != nil {
+			return err
+		}
+		if overwrite {
+			container[i] = value
+		}
 	}
+	return nil
+}
 
-	switch value.(type) {
-	case string:
-		level[leafKey] = value
-	case int:
-		level[leafKey] = value
-	case int64:
-		level[leafKey] = value
-	case float64:
-		level[leafKey] = value
-	case bool:
-		level[leafKey] = value
-	default:
-		return fmt.Errorf("failed Set(); rejected type %v %v", reflect.TypeOf(value), value)
+// normalizeValue checks that a value can be stored in an event
+// slices and maps of other types are converted to []any and map[string]any
+func normalizeValue(value any) (any, error) {
+	switch v := value.(type) {
+	case string, int, int64, float64, bool, time.Time:
+		return value, nil
+	case map[string]any:
+		for k, inner := range v {
+			normalized, err := normalizeElement(inner)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = normalized
+		}
+		return v, nil
+	case []any:
+		for i, inner := range v {
+			normalized, err := normalizeElement(inner)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = normalized
+		}
+		return v, nil
 	}
 
-	return nil
+	// other kinds of slices and maps, like []string
+	rv := reflect.ValueOf(value)
+	switch {
+	case rv.Kind() == reflect.Slice:
+		array := make([]any, rv.Len())
+		for i := range array {
+			normalized, err := normalizeElement(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			array[i] = normalized
+		}
+		return array, nil
+	case rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String:
+		m := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			normalized, err := normalizeElement(iter.Value().Interface())
+			if err != nil {
+				return nil, err
+			}
+			m[iter.Key().String()] = normalized
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("rejected type %v %v", reflect.TypeOf(value), value)
+}
+
+// arrays and objects can contain nulls (eg. from JSON), but fields can't be set to null
+func normalizeElement(value any) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	return normalizeValue(value)
 }
 
 func (fld *Field) SetString(value string) {
@@ -135,6 +404,10 @@ func (fld *Field) SetBool(value bool) {
 	fld.Set(value)
 }
 
+func (fld *Field) SetTime(value time.Time) {
+	fld.Set(value)
+}
+
 func (fld *Field) GetString() string {
 	rawValue, err := fld.Get()
 	if err != nil {
@@ -142,20 +415,32 @@ func (fld *Field) GetString() string {
 	}
 
 	switch v := rawValue.(type) {
+	case nil:
+		return ""
 	case string:
 		return v
 	case int:
 		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case map[string]any, []any:
+		// objects and arrays aren't strings; their leaves might be
+		return ""
 	case float64:
-		// TODO: there's a better way
-		return fmt.Sprintf("%filterChain", v)
+		// whole numbers (eg. from JSON) look like integers
+		return strconv.FormatFloat(v, 'f', -1, 64)
 	case bool:
 		return fmt.Sprintf("%t", v)
 	default:
-		panic("unsupported type")
+		// eg. uint64 from YAML
+		return fmt.Sprint(v)
 	}
 }
 
+// GetInt returns 0 if the field is missing, or isn't a number (eg. an object or array).
+// A timestamp is seconds since the epoch.
 func (fld *Field) GetInt() int {
 	rawValue, err := fld.Get()
 	if err != nil {
@@ -166,15 +451,19 @@ func (fld *Field) GetInt() int {
 	case string:
 		vv, err := strconv.Atoi(v)
 		if err != nil {
-			return vv
-		} else {
 			return 0
 		}
+		return vv
 	case int:
 		return v
+	case int64:
+		return int(v)
 	case float64:
 		// TODO: there's a better way
 		return int(v)
+	case time.Time:
+		// seconds since the epoch
+		return int(v.Unix())
 	case bool:
 		if v == false {
 			return 0
@@ -182,10 +471,13 @@ func (fld *Field) GetInt() int {
 			return 1
 		}
 	default:
-		panic("unsupported type")
+		// filters often read fields that came from outside, so this mustn't panic
+		return 0
 	}
 }
 
+// GetFloat returns 0 if the field is missing, or isn't a number (eg. an object or array).
+// A timestamp is seconds since the epoch.
 func (fld *Field) GetFloat() float64 {
 	rawValue, err := fld.Get()
 	if err != nil {
@@ -196,14 +488,18 @@ func (fld *Field) GetFloat() float64 {
 	case string:
 		vv, err := strconv.ParseFloat(v, 64)
 		if err != nil {
-			return vv
-		} else {
 			return 0.0
 		}
+		return vv
 	case int:
 		return float64(v)
+	case int64:
+		return float64(v)
 	case float64:
 		return v
+	case time.Time:
+		// seconds since the epoch, with the fraction
+		return float64(v.UnixNano()) / 1e9
 	case bool:
 		if v == false {
 			return 0.0
@@ -211,10 +507,11 @@ func (fld *Field) GetFloat() float64 {
 			return 1.0
 		}
 	default:
-		panic("unsupported type")
+		return 0.0
 	}
 }
 
+// GetBool returns false if the field is missing, or isn't a boolean, number or string (eg. an object or array)
 func (fld *Field) GetBool(field string) bool {
 	rawValue, err := fld.Get()
 	if err != nil {
@@ -225,21 +522,29 @@ func (fld *Field) GetBool(field string) bool {
 	case string:
 		bb, err := strconv.ParseBool(v)
 		if err != nil {
-			return bb
-		} else {
 			return false
 		}
+		return bb
 	case int:
 		return v > 0
+	case int64:
+		return v > 0
 	case float64:
 		return v > 0
 	case bool:
 		return v
 	default:
-		panic("unsupported type")
+		return false
 	}
 }
 
+// GetTime understands time.Time, and strings in RFC 3339 or syslog (Mmm dd hh:mm:ss) format.
+// It returns the zero time if the field is missing or isn't a timestamp.
+func (fld *Field) GetTime() time.Time {
+	t, _ := toTime(fld.MustGet())
+	return t
+}
+
 func (fld *Field) Delete() {
 	_ = fld.DeleteCarefully()
 }
@@ -252,26 +557,36 @@ func (fld *Field) DeleteCarefully() error {
 		return fmt.Errorf("cannot traverse empty Path")
 	}
 
-	var level map[string]any
-	level = fld.original.Fields
-	for i := 0; i < len(fld.Path)-1; i++ {
-		key := fld.Path[i]
-		inner, keyExists := level[key]
-		if !keyExists {
-			level[key] = make(map[string]any)
-		} else if keyExists {
-			if _, isMap := inner.(map[string]any); isMap {
-				// good
-			} else {
-				// damn
-				slog.Warn("key is getting overwritten")
-				level[key] = make(map[string]any)
-			}
+	root, path := fld.root(false)
+	switch len(path) {
+	case 0:
+		fld.original.Metadata = nil
+		return nil
+	case 1:
+		delete(root, path[0])
+		return nil
+	}
+	leafKey := path[len(path)-1]
+	parent := Field{Path: fld.Path[:len(fld.Path)-1], original: fld.original}
+	container, err := parent.Get()
+	if err != nil {
+		// already gone
+		return nil
+	}
+	switch c := container.(type) {
+	case map[string]any:
+		delete(c, leafKey)
+	case []any:
+		// removing an element shifts the rest down, like a JSON Patch remove
+		i, err := arrayIndex(c, leafKey)
+		if err != nil {
+			return err
 		}
-		level = level[key].(map[string]any)
+		shorter := make([]any, 0, len(c)-1)
+		shorter = append(shorter, c[:i]...)
+		shorter = append(shorter, c[i+1:]...)
+		return parent.set(shorter, true)
 	}
-
-	delete(level, fld.Path[len(fld.Path)-1])
 	//fld.original.touch(fld.Path[0])
 	return nil
 }
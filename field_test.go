@@ -0,0 +1,225 @@
+package loglang
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestField_SetArraysAndObjects(t *testing.T) {
+	evt := NewEvent()
+	evt.Field("tags").Set([]string{"a", "b"})
+	evt.Field("http", "headers").Set(map[string]any{"accept": []any{"text/html", nil}})
+
+	if actual := evt.Field("tags").GetArray(); !reflect.DeepEqual(actual, []any{"a", "b"}) {
+		t.Errorf("expected []string to become []any but got %#v", actual)
+	}
+	if actual := evt.Field("http", "headers", "accept", "0").GetString(); actual != "text/html" {
+		t.Errorf("expected text/html but got %q", actual)
+	}
+	if err := evt.Field("bad").SetCarefully(struct{}{}); err == nil {
+		t.Error("expected a struct to be rejected")
+	}
+	if err := evt.Field("bad").SetCarefully([]any{struct{}{}}); err == nil {
+		t.Error("expected a struct inside an array to be rejected")
+	}
+}
+
+func TestField_GettersDontPanicOnObjects(t *testing.T) {
+	evt := NewEvent()
+	evt.Field("tags").Set([]string{"a", "b"})
+	evt.Field("user").Set(map[string]any{"id": 1})
+	evt.Field("count").SetString("42")
+
+	for _, name := range []string{"tags", "user"} {
+		if actual := evt.Field(name).GetInt(); actual != 0 {
+			t.Errorf("%s: expected 0 but got %d", name, actual)
+		}
+		if actual := evt.Field(name).GetFloat(); actual != 0 {
+			t.Errorf("%s: expected 0 but got %f", name, actual)
+		}
+		if evt.Field(name).GetBool(name) {
+			t.Errorf("%s: expected false", name)
+		}
+	}
+	if actual := evt.Field("count").GetInt(); actual != 42 {
+		t.Errorf("expected a numeric string to be read but got %d", actual)
+	}
+}
+
+func TestField_GetStringNumbers(t *testing.T) {
+	evt := NewEvent()
+	evt.Fields["pid"] = float64(1234)
+	evt.Fields["ratio"] = 0.25
+	evt.Fields["huge"] = uint64(18446744073709551615)
+
+	for name, expected := range map[string]string{
+		"pid":   "1234",
+		"ratio": "0.25",
+		"huge":  "18446744073709551615",
+	} {
+		if actual := evt.Field(name).GetString(); actual != expected {
+			t.Errorf("%s: expected %q but got %q", name, expected, actual)
+		}
+	}
+}
+
+func TestField_Index(t *testing.T) {
+	evt := NewEvent()
+	evt.Fields["users"] = []any{
+		map[string]any{"name": "alice"},
+		map[string]any{"name": "bob"},
+	}
+
+	if actual := evt.Field("users").Index(1).GetMap()["name"]; actual != "bob" {
+		t.Errorf("expected bob but got %v", actual)
+	}
+	if actual := evt.Field("users", "-1", "name").GetString(); actual != "bob" {
+		t.Errorf("expected a negative index to count from the end but got %q", actual)
+	}
+	if _, err := evt.Field("users", "2").Get(); err == nil {
+		t.Error("expected an index out of range to be missing")
+	}
+	if _, err := evt.Field("users", "name").Get(); err == nil {
+		t.Error("expected a name to be rejected as an array index")
+	}
+
+	// set inside an existing element
+	evt.Field("users", "0", "admin").SetBool(true)
+	if actual := evt.Field("users", "0", "admin").MustGet(); actual != true {
+		t.Errorf("expected true but got %v", actual)
+	}
+	// but arrays don't grow by setting past the end
+	if err := evt.Field("users", "5").SetCarefully("x"); err == nil {
+		t.Error("expected setting past the end of an array to fail")
+	}
+}
+
+func TestField_AppendAndLen(t *testing.T) {
+	evt := NewEvent()
+	if actual := evt.Field("tags").Len(); actual != 0 {
+		t.Errorf("expected a missing field to have length 0 but got %d", actual)
+	}
+	if err := evt.Field("tags").Append("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := evt.Field("tags").Append("b", 3); err != nil {
+		t.Fatal(err)
+	}
+	if actual := evt.Field("tags").Len(); actual != 3 {
+		t.Errorf("expected 3 but got %d", actual)
+	}
+	if actual := evt.Field("tags").GetStrings(); !reflect.DeepEqual(actual, []string{"a", "b", "3"}) {
+		t.Errorf("unexpected %v", actual)
+	}
+
+	evt.Field("message").SetString("hello")
+	if err := evt.Field("message").Append("x"); err == nil {
+		t.Error("expected Append() to a string to fail")
+	}
+}
+
+func TestField_DeleteArrayElement(t *testing.T) {
+	evt := NewEvent()
+	evt.Fields["tags"] = []any{"a", "b", "c"}
+	evt.Field("tags", "1").Delete()
+	if actual := evt.Field("tags").GetArray(); !reflect.DeepEqual(actual, []any{"a", "c"}) {
+		t.Errorf("expected [a c] but got %v", actual)
+	}
+
+	evt.Field("missing", "nested").Delete()
+	if _, exists := evt.Fields["missing"]; exists {
+		t.Error("deleting a missing field should not create anything")
+	}
+}
+
+func TestEvent_TraverseFieldsVisitsArrays(t *testing.T) {
+	evt := NewEvent()
+	evt.Fields["b"] = []any{"x", map[string]any{"y": 1}, []any{true}}
+	evt.Fields["a"] = "first"
+
+	var visited [][]string
+	evt.TraverseFields(func(field Field) {
+		visited = append(visited, field.Path)
+	})
+	expected := [][]string{{"a"}, {"b", "0"}, {"b", "1", "y"}, {"b", "2", "0"}}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Errorf("expected %v but got %v", expected, visited)
+	}
+}
+
+func TestEvent_MergeCopiesArrays(t *testing.T) {
+	template := NewEvent()
+	template.Fields["tags"] = []any{"from-template"}
+
+	evt := NewEvent()
+	evt.Merge(&template, false)
+	if err := evt.Field("tags").Append("mine"); err != nil {
+		t.Fatal(err)
+	}
+	evt.Field("tags", "0").SetString("changed")
+	if actual := template.Field("tags").GetArray(); !reflect.DeepEqual(actual, []any{"from-template"}) {
+		t.Errorf("template was modified through a merged event: %v", actual)
+	}
+}
+
+func TestParseFieldPath(t *testing.T) {
+	tests := []struct {
+		ref      string
+		expected []string
+	}{
+		{"message", []string{"message"}},
+		{"[http][request][method]", []string{"http", "request", "method"}},
+		{"http.request.method", []string{"http", "request", "method"}},
+		{"[tags][0]", []string{"tags", "0"}},
+		{"tags.0", []string{"tags", "0"}},
+		{"[dotted.key][inner]", []string{"dotted.key", "inner"}},
+		{`dotted\.key.inner`, []string{"dotted.key", "inner"}},
+		{`back\\slash`, []string{`back\slash`}},
+		{"@metadata.source", []string{"@metadata", "source"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			actual, err := ParseFieldPath(tt.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("expected %q but got %q", tt.expected, actual)
+			}
+			// and back again
+			reparsed, err := ParseFieldPath(DottedPath(actual))
+			if err != nil || !reflect.DeepEqual(reparsed, actual) {
+				t.Errorf("%s did not survive DottedPath(): %q", tt.ref, reparsed)
+			}
+		})
+	}
+}
+
+func TestParseFieldPath_Errors(t *testing.T) {
+	for _, ref := range []string{"", "[a", "[a]b", "[]", "[a][[b]]", "a..b", ".a", "a.", `a\`} {
+		if _, err := ParseFieldPath(ref); err == nil {
+			t.Errorf("expected %q to be rejected", ref)
+		}
+	}
+}
+
+func TestEvent_Ref(t *testing.T) {
+	evt := NewEvent()
+	evt.Ref("[http][request][method]").SetString("GET")
+	if actual := evt.Field("http", "request", "method").GetString(); actual != "GET" {
+		t.Errorf("expected GET but got %q", actual)
+	}
+	if actual := evt.Ref("http.request.method").MustGet(); actual != "GET" {
+		t.Errorf("expected GET but got %v", actual)
+	}
+	// a reference that can't be parsed is a literal key
+	evt.Ref("a..b").SetInt(1)
+	if actual := evt.Fields["a..b"]; actual != 1 {
+		t.Errorf("expected a literal key but got %v", evt.Fields)
+	}
+	// Set and Get don't parse references
+	evt.Set("user.name", "alice")
+	if actual := evt.Fields["user.name"]; actual != "alice" || evt.Get("user.name") != "alice" {
+		t.Errorf("expected a literal key but got %v", evt.Fields)
+	}
+}
@@ -0,0 +1,96 @@
+package filter
+
+import (
+	"fmt"
+
+	"github.com/nicwaller/loglang"
+)
+
+// These filters use expressions (see loglang.Expr) so they can be written without Go.
+// They panic if the expression (or field reference) is invalid; use loglang.CompileExpr first to check untrusted input.
+
+// If runs one filter when the condition is true, and the other (which may be nil) otherwise.
+// An expression that fails to evaluate is an error, and neither filter runs.
+func If(condition string, then loglang.FilterPlugin, otherwise loglang.FilterPlugin) loglang.FilterPlugin {
+	return ifExpr(loglang.MustCompileExpr(condition), then, otherwise)
+}
+
+func ifExpr(expr *loglang.Expr, then loglang.FilterPlugin, otherwise loglang.FilterPlugin) loglang.FilterPlugin {
+	return func(event *loglang.Event, inject chan<- *loglang.Event, drop func()) error {
+		ok, err := expr.Test(event)
+		if err != nil {
+			return fmt.Errorf("if %s: %w", expr, err)
+		}
+		switch {
+		case ok && then != nil:
+			return then(event, inject, drop)
+		case !ok && otherwise != nil:
+			return otherwise(event, inject, drop)
+		}
+		return nil
+	}
+}
+
+// DropIf drops events that match the condition
+func DropIf(condition string) loglang.FilterPlugin {
+	return dropIfExpr(loglang.MustCompileExpr(condition))
+}
+
+func dropIfExpr(expr *loglang.Expr) loglang.FilterPlugin {
+	return func(event *loglang.Event, inject chan<- *loglang.Event, drop func()) error {
+		ok, err := expr.Test(event)
+		if err != nil {
+			return fmt.Errorf("drop if %s: %w", expr, err)
+		}
+		if ok {
+			drop()
+		}
+		return nil
+	}
+}
+
+// Set a field to a computed value; eg. Set("[http][status_class]", "int([http][response][status] / 100)")
+// The field is removed when the value is null.
+func Set(field string, value string) loglang.FilterPlugin {
+	return setExpr(field, loglang.MustCompileExpr(value))
+}
+
+func setExpr(field string, expr *loglang.Expr) loglang.FilterPlugin {
+	path := loglang.MustParseFieldPath(field)
+	return func(event *loglang.Event, inject chan<- *loglang.Event, drop func()) error {
+		result, err := expr.Eval(event)
+		if err != nil {
+			return fmt.Errorf("set %s: %w", field, err)
+		}
+		if result == nil {
+			event.Field(path...).Delete()
+			return nil
+		}
+		if err := event.Field(path...).SetCarefully(result); err != nil {
+			return fmt.Errorf("set %s: %w", field, err)
+		}
+		return nil
+	}
+}
+
+// Chain runs several filters in order, like a pipeline would, stopping if one drops the event
+func Chain(filters ...loglang.FilterPlugin) loglang.FilterPlugin {
+	return func(event *loglang.Event, inject chan<- *loglang.Event, drop func()) error {
+		dropped := false
+		dropOnce := func() {
+			if !dropped {
+				dropped = true
+				drop()
+			}
+		}
+		for _, f := range filters {
+			if err := f(event, inject, dropOnce); err != nil {
+				return err
+			}
+			if dropped {
+				break
+			}
+		}
+		return nil
+	}
+}
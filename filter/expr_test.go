@@ -0,0 +1,133 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/nicwaller/loglang"
+	"gopkg.in/yaml.v3"
+)
+
+func TestIf(t *testing.T) {
+	filter := If("[http][status] >= 500", Replace("severity", "bad"), Replace("severity", "good"))
+	noDrop := func() {
+		t.Error("should not drop event")
+	}
+
+	for status, expected := range map[int]string{200: "good", 503: "bad"} {
+		evt := loglang.NewEvent()
+		evt.Field("http", "status").SetInt(status)
+		if err := filter(&evt, nil, noDrop); err != nil {
+			t.Fatal(err)
+		}
+		if actual := evt.Field("severity").GetString(); actual != expected {
+			t.Errorf("status %d: expected %q but got %q", status, expected, actual)
+		}
+	}
+}
+
+func TestDropIf(t *testing.T) {
+	filter := DropIf(`[url][path] =~ /^\/health/ or [level] == "debug"`)
+
+	for path, expected := range map[string]bool{"/healthz": true, "/api": false} {
+		evt := loglang.NewEvent()
+		evt.Field("url", "path").SetString(path)
+		dropped := false
+		if err := filter(&evt, nil, func() { dropped = true }); err != nil {
+			t.Fatal(err)
+		}
+		if dropped != expected {
+			t.Errorf("%s: expected dropped=%t", path, expected)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	evt := loglang.NewEvent()
+	evt.Field("http", "status").SetInt(404)
+	evt.Field("stale").SetString("x")
+
+	chain := Chain(
+		Set("status_class", "int([http][status] / 100)"),
+		Set("stale", "[missing]"),
+	)
+	if err := chain(&evt, nil, func() {}); err != nil {
+		t.Fatal(err)
+	}
+	if actual := evt.Field("status_class").GetInt(); actual != 4 {
+		t.Errorf("expected 4 but got %d", actual)
+	}
+	if _, err := evt.Field("stale").Get(); err == nil {
+		t.Error("expected a null value to remove the field")
+	}
+}
+
+func TestChain_StopsWhenDropped(t *testing.T) {
+	evt := loglang.NewEvent()
+	drops := 0
+	chain := Chain(DropIf("true"), Replace("after", "ran"), DropIf("true"))
+	if err := chain(&evt, nil, func() { drops++ }); err != nil {
+		t.Fatal(err)
+	}
+	if drops != 1 {
+		t.Errorf("expected 1 drop but got %d", drops)
+	}
+	if _, err := evt.Field("after").Get(); err == nil {
+		t.Error("filters after a drop should not run")
+	}
+}
+
+func TestIf_Config(t *testing.T) {
+	var spec loglang.PluginSpec
+	err := yaml.Unmarshal([]byte(`
+type: if
+options:
+  condition: '[level] == "debug"'
+  then:
+    - type: set
+      options: {field: verbose, value: "true"}
+  else:
+    - type: drop_if
+      options: {condition: "not exists([message])"}
+`), &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter, err := loglang.BuildFilter(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evt := loglang.NewEvent()
+	evt.Field("level").SetString("debug")
+	if err := filter(&evt, nil, func() { t.Error("should not drop event") }); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := evt.Field("verbose").Get(); v != true {
+		t.Errorf("expected verbose=true but got %v", v)
+	}
+
+	dropped := false
+	empty := loglang.NewEvent()
+	if err := filter(&empty, nil, func() { dropped = true }); err != nil {
+		t.Fatal(err)
+	}
+	if !dropped {
+		t.Error("expected the else branch to drop the event")
+	}
+}
+
+func TestIf_ConfigErrors(t *testing.T) {
+	for _, options := range []string{
+		`{condition: "[a] =="}`,
+		`{condition: "[a]", then: [{type: set, options: {field: x, value: "lower("}}]}`,
+		`{condition: "[a]", else: [{type: nope}]}`,
+	} {
+		var spec loglang.PluginSpec
+		if err := yaml.Unmarshal([]byte("type: if\noptions: "+options), &spec); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loglang.BuildFilter(spec); err == nil {
+			t.Errorf("expected %s to be rejected", options)
+		}
+	}
+}
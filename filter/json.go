@@ -10,8 +10,9 @@ import (
 
 // JSON filter doesn't make sense...?
 func Json(name string, sourceField string) loglang.FilterPlugin {
+	sourcePath := loglang.MustParseFieldPath(sourceField)
 	return func(event *loglang.Event, inject chan<- *loglang.Event, drop func()) error {
-		source := event.Field(sourceField).GetString()
+		source := event.Field(sourcePath...).GetString()
 		if !strings.HasPrefix(source, "{") ||
 			!strings.HasSuffix(source, "}") {
 			return fmt.Errorf("dropped event: field [%s] doesn't look like JSON", sourceField)
@@ -4,28 +4,43 @@ import (
 	"github.com/nicwaller/loglang"
 )
 
+// Fields are named by references like [http][request][method] or http.request.method (see loglang.ParseFieldPath).
+// These filters panic if a reference is invalid.
+
 // Replace the value of a field with a new value, or add the field if it doesn’t already exist.
 func Replace(field string, content string) loglang.FilterPlugin {
+	path := loglang.MustParseFieldPath(field)
 	return func(event *loglang.Event, inject chan<- *loglang.Event, drop func()) error {
-		event.Field(field).SetString(content)
+		event.Field(path...).SetString(content)
 		return nil
 	}
 }
 
 func Remove(field string) loglang.FilterPlugin {
+	path := loglang.MustParseFieldPath(field)
 	return func(event *loglang.Event, inject chan<- *loglang.Event, drop func()) error {
-		event.Field(field).Delete()
+		event.Field(path...).Delete()
 		return nil
 	}
 }
 
-// FIXME: rename doesn't support deep fields
+// Rename moves a field (and everything nested inside it); nothing happens if the field doesn't exist
 func Rename(oldField string, newField string) loglang.FilterPlugin {
+	oldPath := loglang.MustParseFieldPath(oldField)
+	newPath := loglang.MustParseFieldPath(newField)
 	return func(event *loglang.Event, inject chan<- *loglang.Event, drop func()) error {
-		oldF := event.Field(oldField)
-		newF := event.Field(newField)
-		newF.Set(oldF.MustGet())
+		oldF := event.Field(oldPath...)
+		value, err := oldF.Get()
+		if err != nil {
+			return nil
+		}
+		// delete first, in case the new field is nested inside the old one
 		oldF.Delete()
+		if err := event.Field(newPath...).SetCarefully(value); err != nil {
+			// put it back, so a bad rename doesn't lose the value
+			_ = oldF.SetCarefully(value)
+			return err
+		}
 		return nil
 	}
 }
@@ -0,0 +1,84 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/nicwaller/loglang"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRename_DeepFields(t *testing.T) {
+	evt := loglang.NewEvent()
+	evt.Field("http", "status").SetInt(200)
+	evt.Field("dotted.key").SetString("kept")
+
+	filters := Chain(
+		Rename("[http][status]", "http.response.status_code"),
+		Rename(`dotted\.key`, "[labels][dotted.key]"),
+		Rename("missing", "elsewhere"),
+	)
+	if err := filters(&evt, nil, func() {}); err != nil {
+		t.Fatal(err)
+	}
+	if actual := evt.Field("http", "response", "status_code").GetInt(); actual != 200 {
+		t.Errorf("expected 200 but got %d", actual)
+	}
+	if _, err := evt.Field("http", "status").Get(); err == nil {
+		t.Error("expected the old field to be gone")
+	}
+	if actual := evt.Field("labels", "dotted.key").GetString(); actual != "kept" {
+		t.Errorf("expected kept but got %q", actual)
+	}
+	if _, err := evt.Field("elsewhere").Get(); err == nil {
+		t.Error("renaming a missing field should do nothing")
+	}
+}
+
+func TestRename_KeepsValueOnFailure(t *testing.T) {
+	evt := loglang.NewEvent()
+	evt.Field("user").SetString("alice")
+	evt.Field("tags").Set([]any{"a"})
+
+	// arrays don't grow implicitly, so there's nowhere to put it
+	if err := Rename("user", "[tags][5]")(&evt, nil, func() {}); err == nil {
+		t.Error("expected the rename to fail")
+	}
+	if actual := evt.Field("user").GetString(); actual != "alice" {
+		t.Errorf("expected the old field to be kept but got %q", actual)
+	}
+}
+
+func TestReplaceAndRemove_DeepFields(t *testing.T) {
+	evt := loglang.NewEvent()
+	evt.Fields["tags"] = []any{"a", "b"}
+	filters := Chain(
+		Replace("[service][name]", "api"),
+		Remove("tags.0"),
+	)
+	if err := filters(&evt, nil, func() {}); err != nil {
+		t.Fatal(err)
+	}
+	if actual := evt.Field("service", "name").GetString(); actual != "api" {
+		t.Errorf("expected api but got %q", actual)
+	}
+	if actual := evt.Field("tags").GetStrings(); len(actual) != 1 || actual[0] != "b" {
+		t.Errorf("expected [b] but got %v", actual)
+	}
+}
+
+func TestMutate_ConfigRejectsBadFields(t *testing.T) {
+	for _, options := range []string{
+		"type: rename\noptions: {from: '[a', to: b}",
+		"type: remove\noptions: {field: 'a..b'}",
+		"type: replace\noptions: {field: '[]', content: x}",
+		"type: set\noptions: {field: 'a.', value: '1'}",
+	} {
+		var spec loglang.PluginSpec
+		if err := yaml.Unmarshal([]byte(options), &spec); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loglang.BuildFilter(spec); err == nil {
+			t.Errorf("expected %q to be rejected", options)
+		}
+	}
+}
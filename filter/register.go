@@ -0,0 +1,159 @@
+package filter
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nicwaller/loglang"
+)
+
+type FieldOptions struct {
+	Field string `yaml:"field"`
+}
+
+type ReplaceOptions struct {
+	Field   string `yaml:"field"`
+	Content string `yaml:"content"`
+}
+
+type RenameOptions struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+type IfOptions struct {
+	Condition string               `yaml:"condition"`
+	Then      []loglang.PluginSpec `yaml:"then"`
+	Else      []loglang.PluginSpec `yaml:"else"`
+}
+
+type ConditionOptions struct {
+	Condition string `yaml:"condition"`
+}
+
+type SetOptions struct {
+	Field string `yaml:"field"`
+	Value string `yaml:"value"`
+}
+
+type ScriptOptions struct {
+	// Source is the script itself, or File is where to read it from
+	Source  string        `yaml:"source"`
+	File    string        `yaml:"file"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+func init() {
+	loglang.RegisterFilter("json", func(opts FieldOptions) (loglang.FilterPlugin, error) {
+		if err := checkField(opts.Field); err != nil {
+			return nil, err
+		}
+		return Json("json", opts.Field), nil
+	})
+	loglang.RegisterFilter("remove", func(opts FieldOptions) (loglang.FilterPlugin, error) {
+		if err := checkField(opts.Field); err != nil {
+			return nil, err
+		}
+		return Remove(opts.Field), nil
+	})
+	loglang.RegisterFilter("replace", func(opts ReplaceOptions) (loglang.FilterPlugin, error) {
+		if err := checkField(opts.Field); err != nil {
+			return nil, err
+		}
+		return Replace(opts.Field, opts.Content), nil
+	})
+	loglang.RegisterFilter("rename", func(opts RenameOptions) (loglang.FilterPlugin, error) {
+		if opts.From == "" || opts.To == "" {
+			return nil, fmt.Errorf("from and to are required")
+		}
+		if err := checkField(opts.From); err != nil {
+			return nil, fmt.Errorf("from: %w", err)
+		}
+		if err := checkField(opts.To); err != nil {
+			return nil, fmt.Errorf("to: %w", err)
+		}
+		return Rename(opts.From, opts.To), nil
+	})
+	loglang.RegisterFilter("if", func(opts IfOptions) (loglang.FilterPlugin, error) {
+		if opts.Condition == "" {
+			return nil, fmt.Errorf("condition is required")
+		}
+		expr, err := loglang.CompileExpr(opts.Condition)
+		if err != nil {
+			return nil, err
+		}
+		then, err := buildChain("then", opts.Then)
+		if err != nil {
+			return nil, err
+		}
+		otherwise, err := buildChain("else", opts.Else)
+		if err != nil {
+			return nil, err
+		}
+		return ifExpr(expr, then, otherwise), nil
+	})
+	loglang.RegisterFilter("drop_if", func(opts ConditionOptions) (loglang.FilterPlugin, error) {
+		if opts.Condition == "" {
+			return nil, fmt.Errorf("condition is required")
+		}
+		expr, err := loglang.CompileExpr(opts.Condition)
+		if err != nil {
+			return nil, err
+		}
+		return dropIfExpr(expr), nil
+	})
+	loglang.RegisterFilter("set", func(opts SetOptions) (loglang.FilterPlugin, error) {
+		if opts.Field == "" || opts.Value == "" {
+			return nil, fmt.Errorf("field and value are required")
+		}
+		if err := checkField(opts.Field); err != nil {
+			return nil, err
+		}
+		expr, err := loglang.CompileExpr(opts.Value)
+		if err != nil {
+			return nil, err
+		}
+		return setExpr(opts.Field, expr), nil
+	})
+	loglang.RegisterFilter("script", func(opts ScriptOptions) (loglang.FilterPlugin, error) {
+		name, source := "script", opts.Source
+		switch {
+		case opts.Source != "" && opts.File != "":
+			return nil, fmt.Errorf("source and file can't both be used")
+		case opts.File != "":
+			dat, err := os.ReadFile(opts.File)
+			if err != nil {
+				return nil, err
+			}
+			name, source = opts.File, string(dat)
+		case opts.Source == "":
+			return nil, fmt.Errorf("source or file is required")
+		}
+		return Script(name, source, opts.Timeout)
+	})
+}
+
+// checkField rejects a missing or malformed field reference, before a filter would panic on it
+func checkField(ref string) error {
+	if ref == "" {
+		return fmt.Errorf("field is required")
+	}
+	_, err := loglang.ParseFieldPath(ref)
+	return err
+}
+
+func buildChain(key string, specs []loglang.PluginSpec) (loglang.FilterPlugin, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	filters := make([]loglang.FilterPlugin, 0, len(specs))
+	for i, spec := range specs {
+		f, err := loglang.BuildFilter(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d]: %w", key, i, err)
+		}
+		filters = append(filters, f)
+	}
+	return Chain(filters...), nil
+}
@@ -0,0 +1,350 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/nicwaller/loglang"
+	starlarktime "go.starlark.net/lib/time"
+	"go.starlark.net/starlark"
+)
+
+// Script runs a Starlark script for each event, for one-off transformations that don't deserve Go.
+// The script must define process(event), which is called once per event:
+//
+//	def process(event):
+//	    if event.get("[http][status]") == 404:
+//	        drop()
+//	    event.set("service", "name", event.get("host").upper())
+//
+// The event has get(field), set(field, value), delete(field), has(field) and copy().
+// A field is one reference like "[http][status]" or "http.status", or several names like "http", "status".
+// drop() drops the event, and inject(event or dict) sends a new event down the pipeline.
+// Timestamps (eg. @timestamp) are time values, which the time module can make and format.
+// Starlark is sandboxed: scripts can't read files, use the network, or load other modules.
+// A script that runs longer than the timeout for one event is cancelled, and the event fails the filter.
+func Script(name string, source string, timeout time.Duration) (loglang.FilterPlugin, error) {
+	if timeout <= 0 {
+		timeout = defaultScriptTimeout
+	}
+	log := slog.Default().With("filter", name)
+
+	// run the top level once, to define process() and anything else it needs
+	thread := &starlark.Thread{Name: name, Print: scriptPrinter(log)}
+	globals, err := starlark.ExecFile(thread, name, source, scriptBuiltins)
+	if err != nil {
+		return nil, fmt.Errorf("script %s: %w", name, describeScriptError(err))
+	}
+	process, isFunc := globals["process"].(*starlark.Function)
+	if !isFunc {
+		return nil, fmt.Errorf("script %s must define process(event)", name)
+	}
+	if process.NumParams() != 1 {
+		return nil, fmt.Errorf("script %s: process() must take exactly one argument", name)
+	}
+	// frozen globals can't be changed by process(), so events can't affect each other
+	globals.Freeze()
+
+	return func(event *loglang.Event, inject chan<- *loglang.Event, drop func()) error {
+		thread := &starlark.Thread{Name: name, Print: scriptPrinter(log)}
+		thread.SetLocal(scriptCallKey, &scriptCall{inject: inject, drop: drop})
+		timer := time.AfterFunc(timeout, func() {
+			thread.Cancel(fmt.Sprintf("timed out after %s", timeout))
+		})
+		defer timer.Stop()
+		if _, err := starlark.Call(thread, process, starlark.Tuple{&scriptEvent{event: event}}, nil); err != nil {
+			return fmt.Errorf("script %s: %w", name, describeScriptError(err))
+		}
+		return nil
+	}, nil
+}
+
+const defaultScriptTimeout = 100 * time.Millisecond
+
+const scriptCallKey = "call"
+
+// scriptCall holds what the drop() and inject() builtins need while process() is running
+type scriptCall struct {
+	inject  chan<- *loglang.Event
+	drop    func()
+	dropped bool
+}
+
+var scriptBuiltins = starlark.StringDict{
+	"time": starlarktime.Module,
+	"drop": starlark.NewBuiltin("drop", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 0); err != nil {
+			return nil, err
+		}
+		call, err := currentCall(thread, fn)
+		if err != nil {
+			return nil, err
+		}
+		if !call.dropped {
+			call.dropped = true
+			call.drop()
+		}
+		return starlark.None, nil
+	}),
+	"inject": starlark.NewBuiltin("inject", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var value starlark.Value
+		if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &value); err != nil {
+			return nil, err
+		}
+		call, err := currentCall(thread, fn)
+		if err != nil {
+			return nil, err
+		}
+		var injected loglang.Event
+		switch v := value.(type) {
+		case *scriptEvent:
+			injected = v.event.Copy()
+		case *starlark.Dict:
+			fields, err := fromStarlark(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", fn.Name(), err)
+			}
+			injected = loglang.NewEvent()
+			for k, field := range fields.(map[string]any) {
+				injected.Fields[k] = field
+			}
+		default:
+			return nil, fmt.Errorf("%s: expected an event or dict but got %s", fn.Name(), value.Type())
+		}
+		call.inject <- &injected
+		return starlark.None, nil
+	}),
+}
+
+func currentCall(thread *starlark.Thread, fn *starlark.Builtin) (*scriptCall, error) {
+	call, isCall := thread.Local(scriptCallKey).(*scriptCall)
+	if !isCall {
+		return nil, fmt.Errorf("%s() can only be called from process()", fn.Name())
+	}
+	return call, nil
+}
+
+func scriptPrinter(log *slog.Logger) func(*starlark.Thread, string) {
+	return func(_ *starlark.Thread, msg string) {
+		log.Info(msg)
+	}
+}
+
+// describeScriptError includes the Starlark backtrace, which says where in the script things went wrong
+func describeScriptError(err error) error {
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		return errors.New(evalErr.Backtrace())
+	}
+	return err
+}
+
+// scriptEvent is how scripts see an event
+type scriptEvent struct {
+	event *loglang.Event
+}
+
+var _ starlark.HasAttrs = (*scriptEvent)(nil)
+
+var scriptEventMethods = map[string]func(e *scriptEvent, fn *starlark.Builtin, args starlark.Tuple) (starlark.Value, error){
+	"get": func(e *scriptEvent, fn *starlark.Builtin, args starlark.Tuple) (starlark.Value, error) {
+		path, err := scriptPath(fn, args)
+		if err != nil {
+			return nil, err
+		}
+		value, err := e.event.Field(path...).Get()
+		if err != nil {
+			return starlark.None, nil
+		}
+		return toStarlark(value), nil
+	},
+	"has": func(e *scriptEvent, fn *starlark.Builtin, args starlark.Tuple) (starlark.Value, error) {
+		path, err := scriptPath(fn, args)
+		if err != nil {
+			return nil, err
+		}
+		_, err = e.event.Field(path...).Get()
+		return starlark.Bool(err == nil), nil
+	},
+	"set": func(e *scriptEvent, fn *starlark.Builtin, args starlark.Tuple) (starlark.Value, error) {
+		if len(args) < 2 {
+			return nil, fmt.Errorf("%s: expected a field and a value", fn.Name())
+		}
+		path, err := scriptPath(fn, args[:len(args)-1])
+		if err != nil {
+			return nil, err
+		}
+		value, err := fromStarlark(args[len(args)-1])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fn.Name(), err)
+		}
+		if value == nil {
+			e.event.Field(path...).Delete()
+			return starlark.None, nil
+		}
+		if err := e.event.Field(path...).SetCarefully(value); err != nil {
+			return nil, fmt.Errorf("%s: %w", fn.Name(), err)
+		}
+		return starlark.None, nil
+	},
+	"delete": func(e *scriptEvent, fn *starlark.Builtin, args starlark.Tuple) (starlark.Value, error) {
+		path, err := scriptPath(fn, args)
+		if err != nil {
+			return nil, err
+		}
+		e.event.Field(path...).Delete()
+		return starlark.None, nil
+	},
+	"copy": func(e *scriptEvent, fn *starlark.Builtin, args starlark.Tuple) (starlark.Value, error) {
+		if len(args) > 0 {
+			return nil, fmt.Errorf("%s: takes no arguments", fn.Name())
+		}
+		dup := e.event.Copy()
+		return &scriptEvent{event: &dup}, nil
+	},
+}
+
+func scriptPath(fn *starlark.Builtin, args starlark.Tuple) ([]string, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s: expected a field", fn.Name())
+	}
+	path := make([]string, 0, len(args))
+	for _, arg := range args {
+		name, isString := starlark.AsString(arg)
+		if !isString {
+			return nil, fmt.Errorf("%s: field names must be strings, not %s", fn.Name(), arg.Type())
+		}
+		path = append(path, name)
+	}
+	// one name is a reference, like "[http][status]" or "http.status"; several names are taken literally
+	if len(path) == 1 {
+		parsed, err := loglang.ParseFieldPath(path[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fn.Name(), err)
+		}
+		return parsed, nil
+	}
+	return path, nil
+}
+
+func (e *scriptEvent) String() string        { return fmt.Sprintf("<event %v>", e.event.Fields) }
+func (e *scriptEvent) Type() string          { return "event" }
+func (e *scriptEvent) Freeze()               {}
+func (e *scriptEvent) Truth() starlark.Bool  { return starlark.True }
+func (e *scriptEvent) Hash() (uint32, error) { return 0, fmt.Errorf("unhashable type: event") }
+
+func (e *scriptEvent) Attr(name string) (starlark.Value, error) {
+	method, exists := scriptEventMethods[name]
+	if !exists {
+		return nil, nil
+	}
+	return starlark.NewBuiltin(name, func(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if len(kwargs) > 0 {
+			return nil, fmt.Errorf("%s: unexpected keyword arguments", fn.Name())
+		}
+		return method(e, fn, args)
+	}), nil
+}
+
+func (e *scriptEvent) AttrNames() []string {
+	names := make([]string, 0, len(scriptEventMethods))
+	for name := range scriptEventMethods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func toStarlark(value any) starlark.Value {
+	switch v := value.(type) {
+	case nil:
+		return starlark.None
+	case string:
+		return starlark.String(v)
+	case bool:
+		return starlark.Bool(v)
+	case int:
+		return starlark.MakeInt(v)
+	case int64:
+		return starlark.MakeInt64(v)
+	case float64:
+		return starlark.Float(v)
+	case time.Time:
+		// so setting it back keeps it a time, instead of a string
+		return starlarktime.Time(v)
+	case []any:
+		list := make([]starlark.Value, 0, len(v))
+		for _, item := range v {
+			list = append(list, toStarlark(item))
+		}
+		return starlark.NewList(list)
+	case map[string]any:
+		dict := starlark.NewDict(len(v))
+		for k, item := range v {
+			_ = dict.SetKey(starlark.String(k), toStarlark(item))
+		}
+		return dict
+	}
+	return starlark.String(fmt.Sprint(value))
+}
+
+func fromStarlark(value starlark.Value) (any, error) {
+	switch v := value.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.String:
+		return string(v), nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.Int:
+		i, ok := v.Int64()
+		if !ok {
+			return nil, fmt.Errorf("integer %s is too big", v)
+		}
+		return int(i), nil
+	case starlark.Float:
+		return float64(v), nil
+	case starlarktime.Time:
+		return time.Time(v), nil
+	case *starlark.List:
+		list := make([]any, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			item, err := fromStarlark(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, nil
+	case starlark.Tuple:
+		list := make([]any, 0, len(v))
+		for _, item := range v {
+			converted, err := fromStarlark(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, converted)
+		}
+		return list, nil
+	case *starlark.Dict:
+		fields := make(map[string]any, v.Len())
+		for _, item := range v.Items() {
+			key, isString := starlark.AsString(item[0])
+			if !isString {
+				return nil, fmt.Errorf("field names must be strings, not %s", item[0].Type())
+			}
+			converted, err := fromStarlark(item[1])
+			if err != nil {
+				return nil, err
+			}
+			fields[key] = converted
+		}
+		return fields, nil
+	case *scriptEvent:
+		return nil, fmt.Errorf("cannot store an event in a field")
+	}
+	return nil, fmt.Errorf("cannot store %s in a field", value.Type())
+}
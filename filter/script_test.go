@@ -0,0 +1,152 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nicwaller/loglang"
+)
+
+func TestScript(t *testing.T) {
+	filter, err := Script("test", `
+def process(event):
+    status = event.get("http", "status")
+    if status == 404:
+        drop()
+        return
+    event.set("service", "name", event.get("host").upper())
+    event.set("http", "class", status // 100)
+    event.delete("host")
+    if event.has("alert"):
+        inject({"message": "alert from " + event.get("service", "name")})
+`, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	injector := make(chan *loglang.Event, 1)
+	evt := loglang.NewEvent()
+	evt.Field("http", "status").SetInt(503)
+	evt.Field("host").SetString("web-1")
+	evt.Field("alert").SetBool(true)
+	if err := filter(&evt, injector, func() { t.Error("should not drop event") }); err != nil {
+		t.Fatal(err)
+	}
+	if actual := evt.Field("service", "name").GetString(); actual != "WEB-1" {
+		t.Errorf("expected WEB-1 but got %q", actual)
+	}
+	if actual := evt.Field("http", "class").GetInt(); actual != 5 {
+		t.Errorf("expected 5 but got %d", actual)
+	}
+	if _, err := evt.Field("host").Get(); err == nil {
+		t.Error("expected host to be deleted")
+	}
+	select {
+	case injected := <-injector:
+		if actual := injected.Field("message").GetString(); actual != "alert from WEB-1" {
+			t.Errorf("unexpected injected message %q", actual)
+		}
+	default:
+		t.Error("expected an injected event")
+	}
+
+	dropped := false
+	notFound := loglang.NewEvent()
+	notFound.Field("http", "status").SetInt(404)
+	if err := filter(&notFound, injector, func() { dropped = true }); err != nil {
+		t.Fatal(err)
+	}
+	if !dropped {
+		t.Error("expected the event to be dropped")
+	}
+}
+
+func TestScript_Timestamps(t *testing.T) {
+	filter, err := Script("test", `
+def process(event):
+    event.set("@timestamp", event.get("@timestamp"))
+    event.set("received", event.get("@timestamp") + time.parse_duration("1m"))
+    event.set("year", event.get("@timestamp").year)
+`, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stamp := time.Date(2024, time.March, 5, 9, 7, 3, 0, time.UTC)
+	evt := loglang.NewEvent()
+	evt.Field("@timestamp").SetTime(stamp)
+	if err := filter(&evt, nil, func() {}); err != nil {
+		t.Fatal(err)
+	}
+	if actual, _ := evt.Field("@timestamp").Get(); actual != stamp {
+		t.Errorf("expected @timestamp to still be a time but got %#v", actual)
+	}
+	if actual, _ := evt.Field("received").Get(); actual != stamp.Add(time.Minute) {
+		t.Errorf("expected a time one minute later but got %#v", actual)
+	}
+	if actual := evt.Field("year").GetInt(); actual != 2024 {
+		t.Errorf("expected 2024 but got %d", actual)
+	}
+}
+
+func TestScript_CompileErrors(t *testing.T) {
+	for _, source := range []string{
+		"def process(event)\n    pass",
+		"x = 1",
+		"def process():\n    pass",
+		"load('other.star', 'x')\ndef process(event):\n    pass",
+		"drop()\ndef process(event):\n    pass",
+	} {
+		if _, err := Script("test", source, 0); err == nil {
+			t.Errorf("expected %q to be rejected", source)
+		}
+	}
+}
+
+func TestScript_RuntimeError(t *testing.T) {
+	filter, err := Script("test", "def process(event):\n    event.set('x', 1 // 0)", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evt := loglang.NewEvent()
+	err = filter(&evt, nil, func() {})
+	if err == nil || !strings.Contains(err.Error(), "division by zero") {
+		t.Errorf("expected a division error but got %v", err)
+	}
+}
+
+func TestScript_Timeout(t *testing.T) {
+	filter, err := Script("test", `
+def process(event):
+    for i in range(1000000000):
+        pass
+`, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evt := loglang.NewEvent()
+	start := time.Now()
+	err = filter(&evt, nil, func() {})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout but got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("script ran for %s after timing out", elapsed)
+	}
+}
+
+func TestScript_GlobalsAreFrozen(t *testing.T) {
+	filter, err := Script("test", `
+seen = []
+def process(event):
+    seen.append(1)
+`, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evt := loglang.NewEvent()
+	if err := filter(&evt, nil, func() {}); err == nil {
+		t.Error("expected process() to be unable to change globals")
+	}
+}
@@ -20,15 +20,17 @@ func Auto() loglang.FramingPlugin {
 type autoFraming struct{}
 
 func (p *autoFraming) Extract(ctx context.Context, rawInput <-chan []byte, output chan<- []byte) (exErr error) {
+	parentCtx := ctx
 	var stop context.CancelCauseFunc
 	ctx, stop = context.WithCancelCause(ctx)
+	defer stop(nil)
 	//log := loglang.ContextLogger(ctx)
 
 	// need to read enough to find index of the first \n in most cases
 	const peekSize = 240
 
-	pipeReader, pipeWriter := io.Pipe()
-	go loglang.PumpToWriter(ctx, stop, rawInput, pipeWriter)
+	pipeReader := pipeInput(ctx, rawInput)
+	defer pipeReader.Close()
 
 	// TODO: try to make a decision with every packet of data that arrives?
 	input := bufio.NewReaderSize(pipeReader, peekSize)
@@ -36,25 +38,31 @@ func (p *autoFraming) Extract(ctx context.Context, rawInput <-chan []byte, outpu
 	//peek, err := input.Peek(peekSize)
 	peek, err := input.Peek(10)
 	if err != nil {
-		if err.Error() == "unexpected EOF" {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
 			// actually this is normal for short stdin segments
 		} else {
+			close(output)
 			return err
 		}
 	}
+	if len(peek) == 0 {
+		close(output)
+		return nil
+	}
 
 	// we don't need to do the decoding;
 	// we just need to cut the byte stream into frames
 	var mode autoFramingMode
-	if peek[0] == '-' && peek[1] == '-' && peek[2] == '-' {
+	if bytes.HasPrefix(peek, []byte("---")) {
 		mode = yamlFramingMode
 	} else if peek[0] == '{' {
 		// json-lines is a common pattern
 		mode = linesFramingMode
-	} else if peek[0] == 0x1f && peek[1] == 0x8b {
+	} else if bytes.HasPrefix(peek, []byte{0x1f, 0x8b}) {
 		mode = gzipFramingMode
-	} else if peek[0] == 0x1e && peek[1] == 0x0f {
+	} else if bytes.HasPrefix(peek, []byte{0x1e, 0x0f}) {
 		// detected magic bytes for chunked GELF
+		close(output)
 		return fmt.Errorf("auto framing doesn't support chunked GELF")
 	} else if ix := bytes.IndexRune(peek, '\n'); ix > 0 && ix < 1000 {
 		mode = linesFramingMode
@@ -63,56 +71,42 @@ func (p *autoFraming) Extract(ctx context.Context, rawInput <-chan []byte, outpu
 	}
 	// TODO: what are bzip magic bytes?
 
-	running := true
-	go func() {
-		// PERF: do we actually leak a goroutine here?
-		<-ctx.Done()
-		running = false
-	}()
-
 	// handle each case
+	// PumpFromReader closes output when it's done, so the scanners must do the same
 	switch mode {
 	case linesFramingMode:
-		s := bufio.NewScanner(input)
-		for running && s.Scan() {
-			output <- s.Bytes()
-		}
+		scanFrames(ctx, bufio.NewScanner(input), output)
 	case yamlFramingMode:
 		s := bufio.NewScanner(input)
 		s.Split(scanYaml)
-		for running && s.Scan() {
-			output <- s.Bytes()
-		}
+		scanFrames(ctx, s, output)
 	case gzipFramingMode:
 		var subreader io.Reader
 		subreader, exErr = gzip.NewReader(input)
 		if exErr != nil {
+			close(output)
 			return
 		}
 		loglang.PumpFromReader(ctx, stop, subreader, output)
-		return
 	case bzipFramingMode:
 		var subreader io.Reader
 		subreader = bzip2.NewReader(input)
 		loglang.PumpFromReader(ctx, stop, subreader, output)
-		return
 	case zstdFramingMode:
 		var subreader io.Reader
 		subreader, exErr = zlib.NewReader(input)
 		if exErr != nil {
+			close(output)
 			return
 		}
 		loglang.PumpFromReader(ctx, stop, subreader, output)
-		return
 	case wholeFramingMode:
 		loglang.PumpFromReader(ctx, stop, input, output)
-		return
 	default:
 		loglang.PumpFromReader(ctx, stop, input, output)
-		return
 	}
 
-	return nil
+	return stageFailure(parentCtx, ctx)
 }
 
 func (p *autoFraming) Frameup(_ context.Context, _ <-chan []byte, _ chan<- []byte) error {
@@ -4,7 +4,6 @@ import (
 	"compress/bzip2"
 	"context"
 	"github.com/nicwaller/loglang"
-	"io"
 )
 
 //goland:noinspection GoUnusedExportedFunction
@@ -15,17 +14,20 @@ func Bzip() loglang.FramingPlugin {
 type bzipFraming struct{}
 
 func (p *bzipFraming) Extract(ctx context.Context, input <-chan []byte, output chan<- []byte) (retErr error) {
+	parentCtx := ctx
 	var haltExtraction context.CancelCauseFunc
 	ctx, haltExtraction = context.WithCancelCause(ctx)
+	defer haltExtraction(nil)
 	ctx = context.WithValue(ctx, loglang.ContextKeyPluginType, "framing[bzip]")
 
-	pipeReader, pipeWriter := io.Pipe()
+	pipeReader := pipeInput(ctx, input)
+	defer pipeReader.Close()
+
+	// PumpFromReader closes output when it's done
 	subreader := bzip2.NewReader(pipeReader)
-	// io.Pipe MUST be paired with a goroutine to avoid blocking
-	go loglang.PumpFromReader(ctx, haltExtraction, subreader, output)
-	loglang.PumpToWriter(ctx, haltExtraction, input, pipeWriter)
+	loglang.PumpFromReader(ctx, haltExtraction, subreader, output)
 
-	return
+	return stageFailure(parentCtx, ctx)
 }
 
 func (p *bzipFraming) Frameup(_ context.Context, _ <-chan []byte, _ chan<- []byte) (err error) {
@@ -0,0 +1,73 @@
+package framing
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nicwaller/loglang"
+	"github.com/nicwaller/loglang/codec"
+)
+
+func extractAll(t *testing.T, policy loglang.DecodeFailurePolicy, text string) ([]*loglang.Event, error) {
+	input := loglang.BaseInputPlugin{
+		Framing:       []loglang.FramingPlugin{Lines()},
+		Codec:         codec.Json(),
+		DecodeFailure: policy,
+	}
+	template := loglang.NewEvent()
+	template.Field("source").SetString("upload")
+
+	events := make(chan *loglang.Event, 10)
+	err := input.Extract(context.Background(), &template, strings.NewReader(text), events)
+	collected := make([]*loglang.Event, 0)
+	for evt := range events {
+		collected = append(collected, evt)
+	}
+	return collected, err
+}
+
+func TestBaseInputPlugin_DecodeFailureFallback(t *testing.T) {
+	// the default policy
+	events, err := extractAll(t, "", "{\"a\":1}\nnot json\n{\"a\":2}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events but got %d", len(events))
+	}
+	fallback := events[1]
+	if actual := fallback.Field("message").GetString(); actual != "not json" {
+		t.Errorf("expected the raw frame as the message but got %q", actual)
+	}
+	if actual := fallback.Field("tags").GetStrings(); !reflect.DeepEqual(actual, []string{"_jsonparsefailure"}) {
+		t.Errorf("expected _jsonparsefailure but got %v", actual)
+	}
+	if actual := fallback.Field("error", "message").GetString(); !strings.Contains(actual, "invalid character") {
+		t.Errorf("expected the decoding error but got %q", actual)
+	}
+	if actual := fallback.Field("source").GetString(); actual != "upload" {
+		t.Error("expected template to be merged into the fallback event")
+	}
+}
+
+func TestBaseInputPlugin_DecodeFailureSkip(t *testing.T) {
+	events, err := extractAll(t, loglang.DecodeFailureSkip, "{\"a\":1}\nnot json\n{\"a\":2}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Errorf("expected the bad frame to be skipped but got %d events", len(events))
+	}
+}
+
+func TestBaseInputPlugin_DecodeFailureFail(t *testing.T) {
+	events, err := extractAll(t, loglang.DecodeFailureFail, "{\"a\":1}\nnot json\n{\"a\":2}\n")
+	if err == nil {
+		t.Error("expected extraction to fail")
+	}
+	if len(events) != 1 {
+		t.Errorf("expected only the event before the failure but got %d", len(events))
+	}
+}
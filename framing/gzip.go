@@ -5,7 +5,6 @@ import (
 	"context"
 	"fmt"
 	"github.com/nicwaller/loglang"
-	"io"
 )
 
 //goland:noinspection GoUnusedExportedFunction
@@ -16,27 +15,34 @@ func Gzip() loglang.FramingPlugin {
 type gzipFraming struct{}
 
 func (p *gzipFraming) Extract(ctx context.Context, input <-chan []byte, output chan<- []byte) (retErr error) {
+	parentCtx := ctx
 	var stop context.CancelCauseFunc
 	ctx, stop = context.WithCancelCause(ctx)
+	defer stop(nil)
 	ctx = context.WithValue(ctx, loglang.ContextKeyPluginType, "framing[gzip]")
 	log := loglang.ContextLogger(ctx)
 
-	pipeReader, pipeWriter := io.Pipe()
+	// the pump must be running before gzip.NewReader() tries to read the header
+	pipeReader := pipeInput(ctx, input)
+	defer pipeReader.Close()
+
 	subreader, err := gzip.NewReader(pipeReader)
 	if err != nil {
 		log.Error("failed to create gzip reader")
+		close(output)
 		return err
 	}
 
-	// io.Pipe MUST be paired with a goroutine to avoid blocking
-	go loglang.PumpToWriter(ctx, stop, input, pipeWriter)
+	// PumpFromReader closes output when it's done
 	loglang.PumpFromReader(ctx, stop, subreader, output)
-	close(output)
 
-	return
+	return stageFailure(parentCtx, ctx)
 }
 
 func (p *gzipFraming) Frameup(ctx context.Context, input <-chan []byte, output chan<- []byte) (retErr error) {
+	// then close the output channel to indicate that framing is complete
+	defer close(output) // all framing plugins must close output to signal completion!
+
 	var stop context.CancelCauseFunc
 	ctx, stop = context.WithCancelCause(ctx)
 	ctx = context.WithValue(ctx, loglang.ContextKeyPluginType, "framing[gzip]")
@@ -50,9 +56,6 @@ func (p *gzipFraming) Frameup(ctx context.Context, input <-chan []byte, output c
 		return fmt.Errorf("failed to flush gzip: %w", err)
 	}
 
-	// then close the output channel to indicate that framing is complete
-	close(output) // all framing plugins must close output to signal completion!
-
 	return
 }
 
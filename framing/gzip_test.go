@@ -0,0 +1,91 @@
+package framing
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"github.com/nicwaller/loglang"
+	"github.com/nicwaller/loglang/codec"
+	"testing"
+)
+
+func gzipped(t *testing.T, text string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(text)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGzip_Extract(t *testing.T) {
+	ctx := context.Background()
+	chunks := make(chan []byte, 1)
+	chunks <- gzipped(t, "Hello\nGoodbye\n")
+	close(chunks)
+
+	out := make(chan []byte, 10)
+	err := Gzip().Extract(ctx, chunks, out)
+	if err != nil {
+		t.Error(err)
+	}
+
+	var dat []byte
+	for chunk := range out {
+		dat = append(dat, chunk...)
+	}
+	const expected = "Hello\nGoodbye\n"
+	if expected != string(dat) {
+		t.Errorf(`Expected "%s" but got "%s"`, expected, dat)
+	}
+}
+
+func TestPumpFraming_GzipLines(t *testing.T) {
+	ctx, stop := context.WithCancelCause(context.Background())
+	defer stop(nil)
+	chunks := make(chan []byte, 1)
+	chunks <- gzipped(t, "Hello\nGoodbye\n")
+	close(chunks)
+
+	frames := loglang.PumpFraming(ctx, stop, []loglang.FramingPlugin{Gzip(), Lines()}, chunks)
+
+	actual := make([]string, 0)
+	for frame := range frames {
+		actual = append(actual, string(frame))
+	}
+	if len(actual) != 2 || actual[0] != "Hello" || actual[1] != "Goodbye" {
+		t.Errorf(`Expected [Hello Goodbye] but got %v`, actual)
+	}
+	if cause := context.Cause(ctx); cause != nil {
+		t.Error(cause)
+	}
+}
+
+func TestBaseInputPlugin_ExtractChain(t *testing.T) {
+	input := loglang.BaseInputPlugin{
+		Framing: []loglang.FramingPlugin{Gzip(), Lines()},
+		Codec:   codec.Plain("message"),
+	}
+	template := loglang.NewEvent()
+	template.Field("source").SetString("upload")
+
+	events := make(chan *loglang.Event, 10)
+	err := input.Extract(context.Background(), &template, bytes.NewReader(gzipped(t, "Hello\nGoodbye\n")), events)
+	if err != nil {
+		t.Error(err)
+	}
+
+	actual := make([]string, 0)
+	for evt := range events {
+		actual = append(actual, evt.Field("message").GetString())
+		if evt.Field("source").GetString() != "upload" {
+			t.Error("expected template to be merged")
+		}
+	}
+	if len(actual) != 2 || actual[0] != "Hello" || actual[1] != "Goodbye" {
+		t.Errorf(`Expected [Hello Goodbye] but got %v`, actual)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"context"
 	"fmt"
 	"github.com/nicwaller/loglang"
-	"io"
 	"log/slog"
 )
 
@@ -18,37 +17,26 @@ func Lines() loglang.FramingPlugin {
 type lines struct{}
 
 func (p *lines) Extract(ctx context.Context, input <-chan []byte, output chan<- []byte) (retErr error) {
-	var stop context.CancelCauseFunc
-	ctx, stop = context.WithCancelCause(ctx)
 	log := loglang.ContextLogger(ctx)
 
-	scannable, writeInputFrames := io.Pipe()
-	go loglang.PumpToWriter(ctx, stop, input, writeInputFrames)
+	scannable := pipeInput(ctx, input)
+	// unblock the writing side if we stop reading early
+	defer scannable.Close()
 
 	streamScanner := bufio.NewScanner(scannable)
 	log.Info("started scanLoop")
-	running := true
-scanLoop:
-	for running {
-		select {
-		case <-ctx.Done():
-			break scanLoop
-		default:
-			running = streamScanner.Scan()
-			output <- streamScanner.Bytes()
-		}
-	}
+	scanFrames(ctx, streamScanner, output)
 	log.Info("stopped scanLoop")
-	return
+	return streamScanner.Err()
 }
 
 func (p *lines) Frameup(ctx context.Context, input <-chan []byte, output chan<- []byte) error {
+	defer close(output)
 framingLoop:
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
-		default:
 		case frame, more := <-input:
 			if !more {
 				slog.Debug("framingLoop saw closed channel")
@@ -62,6 +50,5 @@ framingLoop:
 			output <- frameLF
 		}
 	}
-	close(output)
 	return nil
 }
@@ -2,44 +2,37 @@ package framing
 
 import (
 	"context"
-	"io"
-	"strings"
 	"testing"
 )
 
 func TestLines_Extract(t *testing.T) {
 	ctx := context.Background()
-	streams := make(chan io.Reader)
+	chunks := make(chan []byte)
 	go func() {
-		streams <- strings.NewReader("Hello\nGoodbye\n")
-		close(streams)
+		chunks <- []byte("Hello\nGood")
+		chunks <- []byte("bye\n")
+		close(chunks)
 	}()
 
-	out := make(chan io.Reader, 2)
+	out := make(chan []byte, 2)
 
-	err := Lines().Extract(ctx, streams, out)
+	err := Lines().Extract(ctx, chunks, out)
 	if err != nil {
 		t.Error(err)
 	}
 
 	line1 := <-out
-	dat1, err := io.ReadAll(line1)
-	if err != nil {
-		t.Error(err)
-	}
-
 	line2 := <-out
-	dat2, err := io.ReadAll(line2)
-	if err != nil {
-		t.Error(err)
+	if _, more := <-out; more {
+		t.Error("expected output channel to be closed")
 	}
 
 	const expected1 = "Hello"
 	const expected2 = "Goodbye"
-	if expected1 != string(dat1) {
-		t.Errorf(`Expected "%s" but got "%s"`, expected1, dat1)
+	if expected1 != string(line1) {
+		t.Errorf(`Expected "%s" but got "%s"`, expected1, line1)
 	}
-	if expected2 != string(dat2) {
-		t.Errorf(`Expected "%s" but got "%s"`, expected2, dat2)
+	if expected2 != string(line2) {
+		t.Errorf(`Expected "%s" but got "%s"`, expected2, line2)
 	}
 }
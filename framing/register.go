@@ -0,0 +1,14 @@
+package framing
+
+import (
+	"github.com/nicwaller/loglang"
+)
+
+func init() {
+	loglang.RegisterFraming("auto", func(struct{}) (loglang.FramingPlugin, error) { return Auto(), nil })
+	loglang.RegisterFraming("bzip", func(struct{}) (loglang.FramingPlugin, error) { return Bzip(), nil })
+	loglang.RegisterFraming("gzip", func(struct{}) (loglang.FramingPlugin, error) { return Gzip(), nil })
+	loglang.RegisterFraming("lines", func(struct{}) (loglang.FramingPlugin, error) { return Lines(), nil })
+	loglang.RegisterFraming("syslog", func(struct{}) (loglang.FramingPlugin, error) { return Syslog(), nil })
+	loglang.RegisterFraming("whole", func(struct{}) (loglang.FramingPlugin, error) { return Whole(), nil })
+}
@@ -0,0 +1,97 @@
+package framing
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/nicwaller/loglang"
+)
+
+// Syslog is for syslog over TCP, where senders use one of two framings (RFC 6587):
+// octet counting ("17 <13>1 - - - - - -") or non-transparent framing with a linefeed after each message.
+// Each stream is checked once, so one listener can serve both kinds of senders.
+// Frameup uses octet counting, because it's safe for messages that contain linefeeds.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Syslog() loglang.FramingPlugin {
+	return &syslogFraming{}
+}
+
+type syslogFraming struct{}
+
+// MSG-LEN is at most this many digits
+const maxSyslogLengthDigits = 5
+
+func (p *syslogFraming) Extract(ctx context.Context, input <-chan []byte, output chan<- []byte) error {
+	scannable := pipeInput(ctx, input)
+	// unblock the writing side if we stop reading early
+	defer scannable.Close()
+
+	reader := bufio.NewReader(scannable)
+	first, err := reader.Peek(1)
+	if err != nil {
+		close(output)
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+
+	s := bufio.NewScanner(reader)
+	s.Buffer(make([]byte, 0, 4096), loglang.MaxFrameSize+maxSyslogLengthDigits+1)
+	// a message always starts with <PRI>, so a digit must be MSG-LEN
+	if first[0] >= '0' && first[0] <= '9' {
+		s.Split(scanOctetCounted)
+	}
+	scanFrames(ctx, s, output)
+	return s.Err()
+}
+
+func (p *syslogFraming) Frameup(ctx context.Context, input <-chan []byte, output chan<- []byte) error {
+	defer close(output)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case frame, more := <-input:
+			if !more {
+				return nil
+			}
+			output <- append([]byte(strconv.Itoa(len(frame))+" "), frame...)
+		}
+	}
+}
+
+// scanOctetCounted splits MSG-LEN SP SYSLOG-MSG, for use with bufio.Scanner .Split()
+func scanOctetCounted(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	// some senders put a linefeed between messages anyway
+	if data[0] == '\n' || data[0] == '\r' {
+		return 1, nil, nil
+	}
+	space := bytes.IndexByte(data, ' ')
+	if space < 0 {
+		if len(data) > maxSyslogLengthDigits || atEOF {
+			return 0, nil, fmt.Errorf("octet counting: expected MSG-LEN but got %q", data[:min(len(data), 10)])
+		}
+		return 0, nil, nil
+	}
+	length, err := strconv.Atoi(string(data[:space]))
+	if err != nil || space > maxSyslogLengthDigits || length <= 0 || length > loglang.MaxFrameSize {
+		return 0, nil, fmt.Errorf("octet counting: bad MSG-LEN %q", data[:min(space, 10)])
+	}
+	end := space + 1 + length
+	if len(data) < end {
+		if atEOF {
+			return 0, nil, fmt.Errorf("octet counting: stream ended %d bytes into a %d byte message", len(data)-space-1, length)
+		}
+		return 0, nil, nil
+	}
+	return end, data[space+1 : end], nil
+}
@@ -0,0 +1,69 @@
+package framing
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func extractSyslog(t *testing.T, chunks ...string) ([]string, error) {
+	t.Helper()
+	input := make(chan []byte)
+	go func() {
+		for _, chunk := range chunks {
+			input <- []byte(chunk)
+		}
+		close(input)
+	}()
+	out := make(chan []byte, 10)
+	err := Syslog().Extract(context.Background(), input, out)
+	frames := make([]string, 0)
+	for frame := range out {
+		frames = append(frames, string(frame))
+	}
+	return frames, err
+}
+
+func TestSyslog_ExtractOctetCounting(t *testing.T) {
+	// a message can contain a linefeed, and can be split across reads
+	frames, err := extractSyslog(t, "15 <13>hello\nw", "orld12 <13>goodbye\n\n", "9 <13>again")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"<13>hello\nworld", "<13>goodbye\n", "<13>again"}
+	if !reflect.DeepEqual(frames, expected) {
+		t.Errorf("expected %q but got %q", expected, frames)
+	}
+}
+
+func TestSyslog_ExtractNonTransparent(t *testing.T) {
+	frames, err := extractSyslog(t, "<13>hello\r\n<13>wor", "ld\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"<13>hello", "<13>world"}
+	if !reflect.DeepEqual(frames, expected) {
+		t.Errorf("expected %q but got %q", expected, frames)
+	}
+}
+
+func TestSyslog_ExtractBadLength(t *testing.T) {
+	for _, stream := range []string{"12 <13>truncated", "5x <13>hi", "123456789"} {
+		if _, err := extractSyslog(t, stream); err == nil {
+			t.Errorf("expected %q to fail", stream)
+		}
+	}
+}
+
+func TestSyslog_Frameup(t *testing.T) {
+	input := make(chan []byte, 1)
+	input <- []byte("<13>hi\nthere")
+	close(input)
+	out := make(chan []byte, 1)
+	if err := Syslog().Frameup(context.Background(), input, out); err != nil {
+		t.Fatal(err)
+	}
+	if actual := string(<-out); actual != "12 <13>hi\nthere" {
+		t.Errorf("unexpected frame %q", actual)
+	}
+}
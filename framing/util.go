@@ -1 +1,52 @@
 package framing
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"github.com/nicwaller/loglang"
+	"io"
+)
+
+// pipeInput copies chunks from the input channel into a pipe,
+// so that framing stages can use io.Reader based decoders (bufio, gzip, etc.)
+//
+// The writing side gets its own context because a closed input channel
+// is normal, and must not cancel the reading side before it drains the pipe.
+func pipeInput(ctx context.Context, input <-chan []byte) *io.PipeReader {
+	pipeReader, pipeWriter := io.Pipe()
+	// io.Pipe MUST be paired with a goroutine to avoid blocking
+	go func() {
+		writeCtx, stopWriting := context.WithCancelCause(ctx)
+		loglang.PumpToWriter(writeCtx, stopWriting, input, pipeWriter)
+		stopWriting(nil)
+	}()
+	return pipeReader
+}
+
+// stageFailure picks out a real failure from the reason a framing stage stopped.
+// Reaching EOF is normal, and so is the parent context being cancelled.
+func stageFailure(parent context.Context, ctx context.Context) error {
+	if parent.Err() != nil {
+		return nil
+	}
+	if cause := context.Cause(ctx); cause != nil && !errors.Is(cause, io.EOF) && !errors.Is(cause, context.Canceled) {
+		return cause
+	}
+	return nil
+}
+
+// scanFrames sends every token from the scanner as a frame, then closes output
+func scanFrames(ctx context.Context, s *bufio.Scanner, output chan<- []byte) {
+	defer close(output)
+	for s.Scan() {
+		// the scanner reuses its buffer, so each frame needs a copy
+		frame := bytes.Clone(s.Bytes())
+		select {
+		case <-ctx.Done():
+			return
+		case output <- frame:
+		}
+	}
+}
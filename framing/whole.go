@@ -18,31 +18,25 @@ type whole struct{}
 // func (p *lines) Extract(ctx context.Context, reader io.Reader, out chan<- io.Reader) error {
 
 func (p *whole) Extract(ctx context.Context, input <-chan []byte, output chan<- []byte) error {
-loop:
-	for {
-		select {
-		case frame := <-input:
-			output <- frame
-
-		case <-ctx.Done():
-			break loop
-		}
-	}
-	close(output)
-	return nil
+	return passthrough(ctx, input, output)
 }
 
 func (p *whole) Frameup(ctx context.Context, input <-chan []byte, output chan<- []byte) error {
-loop:
+	return passthrough(ctx, input, output)
+}
+
+func passthrough(ctx context.Context, input <-chan []byte, output chan<- []byte) error {
+	defer close(output)
 	for {
 		select {
-		case frame := <-input:
+		case frame, more := <-input:
+			if !more {
+				return nil
+			}
 			output <- frame
 
 		case <-ctx.Done():
-			break loop
+			return nil
 		}
 	}
-	close(output)
-	return nil
 }
@@ -2,31 +2,27 @@ package framing
 
 import (
 	"context"
-	"io"
-	"strings"
 	"testing"
 )
 
 func TestWhole_Extract(t *testing.T) {
-	//	func (p *whole) Extract(_ context.Context, streams <-chan io.Reader, out chan<- io.Reader) error {
 	ctx := context.Background()
-	streams := make(chan io.Reader)
+	chunks := make(chan []byte)
 	go func() {
-		streams <- strings.NewReader("Hello\nGoodbye\n")
-		close(streams)
+		chunks <- []byte("Hello\nGoodbye\n")
+		close(chunks)
 	}()
 
-	out := make(chan io.Reader, 1)
+	out := make(chan []byte, 1)
 
-	err := Whole().Extract(ctx, streams, out)
+	err := Whole().Extract(ctx, chunks, out)
 	if err != nil {
 		t.Error(err)
 	}
 
-	onlyReader := <-out
-	dat, err := io.ReadAll(onlyReader)
-	if err != nil {
-		t.Error(err)
+	dat := <-out
+	if _, more := <-out; more {
+		t.Error("expected output channel to be closed")
 	}
 
 	const expected = "Hello\nGoodbye\n"
@@ -3,6 +3,9 @@ module github.com/nicwaller/loglang
 go 1.21
 
 require (
-	github.com/lmittmann/tint v1.0.2 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	github.com/lmittmann/tint v1.0.2
+	go.starlark.net v0.0.0-20231121155337-90ade8b19d09
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
@@ -0,0 +1,215 @@
+package loglang
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Health is served by the admin server as JSON at /health and /ready,
+// The pipeline is healthy until a plugin fails or an output stalls (a quiet input is only reported),
+// The pipeline is healthy until something fails or stalls,
+// and ready once every input has started (eg. is listening on its port).
+
+type PluginState string
+
+const (
+	StateStarting PluginState = "starting"
+	StateRunning  PluginState = "running"
+	StateStalled  PluginState = "stalled"
+	StateFailed   PluginState = "failed"
+	StateDraining PluginState = "draining"
+	StateStopped  PluginState = "stopped"
+)
+
+// how many errors to remember for each plugin
+const maxRecentErrors = 10
+
+type HealthReport struct {
+	Pipeline string         `json:"pipeline"`
+	State    PluginState    `json:"state"`
+	Cause    string         `json:"cause,omitempty"`
+	Healthy  bool           `json:"healthy"`
+	Ready    bool           `json:"ready"`
+	Inputs   []PluginHealth `json:"inputs"`
+	Outputs  []PluginHealth `json:"outputs"`
+}
+
+type PluginHealth struct {
+	Name         string        `json:"name"`
+	State        PluginState   `json:"state"`
+	Cause        string        `json:"cause,omitempty"`
+	RecentErrors []RecentError `json:"recentErrors,omitempty"`
+	// PendingBatches is how many E2E batches from an input are waiting to be acknowledged
+	PendingBatches int64 `json:"pendingBatches"`
+	// PendingEvents is how many events an output has received but not finished
+	PendingEvents int64 `json:"pendingEvents"`
+}
+
+type RecentError struct {
+	Time  time.Time `json:"time"`
+	Error string    `json:"error"`
+}
+
+// InputReady is called by an input plugin once it's ready for traffic (eg. listening on its port).
+// Inputs that never call it are ready once they send their first event.
+func InputReady(ctx context.Context) {
+	status, _ := ctx.Value(contextKeyStatus).(*pluginStatus)
+	status.ready()
+}
+
+const contextKeyStatus ContextKey = "status"
+
+type pluginStatus struct {
+	mutex        sync.Mutex
+	state        PluginState
+	cause        error
+	recentErrors []RecentError
+	// failedSends is how many batches in a row an output has failed
+	failedSends int
+	// these are attached once the pipeline is running
+	pendingBatches func() int64
+	watch          *stallWatch
+}
+
+func newPluginStatus() *pluginStatus {
+	return &pluginStatus{state: StateStarting}
+}
+
+func (s *pluginStatus) set(state PluginState, cause error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.state = state
+	s.cause = cause
+}
+
+// ready moves from starting to running, and is safe to call often (or on nil)
+func (s *pluginStatus) ready() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.state == StateStarting {
+		s.state = StateRunning
+	}
+}
+
+func (s *pluginStatus) attachWatch(watch *stallWatch) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.watch = watch
+}
+
+func (s *pluginStatus) attachPendingBatches(pending func() int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.pendingBatches = pending
+}
+
+// sendFailed only marks an output as failed once it has failed threshold batches in a row,
+// so one bad batch doesn't make the whole pipeline unhealthy
+func (s *pluginStatus) sendFailed(err error, threshold int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failedSends++
+	if s.failedSends >= threshold {
+		s.state = StateFailed
+		s.cause = err
+	}
+}
+
+func (s *pluginStatus) sendSucceeded() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failedSends = 0
+	s.state = StateRunning
+	s.cause = nil
+}
+
+func (s *pluginStatus) recordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.recentErrors = append(s.recentErrors, RecentError{Time: time.Now(), Error: err.Error()})
+	if len(s.recentErrors) > maxRecentErrors {
+		s.recentErrors = s.recentErrors[len(s.recentErrors)-maxRecentErrors:]
+	}
+}
+
+func (s *pluginStatus) report(name string) PluginHealth {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	h := PluginHealth{
+		Name:         name,
+		State:        s.state,
+		RecentErrors: append([]RecentError(nil), s.recentErrors...),
+	}
+	if s.cause != nil {
+		h.Cause = s.cause.Error()
+	}
+	if s.watch != nil {
+		if h.State == StateRunning && s.watch.stalled.Load() {
+			h.State = StateStalled
+		}
+		if s.watch.pending != nil {
+			h.PendingEvents = s.watch.pending()
+		}
+	}
+	if s.pendingBatches != nil {
+		h.PendingBatches = s.pendingBatches()
+	}
+	return h
+}
+
+// Health describes the pipeline and each of its inputs and outputs
+func (p *Pipeline) Health() HealthReport {
+	pipeline := p.status.report(p.Name)
+	report := HealthReport{
+		Pipeline: p.Name,
+		State:    pipeline.State,
+		Cause:    pipeline.Cause,
+		Healthy:  pipeline.State != StateStopped,
+		Ready:    pipeline.State == StateRunning,
+		Inputs:   make([]PluginHealth, 0, len(p.inputs)),
+		Outputs:  make([]PluginHealth, 0, len(p.outputs)),
+	}
+	for _, input := range p.inputs {
+		h := input.Value.status.report(input.Name)
+		report.Inputs = append(report.Inputs, h)
+		// a stalled input is still listening; it just has nothing to say
+		if h.State != StateRunning && h.State != StateStalled {
+			report.Ready = false
+		}
+		if h.State == StateFailed {
+			report.Healthy = false
+		}
+	}
+	for _, output := range p.outputs {
+		h := output.Value.status.report(output.Name)
+		report.Outputs = append(report.Outputs, h)
+		if h.State == StateFailed || h.State == StateStalled {
+			report.Healthy = false
+		}
+	}
+	return report
+}
+
+func (p *Pipeline) healthHandler(ok func(HealthReport) bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		report := p.Health()
+		w.Header().Set("Content-Type", "application/json")
+		if ok(report) {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(report)
+	}
+}
@@ -0,0 +1,138 @@
+package loglang
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPipeline_Health(t *testing.T) {
+	p := NewPipeline("test", PipelineOptions{
+		StalledOutputThreshold: 50 * time.Millisecond,
+		DrainTimeout:           50 * time.Millisecond,
+	})
+	in := &streamingInput{events: messageEvents("one"), sent: make(chan struct{})}
+	p.Input("stream", in)
+	p.Output("stuck", &stuckOutput{}, nil, nil)
+	// otherwise the stall warning would be stuck in the output too
+	p.StallOutput("warnings", &memoryOutput{}, nil, nil)
+
+	if h := p.Health(); h.Ready || h.Inputs[0].State != StateStarting {
+		t.Errorf("expected a pipeline that isn't running to be unready but got %+v", h)
+	}
+
+	finished := make(chan error, 1)
+	go func() {
+		finished <- p.Run()
+	}()
+	<-in.sent
+
+	var h HealthReport
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		h = p.Health()
+		if h.Outputs[0].State == StateStalled {
+			break
+		}
+	}
+	if h.Outputs[0].State != StateStalled || h.Outputs[0].PendingEvents != 1 {
+		t.Errorf("expected the output to be stalled with 1 event pending but got %+v", h.Outputs[0])
+	}
+	if !h.Ready || h.Healthy {
+		t.Errorf("expected ready but unhealthy; got ready=%t healthy=%t", h.Ready, h.Healthy)
+	}
+
+	for path, expected := range map[string]int{"/ready": http.StatusOK, "/health": http.StatusServiceUnavailable} {
+		ok := func(r HealthReport) bool { return r.Ready }
+		if path == "/health" {
+			ok = func(r HealthReport) bool { return r.Healthy }
+		}
+		rec := httptest.NewRecorder()
+		p.healthHandler(ok)(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != expected {
+			t.Errorf("expected %s to return %d but got %d", path, expected, rec.Code)
+		}
+		var body HealthReport
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Pipeline != "test" {
+			t.Errorf("expected a JSON health report from %s but got %q", path, rec.Body.String())
+		}
+	}
+
+	p.Stop("test")
+	<-finished
+	h = p.Health()
+	if h.State != StateStopped || !strings.Contains(h.Cause, "pipeline stop requested: test") {
+		t.Errorf("expected a stopped pipeline with a cause but got %+v", h)
+	}
+	if h.Inputs[0].State != StateStopped {
+		t.Errorf("expected the input to be stopped but got %v", h.Inputs[0].State)
+	}
+}
+
+func TestPipeline_HealthRecentErrors(t *testing.T) {
+	p := NewPipeline("test", PipelineOptions{})
+	p.Input("slice", &sliceInput{events: messageEvents("one")})
+	p.Output("broken", &brokenOutput{}, nil, nil)
+	if err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	h := p.Health()
+	if h.Outputs[0].State != StateStopped || len(h.Outputs[0].RecentErrors) != 1 {
+		t.Errorf("expected a stopped output with a recent error but got %+v", h.Outputs[0])
+	}
+	if len(h.Inputs[0].RecentErrors) != 1 {
+		t.Errorf("expected the input batch to report the error but got %+v", h.Inputs[0])
+	}
+}
+
+func TestPluginStatus_FailedOutputThreshold(t *testing.T) {
+	status := newPluginStatus()
+	status.set(StateRunning, nil)
+	for i := 1; i < 3; i++ {
+		status.sendFailed(errors.New("connection refused"), 3)
+		if h := status.report("out"); h.State != StateRunning {
+			t.Errorf("expected the output to keep running after %d failed batches but got %v", i, h.State)
+		}
+	}
+	status.sendFailed(errors.New("connection refused"), 3)
+	if h := status.report("out"); h.State != StateFailed || h.Cause != "connection refused" {
+		t.Errorf("expected the output to fail after 3 failed batches but got %+v", h)
+	}
+	status.sendSucceeded()
+	status.sendFailed(errors.New("connection refused"), 3)
+	if h := status.report("out"); h.State != StateRunning || h.Cause != "" {
+		t.Errorf("expected a success to reset the failures but got %+v", h)
+	}
+}
+
+func TestPipeline_HealthQuietInput(t *testing.T) {
+	p := NewPipeline("test", PipelineOptions{StalledInputThreshold: 50 * time.Millisecond})
+	in := &streamingInput{events: messageEvents("one"), sent: make(chan struct{})}
+	p.Input("stream", in)
+	p.Output("memory", &memoryOutput{}, nil, nil)
+
+	finished := make(chan error, 1)
+	go func() {
+		finished <- p.Run()
+	}()
+	defer func() {
+		p.Stop("test")
+		<-finished
+	}()
+	<-in.sent
+
+	var h HealthReport
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		h = p.Health()
+		if h.Inputs[0].State == StateStalled {
+			break
+		}
+	}
+	// a quiet input is reported, but it's still listening, so it shouldn't fail liveness probes
+	if h.Inputs[0].State != StateStalled || !h.Healthy || !h.Ready {
+		t.Errorf("expected a stalled input in a healthy, ready pipeline but got %+v", h)
+	}
+}
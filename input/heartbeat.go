@@ -25,23 +25,18 @@ type generator struct {
 }
 
 type HeartbeatOptions struct {
-	ID       string
-	Interval time.Duration
-	Count    int
-	Schema   loglang.SchemaModel
+	ID       string              `yaml:"id"`
+	Interval time.Duration       `yaml:"interval"`
+	Count    int                 `yaml:"count"`
+	Schema   loglang.SchemaModel `yaml:"schema"`
 }
 
 func (p *generator) Run(ctx context.Context, sender loglang.Sender) error {
-	running := true
-	go func() {
-		<-ctx.Done()
-		running = false
-	}()
-
 	log := slog.With("pipeline", ctx.Value("pipeline"),
 		"plugin", ctx.Value("plugin"))
 
 	sender.SetE2E(true)
+	loglang.InputReady(ctx)
 
 	schema := p.opts.Schema
 	if schema == loglang.SchemaNotDefined {
@@ -56,9 +51,13 @@ func (p *generator) Run(ctx context.Context, sender loglang.Sender) error {
 	}
 	opts := p.opts
 	var lastDuration time.Duration
-	for count := 1; running && count != p.opts.Count; count++ {
+	for count := 1; ctx.Err() == nil && count != p.opts.Count; count++ {
 		nextHeartbeat := time.After(opts.Interval)
 		evt := loglang.NewEvent()
+		if schema != loglang.SchemaNone {
+			// stamped now, rather than whenever the pipeline gets to it
+			evt.Field("@timestamp").SetTime(time.Now())
+		}
 		switch schema {
 		case loglang.SchemaNone:
 			// don't enrich with any automatic fields
@@ -100,7 +99,10 @@ func (p *generator) Run(ctx context.Context, sender loglang.Sender) error {
 			}
 			lastDuration = result.Finish.Sub(result.Start)
 		}
-		<-nextHeartbeat
+		select {
+		case <-nextHeartbeat:
+		case <-ctx.Done():
+		}
 	}
 
 	log.Debug("stopped generator")
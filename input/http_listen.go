@@ -2,11 +2,13 @@ package input
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/nicwaller/loglang"
 	"github.com/nicwaller/loglang/codec"
 	"github.com/nicwaller/loglang/framing"
 	"log/slog"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
@@ -31,7 +33,7 @@ type httpListener struct {
 }
 
 type HttpListenerOptions struct {
-	ReplyImmediately bool
+	ReplyImmediately bool `yaml:"replyImmediately"`
 }
 
 func (p *httpListener) Run(ctx context.Context, sender loglang.Sender) error {
@@ -139,13 +141,19 @@ func (p *httpListener) Run(ctx context.Context, sender loglang.Sender) error {
 	}
 
 	log.Debug("starting listener")
+	// listen here, so a port conflict fails the input instead of just being logged
+	ln, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return err
+	}
 	go func() {
-		err := server.ListenAndServe()
-		if err != nil {
-			log.Error("failed to listen and serve", "error", err)
+		err := server.Serve(ln)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("failed to serve", "error", err)
 		}
 	}()
 	log.Info("started listening on " + server.Addr)
+	loglang.InputReady(ctx)
 
 	<-ctx.Done()
 	// TODO: should use a timeout on server shutdown
@@ -0,0 +1,112 @@
+package input
+
+import (
+	"fmt"
+	"github.com/nicwaller/loglang"
+	"slices"
+	"time"
+)
+
+type PortOptions struct {
+	Port int `yaml:"port"`
+}
+
+type HttpConfig struct {
+	Port                int `yaml:"port"`
+	HttpListenerOptions `yaml:",inline"`
+}
+
+type UdpConfig struct {
+	Port    int                 `yaml:"port"`
+	Codec   *loglang.PluginSpec `yaml:"codec"`
+	Framing *loglang.PluginSpec `yaml:"framing"`
+	Schema  loglang.SchemaModel `yaml:"schema"`
+}
+
+func init() {
+	loglang.RegisterInput("heartbeat", func(opts HeartbeatOptions) (loglang.InputPlugin, error) {
+		if err := loglang.CheckSchema(opts.Schema); err != nil {
+			return nil, err
+		}
+		return Heartbeat(opts), nil
+	})
+	loglang.RegisterInput("stdin", func(struct{}) (loglang.InputPlugin, error) {
+		return Stdin(), nil
+	})
+	loglang.RegisterInput("http", func(opts HttpConfig) (loglang.InputPlugin, error) {
+		if err := checkPort(opts.Port); err != nil {
+			return nil, err
+		}
+		return HttpListener(opts.Port, opts.HttpListenerOptions), nil
+	})
+	loglang.RegisterInput("tcp", func(opts PortOptions) (loglang.InputPlugin, error) {
+		if err := checkPort(opts.Port); err != nil {
+			return nil, err
+		}
+		return NewTcpListener(opts.Port, TcpListenerOptions{}), nil
+	})
+	loglang.RegisterInput("gelf", func(opts PortOptions) (loglang.InputPlugin, error) {
+		if err := checkPort(opts.Port); err != nil {
+			return nil, err
+		}
+		return GelfUDP(opts.Port), nil
+	})
+	loglang.RegisterInput("syslog", func(opts SyslogOptions) (loglang.InputPlugin, error) {
+		if err := loglang.CheckSchema(opts.Schema); err != nil {
+			return nil, err
+		}
+		if opts.Port != 0 {
+			if err := checkPort(opts.Port); err != nil {
+				return nil, err
+			}
+		}
+		for _, protocol := range opts.Protocols {
+			if protocol != "udp" && protocol != "tcp" {
+				return nil, fmt.Errorf("protocols: unknown protocol %q (known: udp, tcp)", protocol)
+			}
+		}
+		if _, err := time.LoadLocation(opts.Timezone); err != nil {
+			return nil, fmt.Errorf("timezone: %w", err)
+		}
+		if opts.TLS != nil {
+			if len(opts.Protocols) > 0 && !slices.Contains(opts.Protocols, "tcp") {
+				return nil, fmt.Errorf("tls: only works with tcp, but protocols doesn't include it")
+			}
+			if opts.TLS.CertFile == "" || opts.TLS.KeyFile == "" {
+				return nil, fmt.Errorf("tls: certFile and keyFile are required")
+			}
+			if _, err := opts.TLS.Config(); err != nil {
+				return nil, fmt.Errorf("tls: %w", err)
+			}
+		}
+		return Syslog(opts), nil
+	})
+	loglang.RegisterInput("udp", func(opts UdpConfig) (loglang.InputPlugin, error) {
+		if err := checkPort(opts.Port); err != nil {
+			return nil, err
+		}
+		if err := loglang.CheckSchema(opts.Schema); err != nil {
+			return nil, err
+		}
+		udpOpts := UdpListenerOptions{Schema: opts.Schema}
+		var err error
+		if opts.Codec != nil {
+			if udpOpts.Codec, err = loglang.BuildCodec(*opts.Codec); err != nil {
+				return nil, fmt.Errorf("codec: %w", err)
+			}
+		}
+		if opts.Framing != nil {
+			if udpOpts.Framing, err = loglang.BuildFraming(*opts.Framing); err != nil {
+				return nil, fmt.Errorf("framing: %w", err)
+			}
+		}
+		return UdpListener(opts.Port, udpOpts), nil
+	})
+}
+
+func checkPort(port int) error {
+	if port <= 0 || port > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535")
+	}
+	return nil
+}
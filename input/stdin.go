@@ -24,6 +24,7 @@ func (p *stdInput) Run(ctx context.Context, sender loglang.Sender) (err error) {
 
 	p.Framing = []loglang.FramingPlugin{framing.Lines()}
 	sender.SetE2E(true)
+	loglang.InputReady(ctx)
 	_, err = sender.SendRaw(ctx, p.eventTemplate(), os.Stdin)
 	return
 }
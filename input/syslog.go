@@ -0,0 +1,266 @@
+package input
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/nicwaller/loglang"
+	"github.com/nicwaller/loglang/codec"
+	"github.com/nicwaller/loglang/framing"
+)
+
+// Syslog receives syslog over UDP and TCP on the same port, like most syslog servers.
+// Each TCP connection can use octet counting or linefeeds (RFC 6587),
+// and each message can be RFC 5424 or RFC 3164.
+// With TLS, the TCP listener uses TLS (RFC 5425) and the default port is 6514.
+//
+// Test with: logger --server localhost --port 5514 --tcp --octet-count hello
+func Syslog(opts SyslogOptions) loglang.InputPlugin {
+	if opts.Port == 0 {
+		opts.Port = 514
+		if opts.TLS != nil {
+			opts.Port = 6514
+		}
+	}
+	if len(opts.Protocols) == 0 {
+		opts.Protocols = []string{"udp", "tcp"}
+	}
+	for _, protocol := range opts.Protocols {
+		if protocol != "udp" && protocol != "tcp" {
+			panic(fmt.Sprintf("unknown syslog protocol %q", protocol))
+		}
+	}
+	if opts.TLS != nil && !slices.Contains(opts.Protocols, "tcp") {
+		panic("syslog TLS needs the tcp protocol")
+	}
+	s := &syslogInput{opts: opts}
+	// the codec is asked to use the pipeline schema, unless this input has its own
+	s.Codec = codec.SyslogAutoWithOptions(codec.SyslogV0Options{Schema: opts.Schema, Timezone: opts.Timezone})
+	s.Framing = []loglang.FramingPlugin{framing.Syslog()}
+	s.Schema = opts.Schema
+	return s
+}
+
+type SyslogOptions struct {
+	// Port for UDP and TCP; defaults to 514, or 6514 with TLS
+	Port int `yaml:"port"`
+	// Protocols is "udp", "tcp" or both (the default)
+	Protocols []string `yaml:"protocols"`
+	// TLS makes the TCP listener use TLS
+	TLS *TLSOptions `yaml:"tls"`
+	// Timezone is for RFC 3164 timestamps, which don't have one; defaults to local time
+	Timezone string              `yaml:"timezone"`
+	Schema   loglang.SchemaModel `yaml:"schema"`
+}
+
+type TLSOptions struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+	// ClientCAFile makes clients present a certificate signed by one of these CAs
+	ClientCAFile string `yaml:"clientCAFile"`
+}
+
+// Config loads the certificates
+func (o *TLSOptions) Config() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if o.ClientCAFile != "" {
+		pem, err := os.ReadFile(o.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", o.ClientCAFile)
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+type syslogInput struct {
+	loglang.BaseInputPlugin
+	opts SyslogOptions
+}
+
+func (p *syslogInput) Run(ctx context.Context, sender loglang.Sender) error {
+	log := loglang.ContextLogger(ctx).With("server.port", strconv.Itoa(p.opts.Port))
+
+	schema := p.opts.Schema
+	if schema == loglang.SchemaNotDefined {
+		if pipelineSchema, ok := ctx.Value(loglang.ContextKeySchema).(loglang.SchemaModel); ok {
+			schema = pipelineSchema
+		}
+	}
+
+	// listen on everything first, so a port conflict fails the input
+	var udpConn *net.UDPConn
+	var tcpListener net.Listener
+	for _, protocol := range p.opts.Protocols {
+		var err error
+		switch protocol {
+		case "udp":
+			udpConn, err = net.ListenUDP("udp", &net.UDPAddr{Port: p.opts.Port})
+		case "tcp":
+			tcpListener, err = p.listenTCP()
+		}
+		if err != nil {
+			if udpConn != nil {
+				_ = udpConn.Close()
+			}
+			return err
+		}
+	}
+	log.Info("listening", "protocols", p.opts.Protocols, "tls", p.opts.TLS != nil)
+	loglang.InputReady(ctx)
+
+	// closing is the only way to interrupt ReadFromUDP() and Accept()
+	go func() {
+		<-ctx.Done()
+		if udpConn != nil {
+			_ = udpConn.Close()
+		}
+		if tcpListener != nil {
+			_ = tcpListener.Close()
+		}
+	}()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	if udpConn != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- p.serveUDP(ctx, sender, udpConn, schema)
+		}()
+	}
+	if tcpListener != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- p.serveTCP(ctx, sender, tcpListener, schema)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var err error
+	for serveErr := range errs {
+		err = errors.Join(err, serveErr)
+	}
+	return err
+}
+
+func (p *syslogInput) listenTCP() (net.Listener, error) {
+	address := ":" + strconv.Itoa(p.opts.Port)
+	if p.opts.TLS == nil {
+		return net.Listen("tcp", address)
+	}
+	config, err := p.opts.TLS.Config()
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	return tls.Listen("tcp", address, config)
+}
+
+func (p *syslogInput) serveUDP(ctx context.Context, sender loglang.Sender, conn *net.UDPConn, schema loglang.SchemaModel) error {
+	log := loglang.ContextLogger(ctx)
+	// each datagram is exactly one message
+	whole := framing.Whole()
+	buf := make([]byte, loglang.MaxFrameSize)
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		template := p.clientTemplate(schema, addr, "udp")
+		datagram := bytes.NewReader(bytes.Clone(buf[:n]))
+		if _, err := sender.SendWithFramingCodec(ctx, template, whole, p.Codec, datagram); err != nil {
+			log.Warn("problem with syslog datagram", "client.address", addr.String(), "error", err)
+		}
+	}
+}
+
+func (p *syslogInput) serveTCP(ctx context.Context, sender loglang.Sender, listener net.Listener, schema loglang.SchemaModel) error {
+	log := loglang.ContextLogger(ctx)
+	// connections are finished before returning, so everything they sent has been sent on
+	var connections sync.WaitGroup
+	defer connections.Wait()
+	for {
+		conn, err := listener.Accept()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+		connections.Add(1)
+		go func() {
+			defer connections.Done()
+			defer conn.Close()
+			// closing the connection is the only way to interrupt a read
+			stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+			defer stop()
+
+			template := p.clientTemplate(schema, conn.RemoteAddr(), "tcp")
+			// each connection is its own stream, so the framing is detected for each one
+			if _, err := sender.SendWithFramingChain(ctx, template, p.Framing, p.Codec, conn); err != nil && ctx.Err() == nil {
+				log.Warn("problem with syslog connection", "client.address", conn.RemoteAddr().String(), "error", err)
+			}
+		}()
+	}
+}
+
+func (p *syslogInput) clientTemplate(schema loglang.SchemaModel, addr net.Addr, transport string) *loglang.Event {
+	evt := loglang.NewEvent()
+	address := addr.String()
+	ip, portStr, _ := net.SplitHostPort(address)
+	port, _ := strconv.Atoi(portStr)
+
+	switch schema {
+	case loglang.SchemaNone:
+		// don't enrich with any automatic fields
+	case loglang.SchemaLogstashFlat:
+		evt.Field("host").SetString(ip)
+	case loglang.SchemaLogstashECS, loglang.SchemaECS:
+		evt.Field("client", "address").SetString(address)
+		evt.Field("client", "ip").SetString(ip)
+		evt.Field("client", "port").SetInt(port)
+		evt.Field("server", "port").SetInt(p.opts.Port)
+		evt.Field("network", "transport").SetString(transport)
+		evt.Field("network", "protocol").SetString("syslog")
+		if transport == "tcp" && p.opts.TLS != nil {
+			evt.Field("tls", "established").SetBool(true)
+		}
+	case loglang.SchemaFlat:
+		evt.Field("remote_addr").SetString(address)
+		evt.Field("client.ip").SetString(ip)
+		evt.Field("client.port").SetInt(port)
+		evt.Field("server.port").SetInt(p.opts.Port)
+		evt.Field("transport").SetString(transport)
+	}
+
+	return &evt
+}
@@ -0,0 +1,179 @@
+package input
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nicwaller/loglang"
+)
+
+// remembers every event it was asked to send
+type memoryOutput struct {
+	mutex  sync.Mutex
+	events []*loglang.Event
+}
+
+func (p *memoryOutput) Send(_ context.Context, events []*loglang.Event, _ loglang.CodecPlugin, _ loglang.FramingPlugin) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.events = append(p.events, events...)
+	return nil
+}
+
+// waits for count events to arrive, then returns their messages in order
+func (p *memoryOutput) waitForMessages(t *testing.T, count int) ([]string, []*loglang.Event) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		p.mutex.Lock()
+		events := append([]*loglang.Event{}, p.events...)
+		p.mutex.Unlock()
+		if len(events) >= count {
+			messages := make([]string, 0, len(events))
+			for _, evt := range events {
+				messages = append(messages, evt.Field("message").GetString())
+			}
+			sort.Strings(messages)
+			return messages, events
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d events", count)
+	return nil, nil
+}
+
+// starts a pipeline with a syslog input on a free port, and returns the address to send to
+func startSyslog(t *testing.T, opts SyslogOptions) (string, *memoryOutput) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.Port = l.Addr().(*net.TCPAddr).Port
+	_ = l.Close()
+	address := net.JoinHostPort("127.0.0.1", strconv.Itoa(opts.Port))
+
+	p := loglang.NewPipeline("test", loglang.PipelineOptions{})
+	p.Input("syslog", Syslog(opts))
+	out := &memoryOutput{}
+	p.Output("memory", out, nil, nil)
+	done := make(chan error, 1)
+	go func() { done <- p.Run() }()
+	t.Cleanup(func() {
+		p.Stop("test finished")
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+
+	// the TCP listener is opened after the UDP one, so once it accepts, both are ready
+	for start := time.Now(); time.Since(start) < 2*time.Second; time.Sleep(5 * time.Millisecond) {
+		if conn, err := net.Dial("tcp", address); err == nil {
+			_ = conn.Close()
+			return address, out
+		}
+	}
+	t.Fatal("syslog input never started listening")
+	return "", nil
+}
+
+func sendTCP(t *testing.T, address string, stream string) {
+	t.Helper()
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(stream)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyslog_UDP(t *testing.T) {
+	address, out := startSyslog(t, SyslogOptions{Timezone: "UTC"})
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// a trailing linefeed in a datagram isn't part of the message
+	if _, err := conn.Write([]byte("<13>1 2024-03-05T09:07:03Z web-1 app - - - hello udp\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, events := out.waitForMessages(t, 1)
+	if messages[0] != "hello udp" {
+		t.Errorf("expected hello udp but got %q", messages[0])
+	}
+	if actual := events[0].Field("log", "syslog", "hostname").GetString(); actual != "web-1" {
+		t.Errorf("expected web-1 but got %q", actual)
+	}
+	if actual := events[0].Field("network", "transport").GetString(); actual != "udp" {
+		t.Errorf("expected udp but got %q", actual)
+	}
+}
+
+func TestSyslog_TCPNonTransparent(t *testing.T) {
+	address, out := startSyslog(t, SyslogOptions{Protocols: []string{"tcp"}, Timezone: "UTC"})
+	// RFC 3164 and RFC 5424 can be mixed, and CRLF is tolerated
+	sendTCP(t, address, "<13>Mar  1 08:00:00 host3164 app: one\r\n<13>1 - host5424 app - - - two\n")
+
+	messages, events := out.waitForMessages(t, 2)
+	if expected := []string{"one", "two"}; strings.Join(messages, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %q but got %q", expected, messages)
+	}
+	if actual := events[0].Field("network", "transport").GetString(); actual != "tcp" {
+		t.Errorf("expected tcp but got %q", actual)
+	}
+}
+
+func TestSyslog_TCPOctetCounting(t *testing.T) {
+	address, out := startSyslog(t, SyslogOptions{Protocols: []string{"tcp"}, Timezone: "UTC"})
+	// with octet counting, a message can contain a linefeed
+	first := "<13>1 - host app - - - first\nsecond line"
+	last := "<13>Mar  1 08:00:00 host app: last"
+	sendTCP(t, address, fmt.Sprintf("%d %s%d %s", len(first), first, len(last), last))
+
+	messages, _ := out.waitForMessages(t, 2)
+	if expected := []string{"first\nsecond line", "last"}; strings.Join(messages, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %q but got %q", expected, messages)
+	}
+}
+
+func TestSyslog_FramingDetectedForEachConnection(t *testing.T) {
+	address, out := startSyslog(t, SyslogOptions{Protocols: []string{"tcp"}, Timezone: "UTC"})
+	counted := "<13>1 - host app - - - counted"
+	sendTCP(t, address, fmt.Sprintf("%d %s", len(counted), counted))
+	sendTCP(t, address, "<13>1 - host app - - - linefeed\n")
+
+	messages, _ := out.waitForMessages(t, 2)
+	if expected := []string{"counted", "linefeed"}; strings.Join(messages, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %q but got %q", expected, messages)
+	}
+}
+
+func TestSyslog_TLSNeedsTCP(t *testing.T) {
+	config, err := loglang.ParsePipelineConfig([]byte(`
+inputs:
+  - type: syslog
+    options:
+      protocols: [udp]
+      tls: {certFile: server.pem, keyFile: server.key}
+outputs:
+  - type: pipeline
+    options: {address: nowhere}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.Build(); err == nil || !strings.Contains(err.Error(), "tls: only works with tcp") {
+		t.Errorf("expected tls without tcp to be rejected but got %v", err)
+	}
+}
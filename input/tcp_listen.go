@@ -36,22 +36,26 @@ func (p *tcpListener) Run(ctx context.Context, sender loglang.Sender) error {
 		"server.port", strconv.Itoa(p.port),
 	)
 
-	running := true
-	go func() {
-		<-ctx.Done()
-		running = false
-	}()
-
 	ln, err := net.Listen("tcp", ":"+strconv.Itoa(p.port))
 	log.Debug("listening")
 	if err != nil {
 		return err
 	}
+	loglang.InputReady(ctx)
+	// closing the listener is the only way to interrupt Accept()
+	go func() {
+		<-ctx.Done()
+		_ = ln.Close()
+	}()
 
-	for running {
+	for {
 		conn, err := ln.Accept()
+		if ctx.Err() != nil {
+			break
+		}
 		if err != nil {
 			log.Warn("failed accepting tcp connection")
+			continue
 		}
 		// TODO: prepare a better template event, like UDP listener
 		// TODO: is there a Context for TCP connection?
@@ -20,10 +20,15 @@ func UdpListener(port int, opts UdpListenerOptions) loglang.InputPlugin {
 	if opts.Codec == nil {
 		opts.Codec = codec.Auto()
 	}
-	return &udpListener{
+	u := &udpListener{
 		port: port,
 		opts: opts,
 	}
+	// SendRaw extracts with these
+	u.Framing = []loglang.FramingPlugin{opts.Framing}
+	u.Codec = opts.Codec
+	u.Schema = opts.Schema
+	return u
 }
 
 type udpListener struct {
@@ -45,12 +50,6 @@ func (p *udpListener) Run(ctx context.Context, sender loglang.Sender) error {
 		"server.port", strconv.Itoa(p.port),
 	)
 
-	running := true
-	go func() {
-		<-ctx.Done()
-		running = false
-	}()
-
 	schema := p.opts.Schema
 	if schema == loglang.SchemaNotDefined {
 		if pipelineSchema, ok := ctx.Value("schema").(loglang.SchemaModel); ok {
@@ -68,17 +67,23 @@ func (p *udpListener) Run(ctx context.Context, sender loglang.Sender) error {
 		log.Error(err.Error())
 		return err
 	}
-	defer func(conn *net.UDPConn) {
+	loglang.InputReady(ctx)
+	// closing the connection is the only way to interrupt ReadFromUDP()
+	go func() {
+		<-ctx.Done()
 		_ = conn.Close()
-	}(conn)
+	}()
 
-	for running {
+	for {
 		// TODO: we probably need a bigger buffer? maybe 65KB for GELF?
 		var buf [4096]byte
 
 		// PERF: should we have multiple goroutines receiving in parallel?
 		// UDP is not stream based, so we read each individual datagram
 		rlen, addr, err := conn.ReadFromUDP(buf[:])
+		if ctx.Err() != nil {
+			return nil
+		}
 		if err != nil {
 			return err
 		} else {
@@ -91,8 +96,6 @@ func (p *udpListener) Run(ctx context.Context, sender loglang.Sender) error {
 			log.Error("problem in udp listener", "error", err)
 		}
 	}
-
-	return nil
 }
 
 func (p *udpListener) eventTemplate(addr *net.UDPAddr) *loglang.Event {
@@ -0,0 +1,200 @@
+package loglang
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Pipelines in the same process can be linked together, like Logstash pipeline-to-pipeline.
+// A PipelineOutput sends events to the PipelineInput listening on the same address.
+//
+// Each batch sent by the output becomes an E2E batch in the downstream pipeline,
+// and Send() doesn't return until the downstream outputs have finished with it.
+// So a slow downstream pipeline applies back-pressure upstream,
+// and upstream inputs aren't acknowledged until their events are really delivered.
+
+type PipelineInputOptions struct {
+	Address string `yaml:"address"`
+}
+
+type PipelineOutputOptions struct {
+	Address string `yaml:"address"`
+}
+
+func init() {
+	RegisterInput("pipeline", func(opts PipelineInputOptions) (InputPlugin, error) {
+		if opts.Address == "" {
+			return nil, fmt.Errorf("address is required")
+		}
+		return PipelineInput(opts.Address), nil
+	})
+	RegisterOutput("pipeline", func(opts PipelineOutputOptions) (OutputPlugin, error) {
+		if opts.Address == "" {
+			return nil, fmt.Errorf("address is required")
+		}
+		return PipelineOutput(opts.Address), nil
+	})
+}
+
+// the bus is shared by every pipeline in the process
+var bus = &pipelineBus{
+	listeners: make(map[string]*pipelineInput),
+	changed:   make(chan struct{}),
+}
+
+type pipelineBus struct {
+	mutex     sync.Mutex
+	listeners map[string]*pipelineInput
+	// closed (and replaced) whenever a listener comes or goes
+	changed chan struct{}
+}
+
+func (b *pipelineBus) listen(address string, input *pipelineInput) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, exists := b.listeners[address]; exists {
+		return fmt.Errorf("another pipeline input is already listening on %q", address)
+	}
+	b.listeners[address] = input
+	close(b.changed)
+	b.changed = make(chan struct{})
+	return nil
+}
+
+func (b *pipelineBus) unlisten(address string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.listeners, address)
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// lookup waits for an input to listen on address, because the downstream pipeline might still be starting
+func (b *pipelineBus) lookup(ctx context.Context, address string) (*pipelineInput, error) {
+	for {
+		b.mutex.Lock()
+		input, exists := b.listeners[address]
+		changed := b.changed
+		b.mutex.Unlock()
+		if exists {
+			return input, nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("no pipeline input listening on %q: %w", address, context.Cause(ctx))
+		}
+	}
+}
+
+type linkRequest struct {
+	events []*Event
+	result chan<- error
+}
+
+func PipelineInput(address string) InputPlugin {
+	return &pipelineInput{
+		address:  address,
+		requests: make(chan linkRequest),
+	}
+}
+
+type pipelineInput struct {
+	address  string
+	requests chan linkRequest
+	// closed when the input stops accepting requests
+	done chan struct{}
+}
+
+func (p *pipelineInput) Run(ctx context.Context, sender Sender) error {
+	log := ContextLogger(ctx)
+	sender.SetE2E(true)
+	// the upstream output decides how long to wait; failing here while the events
+	// are still being delivered would only cause duplicates when upstream retries
+	sender.SetBatchDeadline(0)
+
+	p.done = make(chan struct{})
+	if err := bus.listen(p.address, p); err != nil {
+		return err
+	}
+	InputReady(ctx)
+	log.Debug("pipeline input listening", "address", p.address)
+
+	// each batch is handled separately, so several upstream pipelines don't wait on each other
+	var handling sync.WaitGroup
+	for ctx.Err() == nil {
+		select {
+		case req := <-p.requests:
+			handling.Add(1)
+			go func() {
+				defer handling.Done()
+				req.result <- batchError(sender.Send(req.events...))
+			}()
+		case <-ctx.Done():
+		}
+	}
+
+	bus.unlisten(p.address)
+	close(p.done)
+	// batches already accepted must still be delivered before the sender closes
+	handling.Wait()
+	return nil
+}
+
+func (p *pipelineInput) Extract(_ context.Context, _ *Event, _ io.Reader, output chan *Event) error {
+	close(output)
+	return fmt.Errorf("pipeline input does not decode byte streams")
+}
+
+// batchError turns an unsuccessful batch into an error for the upstream output.
+// Errors from a batch that was still delivered (eg. a filter error that passed the event on)
+// aren't failures, because retrying upstream would only duplicate events downstream.
+func batchError(result *BatchResult) error {
+	switch {
+	case result == nil || result.Ok:
+		return nil
+	case len(result.Errors) > 0:
+		return fmt.Errorf("downstream pipeline failed: %s: %w", result.Summary(), errors.Join(result.Errors...))
+	}
+	return fmt.Errorf("downstream pipeline failed: %s", result.Summary())
+}
+
+func PipelineOutput(address string) OutputPlugin {
+	return &pipelineOutput{address: address}
+}
+
+type pipelineOutput struct {
+	address string
+}
+
+func (p *pipelineOutput) Send(ctx context.Context, events []*Event, _ CodecPlugin, _ FramingPlugin) error {
+	input, err := bus.lookup(ctx, p.address)
+	if err != nil {
+		return err
+	}
+
+	// the downstream pipeline gets its own copies, with its own E2E batch
+	copies := make([]*Event, 0, len(events))
+	for _, event := range events {
+		dup := event.Copy()
+		copies = append(copies, &dup)
+	}
+
+	result := make(chan error, 1)
+	select {
+	case input.requests <- linkRequest{events: copies, result: result}:
+	case <-input.done:
+		return fmt.Errorf("pipeline input on %q stopped", p.address)
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
@@ -34,4 +34,10 @@ const (
 
 	// ContextKeySchema is the schema used by a pipeline
 	ContextKeySchema ContextKey = "schema"
+
+	// ContextKeyDecodeFailure is the DecodeFailurePolicy of a pipeline
+	ContextKeyDecodeFailure ContextKey = "decodeFailure"
+
+	// ContextKeyMetrics is the *Metrics registry of a pipeline
+	ContextKeyMetrics ContextKey = "metrics"
 )
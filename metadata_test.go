@@ -0,0 +1,70 @@
+package loglang
+
+import (
+	"testing"
+)
+
+func TestEvent_Metadata(t *testing.T) {
+	evt := NewEvent()
+	evt.Field("message").SetString("hello")
+	evt.Field(MetadataField, "route").SetString("alerts")
+	evt.Ref("[@metadata][raw][length]").SetInt(5)
+
+	if _, exists := evt.Fields[MetadataField]; exists {
+		t.Error("metadata must not be stored with the regular fields")
+	}
+	if actual := evt.Metadata["route"]; actual != "alerts" {
+		t.Errorf("expected alerts but got %v", actual)
+	}
+	if actual := evt.Ref("@metadata.raw.length").MustGet(); actual != 5 {
+		t.Errorf("expected 5 but got %v", actual)
+	}
+
+	var visited []string
+	evt.TraverseFields(func(field Field) {
+		visited = append(visited, field.String())
+	})
+	if len(visited) != 1 || visited[0] != "[message]" {
+		t.Errorf("expected only [message] to be visited but got %v", visited)
+	}
+
+	// the whole namespace is an object
+	if all := evt.Field(MetadataField).GetMap(); len(all) != 2 {
+		t.Errorf("expected 2 metadata fields but got %v", all)
+	}
+	evt.Field(MetadataField, "route").Delete()
+	if _, err := evt.Field(MetadataField, "route").Get(); err == nil {
+		t.Error("expected the metadata field to be deleted")
+	}
+	evt.Field(MetadataField).Delete()
+	if evt.Metadata != nil {
+		t.Error("expected all metadata to be deleted")
+	}
+	if err := evt.Field(MetadataField).SetCarefully("nope"); err == nil {
+		t.Error("expected metadata to only be replaced by an object")
+	}
+}
+
+func TestEvent_MetadataIsCopied(t *testing.T) {
+	evt := NewEvent()
+	if _, err := evt.Field(MetadataField, "missing").Get(); err == nil {
+		t.Error("expected missing metadata to be missing")
+	}
+	evt.Field(MetadataField, "route").SetString("a")
+
+	r := evt.replica()
+	r.Field(MetadataField, "route").SetString("b")
+	if actual := evt.Field(MetadataField, "route").GetString(); actual != "a" {
+		t.Errorf("replica must not share metadata, but got %q", actual)
+	}
+
+	merged := NewEvent()
+	merged.Merge(&evt, false)
+	if actual := merged.Field(MetadataField, "route").GetString(); actual != "a" {
+		t.Errorf("expected metadata to be merged from the template but got %q", actual)
+	}
+
+	if fields := evt.FieldsWithMetadata(); fields[MetadataField] == nil {
+		t.Error("expected metadata to be included when asked for")
+	}
+}
@@ -0,0 +1,395 @@
+package loglang
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics is a small registry of counters, gauges and histograms
+// that can be written in the Prometheus text exposition format.
+// Every method is safe to call on a nil *Metrics (and nil metrics) so that
+// plugins don't need to check whether metrics are enabled.
+type Metrics struct {
+	mutex    sync.Mutex
+	families map[string]*metricFamily
+}
+
+type Labels map[string]string
+
+type metricKind string
+
+const (
+	metricCounter   metricKind = "counter"
+	metricGauge     metricKind = "gauge"
+	metricHistogram metricKind = "histogram"
+)
+
+// DefaultBuckets suit things measured in seconds, like batch durations
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// FastBuckets suit things that should take microseconds, like filters
+var FastBuckets = []float64{.00001, .00005, .0001, .0005, .001, .005, .01, .05, .1, .5, 1}
+
+type metricFamily struct {
+	name   string
+	help   string
+	kind   metricKind
+	series map[string]*metricSeries
+}
+
+type metricSeries struct {
+	labels    Labels
+	rendered  string
+	counter   *Counter
+	gauge     func() float64
+	histogram *Histogram
+}
+
+// MetricSample is a single value, as it would appear on a line of /metrics
+type MetricSample struct {
+	Name   string
+	Labels Labels
+	Value  float64
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{families: make(map[string]*metricFamily)}
+}
+
+// Counter finds or creates a counter
+func (m *Metrics) Counter(name string, help string, labels Labels) *Counter {
+	if m == nil {
+		return nil
+	}
+	return m.series(name, help, metricCounter, labels, func(s *metricSeries) {
+		s.counter = &Counter{}
+	}).counter
+}
+
+// GaugeFunc calls fn whenever the gauge is read (eg. to check the length of a channel)
+func (m *Metrics) GaugeFunc(name string, help string, labels Labels, fn func() float64) {
+	if m == nil {
+		return
+	}
+	m.series(name, help, metricGauge, labels, func(s *metricSeries) {}).gauge = fn
+}
+
+// Histogram finds or creates a histogram; buckets are only used when creating it
+func (m *Metrics) Histogram(name string, help string, labels Labels, buckets []float64) *Histogram {
+	if m == nil {
+		return nil
+	}
+	return m.series(name, help, metricHistogram, labels, func(s *metricSeries) {
+		s.histogram = newHistogram(buckets)
+	}).histogram
+}
+
+func (m *Metrics) series(name string, help string, kind metricKind, labels Labels, create func(*metricSeries)) *metricSeries {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	family, exists := m.families[name]
+	if !exists {
+		family = &metricFamily{
+			name:   name,
+			help:   help,
+			kind:   kind,
+			series: make(map[string]*metricSeries),
+		}
+		m.families[name] = family
+	} else if family.kind != kind {
+		panic(fmt.Sprintf("metric %s is a %s, not a %s", name, family.kind, kind))
+	}
+	rendered := renderLabels(labels)
+	series, exists := family.series[rendered]
+	if !exists {
+		series = &metricSeries{labels: labels, rendered: rendered}
+		create(series)
+		family.series[rendered] = series
+	}
+	return series
+}
+
+// Snapshot reads every metric. Histograms appear as _bucket, _sum and _count samples, like in /metrics
+func (m *Metrics) Snapshot() []MetricSample {
+	if m == nil {
+		return nil
+	}
+	var samples []MetricSample
+	m.each(func(family *metricFamily, series *metricSeries) {
+		samples = append(samples, series.samples(family.name)...)
+	})
+	return samples
+}
+
+// Get reads a single counter or gauge; useful for tests
+func (m *Metrics) Get(name string, labels Labels) (float64, bool) {
+	if m == nil {
+		return 0, false
+	}
+	rendered := renderLabels(labels)
+	for _, sample := range m.Snapshot() {
+		if sample.Name == name && renderLabels(sample.Labels) == rendered {
+			return sample.Value, true
+		}
+	}
+	return 0, false
+}
+
+// ServeHTTP writes every metric in the Prometheus text format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	out := bufio.NewWriter(w)
+	var lastFamily *metricFamily
+	m.each(func(family *metricFamily, series *metricSeries) {
+		if family != lastFamily {
+			_, _ = fmt.Fprintf(out, "# HELP %s %s\n", family.name, escapeHelp(family.help))
+			_, _ = fmt.Fprintf(out, "# TYPE %s %s\n", family.name, family.kind)
+			lastFamily = family
+		}
+		for _, sample := range series.samples(family.name) {
+			_, _ = fmt.Fprintf(out, "%s%s %s\n", sample.Name, renderLabels(sample.Labels), formatFloat(sample.Value))
+		}
+	})
+	_ = out.Flush()
+}
+
+// each visits every series in a stable order
+func (m *Metrics) each(fn func(*metricFamily, *metricSeries)) {
+	m.mutex.Lock()
+	families := make([]*metricFamily, 0, len(m.families))
+	for _, family := range m.families {
+		families = append(families, family)
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+	type visit struct {
+		family *metricFamily
+		series *metricSeries
+	}
+	var visits []visit
+	for _, family := range families {
+		keys := make([]string, 0, len(family.series))
+		for key := range family.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			visits = append(visits, visit{family, family.series[key]})
+		}
+	}
+	m.mutex.Unlock()
+
+	// gauge functions are called without holding the lock
+	for _, v := range visits {
+		fn(v.family, v.series)
+	}
+}
+
+func (s *metricSeries) samples(name string) []MetricSample {
+	switch {
+	case s.counter != nil:
+		return []MetricSample{{Name: name, Labels: s.labels, Value: float64(s.counter.Value())}}
+	case s.gauge != nil:
+		return []MetricSample{{Name: name, Labels: s.labels, Value: s.gauge()}}
+	case s.histogram != nil:
+		return s.histogram.samples(name, s.labels)
+	}
+	return nil
+}
+
+type Counter struct {
+	value atomic.Int64
+}
+
+func (c *Counter) Add(n int) {
+	if c == nil {
+		return
+	}
+	c.value.Add(int64(n))
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+func (c *Counter) Value() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.value.Load()
+}
+
+type Histogram struct {
+	mutex  sync.Mutex
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	bounds := append([]float64(nil), buckets...)
+	sort.Float64s(bounds)
+	return &Histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)),
+	}
+}
+
+func (h *Histogram) Observe(value float64) {
+	if h == nil {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	// counts are stored per bucket, and made cumulative when read
+	i := sort.SearchFloat64s(h.bounds, value)
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.sum += value
+	h.count++
+}
+
+func (h *Histogram) ObserveDuration(d time.Duration) {
+	h.Observe(d.Seconds())
+}
+
+func (h *Histogram) samples(name string, labels Labels) []MetricSample {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	samples := make([]MetricSample, 0, len(h.bounds)+3)
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		samples = append(samples, MetricSample{
+			Name:   name + "_bucket",
+			Labels: withLabel(labels, "le", formatFloat(bound)),
+			Value:  float64(cumulative),
+		})
+	}
+	samples = append(samples,
+		MetricSample{Name: name + "_bucket", Labels: withLabel(labels, "le", "+Inf"), Value: float64(h.count)},
+		MetricSample{Name: name + "_sum", Labels: labels, Value: h.sum},
+		MetricSample{Name: name + "_count", Labels: labels, Value: float64(h.count)},
+	)
+	return samples
+}
+
+func withLabel(labels Labels, key string, value string) Labels {
+	combined := make(Labels, len(labels)+1)
+	for k, v := range labels {
+		combined[k] = v
+	}
+	combined[key] = value
+	return combined
+}
+
+func renderLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	sb.WriteString("{")
+	for i, key := range keys {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(key)
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabel(labels[key]))
+		sb.WriteString(`"`)
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// the pipeline puts its registry in the context, so plugins deep inside can find it
+// (the same way they find the logger)
+
+// ContextMetrics returns the metrics registry of the pipeline, or nil
+func ContextMetrics(ctx context.Context) *Metrics {
+	m, _ := ctx.Value(ContextKeyMetrics).(*Metrics)
+	return m
+}
+
+// pluginLabels identifies the pipeline and plugin that a metric belongs to
+func pluginLabels(ctx context.Context, kind string) Labels {
+	labels := Labels{"kind": kind}
+	if pipeline, ok := ctx.Value(ContextKeyPipelineName).(string); ok {
+		labels["pipeline"] = pipeline
+	}
+	if plugin, ok := ctx.Value(ContextKeyPluginName).(string); ok {
+		labels["plugin"] = plugin
+	}
+	return labels
+}
+
+// filters are named by the chain they're in (the pipeline, or an input or output) as well as their own name
+func filterLabels(ctx context.Context, filter string) Labels {
+	labels := pluginLabels(ctx, "filter")
+	labels["chain"] = labels["plugin"]
+	labels["plugin"] = filter
+	return labels
+}
+
+const (
+	helpEventsIn      = "Events received by a plugin"
+	helpEventsOut     = "Events passed on (or delivered) by a plugin"
+	helpEventsDropped = "Events dropped by a plugin"
+	helpEventsErrored = "Events that a plugin failed to handle"
+)
+
+// the standard counters for any plugin that events flow through
+type pluginCounters struct {
+	in      *Counter
+	out     *Counter
+	dropped *Counter
+	errored *Counter
+}
+
+func newPluginCounters(m *Metrics, labels Labels) pluginCounters {
+	return pluginCounters{
+		in:      m.Counter("loglang_events_in_total", helpEventsIn, labels),
+		out:     m.Counter("loglang_events_out_total", helpEventsOut, labels),
+		dropped: m.Counter("loglang_events_dropped_total", helpEventsDropped, labels),
+		errored: m.Counter("loglang_events_errored_total", helpEventsErrored, labels),
+	}
+}
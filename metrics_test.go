@@ -0,0 +1,92 @@
+package loglang
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics_TextFormat(t *testing.T) {
+	m := NewMetrics()
+	m.Counter("test_events_total", "Events\nseen", Labels{"plugin": `say "hi"`}).Add(3)
+	m.GaugeFunc("test_depth", "Depth", nil, func() float64 { return 2 })
+	h := m.Histogram("test_seconds", "Durations", Labels{"plugin": "x"}, []float64{0.1, 1})
+	h.ObserveDuration(50 * time.Millisecond)
+	h.ObserveDuration(500 * time.Millisecond)
+	h.ObserveDuration(5 * time.Second)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	expected := []string{
+		"# HELP test_events_total Events\\nseen\n",
+		"# TYPE test_events_total counter\n",
+		`test_events_total{plugin="say \"hi\""} 3` + "\n",
+		"# TYPE test_depth gauge\ntest_depth 2\n",
+		"# TYPE test_seconds histogram\n",
+		`test_seconds_bucket{le="0.1",plugin="x"} 1` + "\n",
+		`test_seconds_bucket{le="1",plugin="x"} 2` + "\n",
+		`test_seconds_bucket{le="+Inf",plugin="x"} 3` + "\n",
+		`test_seconds_sum{plugin="x"} 5.55` + "\n",
+		`test_seconds_count{plugin="x"} 3` + "\n",
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line) {
+			t.Errorf("expected %q in:\n%s", line, body)
+		}
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+}
+
+func TestMetrics_NilSafe(t *testing.T) {
+	var m *Metrics
+	m.Counter("nothing", "", nil).Inc()
+	m.Histogram("nothing", "", nil, nil).Observe(1)
+	m.GaugeFunc("nothing", "", nil, func() float64 { return 0 })
+	if len(m.Snapshot()) != 0 {
+		t.Error("expected no samples")
+	}
+}
+
+func TestPipeline_Metrics(t *testing.T) {
+	p := NewPipeline("test", PipelineOptions{})
+	p.Input("slice", &sliceInput{events: messageEvents("keep", "drop", "keep")})
+	p.Filter("drop some", func(event *Event, inject chan<- *Event, drop func()) error {
+		if event.Field("message").GetString() == "drop" {
+			drop()
+		}
+		return nil
+	})
+	p.Output("memory", &memoryOutput{}, nil, nil)
+
+	if err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		labels   Labels
+		expected float64
+	}{
+		{"loglang_events_out_total", Labels{"pipeline": "test", "kind": "input", "plugin": "slice"}, 3},
+		{"loglang_events_in_total", Labels{"pipeline": "test", "kind": "filter", "chain": "pipeline", "plugin": "drop some"}, 3},
+		{"loglang_events_dropped_total", Labels{"pipeline": "test", "kind": "filter", "chain": "pipeline", "plugin": "drop some"}, 1},
+		{"loglang_events_out_total", Labels{"pipeline": "test", "kind": "filter", "chain": "pipeline", "plugin": "drop some"}, 2},
+		{"loglang_events_in_total", Labels{"pipeline": "test", "kind": "output", "plugin": "memory"}, 2},
+		{"loglang_events_out_total", Labels{"pipeline": "test", "kind": "output", "plugin": "memory"}, 2},
+		{"loglang_filter_duration_seconds_count", Labels{"pipeline": "test", "kind": "filter", "chain": "pipeline", "plugin": "drop some"}, 3},
+		{"loglang_batch_duration_seconds_count", Labels{"pipeline": "test", "kind": "input", "plugin": "slice"}, 1},
+	}
+	for _, tt := range tests {
+		value, found := p.Metrics().Get(tt.name, tt.labels)
+		if !found {
+			t.Errorf("missing %s%s", tt.name, renderLabels(tt.labels))
+		} else if value != tt.expected {
+			t.Errorf("expected %s%s to be %v but got %v", tt.name, renderLabels(tt.labels), tt.expected, value)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package output
+
+import (
+	"fmt"
+	"github.com/nicwaller/loglang"
+)
+
+func init() {
+	loglang.RegisterOutput("stdout", func(opts StdoutOptions) (loglang.OutputPlugin, error) {
+		return StdOut(opts), nil
+	})
+	loglang.RegisterOutput("slack", func(opts SlackOptions) (loglang.OutputPlugin, error) {
+		if opts.BotToken == "" {
+			return nil, fmt.Errorf("botToken is required")
+		}
+		return Slack(opts), nil
+	})
+}
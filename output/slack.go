@@ -46,27 +46,21 @@ func Slack(opts SlackOptions) loglang.OutputPlugin {
 }
 
 type slackOutput struct {
-	opts         SlackOptions
-	sendFailures int
+	opts SlackOptions
 }
 
 type SlackOptions struct {
-	BotToken        string
-	ApiRoot         string
-	FallbackChannel string
-	IconEmoji       string
-	IconUrl         string
-	DetailFields    bool
+	BotToken        string `yaml:"botToken"`
+	ApiRoot         string `yaml:"apiRoot"`
+	FallbackChannel string `yaml:"fallbackChannel"`
+	IconEmoji       string `yaml:"iconEmoji"`
+	IconUrl         string `yaml:"iconUrl"`
+	DetailFields    bool   `yaml:"detailFields"`
 }
 
 func (p *slackOutput) Send(ctx context.Context, events []*loglang.Event, cp loglang.CodecPlugin, fp loglang.FramingPlugin) error {
 	// TODO: validate token immediately
-	// TODO: outputs should use context and process more than one event at a time
-
-	if p.sendFailures > 3 {
-		time.Sleep(time.Second)
-		return fmt.Errorf("gave up posting to Slack after %d tries", p.sendFailures)
-	}
+	// retries are handled by the pipeline; use loglang.RetryOptions
 
 	for _, event := range events {
 		if err := p.sendOne(ctx, event); err != nil {
@@ -84,6 +78,7 @@ func (p *slackOutput) sendOne(ctx context.Context, event *loglang.Event) error {
 	)
 
 	message := loglang.CoalesceStr(
+		event.Field(loglang.MetadataField, "slack", "message").GetString(),
 		event.Field("slack", "message").GetString(),
 		event.Field("slack.message").GetString(),
 		event.Field("message").GetString(),
@@ -98,13 +93,14 @@ func (p *slackOutput) sendOne(ctx context.Context, event *loglang.Event) error {
 	}
 
 	channel := loglang.CoalesceStr(
+		event.Field(loglang.MetadataField, "slack", "channel").GetString(),
 		event.Field("slack", "channel").GetString(),
 		event.Field("slack.channel").GetString(),
 		event.Field("channel").GetString(),
 		p.opts.FallbackChannel,
 	)
 	if channel == "" {
-		return fmt.Errorf("cannot send to Slack because no channel was selected")
+		return loglang.Permanent(fmt.Errorf("cannot send to Slack because no channel was selected"))
 	}
 
 	level := loglang.CoalesceStr(
@@ -114,6 +110,7 @@ func (p *slackOutput) sendOne(ctx context.Context, event *loglang.Event) error {
 	)
 	autoEmojiStr := levelToEmoji[level]
 	emojiStr := loglang.CoalesceStr(
+		event.Field(loglang.MetadataField, "slack", "emoji").GetString(),
 		event.Field("slack", "emoji").GetString(),
 		event.Field("slack.emoji").GetString(),
 		autoEmojiStr,
@@ -142,6 +139,7 @@ func (p *slackOutput) sendOne(ctx context.Context, event *loglang.Event) error {
 	}
 
 	username := loglang.CoalesceStr(
+		event.Field(loglang.MetadataField, "slack", "username").GetString(),
 		event.Field("slack", "username").GetString(),
 		event.Field("slack.username").GetString(),
 		event.Field("user", "full_name").GetString(),
@@ -152,6 +150,7 @@ func (p *slackOutput) sendOne(ctx context.Context, event *loglang.Event) error {
 	)
 
 	icon_emoji := loglang.CoalesceStr(
+		event.Field(loglang.MetadataField, "slack", "icon_emoji").GetString(),
 		event.Field("slack", "icon_emoji").GetString(),
 		event.Field("slack", "icon").GetString(),
 		event.Field("slack.icon").GetString(),
@@ -162,6 +161,7 @@ func (p *slackOutput) sendOne(ctx context.Context, event *loglang.Event) error {
 	}
 
 	icon_url := loglang.CoalesceStr(
+		event.Field(loglang.MetadataField, "slack", "icon_url").GetString(),
 		event.Field("slack", "icon_url").GetString(),
 		p.opts.IconUrl,
 	)
@@ -186,8 +186,7 @@ func (p *slackOutput) sendOne(ctx context.Context, event *loglang.Event) error {
 		}
 		if field.Path[0] == "slack" || strings.HasPrefix(field.Path[0], "slack.") {
 			// ignore fields that cause special behaviour for Slack
-			// TODO: should it be @slack instead?
-			// or maybe [@metadata][slack][emoji]?
+			// these still work, but [@metadata][slack] is better because codecs never see it
 			return
 		}
 		val := field.GetString()
@@ -256,14 +255,14 @@ func (p *slackOutput) api(ctx context.Context, method SlackApiMethod, payload an
 
 	// Create a HTTP post request
 	posturl := fmt.Sprintf("%s%s", p.opts.ApiRoot, method)
-	req, err := http.NewRequest("POST", posturl, bytes.NewBuffer(encodedPayload))
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", p.opts.BotToken))
-	req.Header.Add("Content-Type", "application/json; charset=utf-8") // essential for Slack
+	req, err := http.NewRequestWithContext(ctx, "POST", posturl, bytes.NewBuffer(encodedPayload))
 	if err != nil {
-		p.sendFailures++
 		log.Error("failed posting to slack", "error", err)
-		return slackApiResponse{}, fmt.Errorf("failed posting to Slack: %w", err)
+		// a bad request will be just as bad next time
+		return slackApiResponse{}, loglang.Permanent(fmt.Errorf("failed posting to Slack: %w", err))
 	}
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", p.opts.BotToken))
+	req.Header.Add("Content-Type", "application/json; charset=utf-8") // essential for Slack
 
 	// Send it
 	client := &http.Client{
@@ -271,25 +270,28 @@ func (p *slackOutput) api(ctx context.Context, method SlackApiMethod, payload an
 	}
 	res, err := client.Do(req)
 	if err != nil {
-		p.sendFailures++
 		log.Error("failed posting to slack", "error", err)
 		return slackApiResponse{}, fmt.Errorf("failed posting to Slack: %w", err)
 	}
 	bbbb, _ := io.ReadAll(res.Body)
+	_ = res.Body.Close()
 
 	var resp slackApiResponse
 	err = json.Unmarshal(bbbb, &resp)
 	if err != nil {
-		p.sendFailures++
-		return resp, fmt.Errorf("that's weird... we failed to unmarshal response from Slack API")
+		// probably an HTML error page from a load balancer; worth trying again
+		return resp, fmt.Errorf("that's weird... we failed to unmarshal response from Slack API (HTTP %d)", res.StatusCode)
 	}
 
 	if !resp.Ok {
-		// TODO: validate Slack bot token much earlier
-		log.Error("Slack API rejected our message. This is usually due to a missing or incorrect BOT_TOKEN.")
 		log.Debug(resp.Warning)
 		log.Debug(resp.Error)
-		return resp, fmt.Errorf("Slack API rejected our message. This is usually due to a missing or incorrect BOT_TOKEN.")
+		if resp.Error == "ratelimited" || res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500 {
+			return resp, fmt.Errorf("Slack API is unavailable: %s", resp.Error)
+		}
+		// TODO: validate Slack bot token much earlier
+		log.Error("Slack API rejected our message. This is usually due to a missing or incorrect BOT_TOKEN.")
+		return resp, loglang.Permanent(fmt.Errorf("Slack API rejected our message: %s", resp.Error))
 	}
 
 	return resp, nil
@@ -3,6 +3,8 @@ package output
 import (
 	"context"
 	"github.com/nicwaller/loglang"
+	"github.com/nicwaller/loglang/codec"
+	"github.com/nicwaller/loglang/framing"
 	"log/slog"
 	"os"
 	"strconv"
@@ -25,6 +27,14 @@ func (p *stdOut) Send(ctx context.Context, events []*loglang.Event, cp loglang.C
 	ctx = context.WithValue(ctx, loglang.ContextKeyPluginType, "stdout")
 	log := loglang.ContextLogger(ctx)
 
+	// outputs built from config might not specify these
+	if cp == nil {
+		cp = codec.Json()
+	}
+	if fp == nil {
+		fp = framing.Lines()
+	}
+
 	encodedEvents := make(chan []byte)
 	framedEvents := make(chan []byte)
 
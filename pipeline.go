@@ -3,7 +3,9 @@ package loglang
 import (
 	"context"
 	"fmt"
+	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,6 +19,12 @@ func NewPipeline(name string, options PipelineOptions) *Pipeline {
 	if options.StalledInputThreshold == 0 {
 		options.StalledInputThreshold = 24 * time.Hour
 	}
+	if options.DrainTimeout == 0 {
+		options.DrainTimeout = 30 * time.Second
+	}
+	if options.FailedOutputThreshold == 0 {
+		options.FailedOutputThreshold = 3
+	}
 
 	var p Pipeline
 
@@ -27,11 +35,21 @@ func NewPipeline(name string, options PipelineOptions) *Pipeline {
 	p.ctx, p.stop = context.WithCancelCause(p.ctx)
 	p.ctx = context.WithValue(p.ctx, ContextKeyPipelineName, p.GetName())
 	p.ctx = context.WithValue(p.ctx, ContextKeySchema, p.opts.Schema)
+	p.ctx = context.WithValue(p.ctx, ContextKeyDecodeFailure, p.opts.DecodeFailure)
 	p.ctx = context.WithValue(p.ctx, ContextKeyPluginName, "pipeline")
+	// pipelines in the same process can share one registry
+	p.metrics = options.Metrics
+	if p.metrics == nil {
+		p.metrics = NewMetrics()
+	}
+	p.ctx = context.WithValue(p.ctx, ContextKeyMetrics, p.metrics)
+	p.status = newPluginStatus()
+	// inputs are stopped first, so everything else can drain
+	p.inputCtx, p.stopInputs = context.WithCancelCause(p.ctx)
 
 	p.Filter("default @timestamp", func(event *Event, inject chan<- *Event, drop func()) error {
 		// PERF: maybe don't allocate a new field each time?
-		event.Field("@timestamp").Default(time.Now().Format(time.RFC3339))
+		event.Field("@timestamp").Default(time.Now())
 		return nil
 	})
 
@@ -46,7 +64,7 @@ func NewPipeline(name string, options PipelineOptions) *Pipeline {
 
 		p.Filter("mark ingestion time", func(event *Event, events chan<- *Event, drop func()) error {
 			f.original = event
-			f.Default(time.Now().Format(time.RFC3339))
+			f.Default(time.Now())
 			return nil
 		})
 	}
@@ -65,32 +83,108 @@ type Pipeline struct {
 	filters []NamedEntity[FilterPlugin]
 	outputs []NamedEntity[OutputConfig]
 	opts    PipelineOptions
-	ctx     context.Context
-	stop    context.CancelCauseFunc
+
+	deadLetter      *NamedEntity[OutputConfig]
+	deadLetterMutex sync.Mutex
+
+	stallOutput      *NamedEntity[OutputConfig]
+	stallOutputMutex sync.Mutex
+
+	metrics *Metrics
+	status  *pluginStatus
+
+	// ctx is cancelled at the very end; everything stops right away
+	ctx  context.Context
+	stop context.CancelCauseFunc
+	// inputCtx is cancelled first, when draining begins
+	inputCtx   context.Context
+	stopInputs context.CancelCauseFunc
 }
 
 type OutputConfig struct {
 	output  OutputPlugin
 	framing FramingPlugin
 	codec   CodecPlugin
+	opts    OutputOptions
+	status  *pluginStatus
+}
+
+type OutputOptions struct {
+	// Filters run after fan-out, on a private copy of the event
+	Filters []NamedEntity[FilterPlugin]
+	// Batch controls how many events are given to OutputPlugin.Send() at once
+	Batch BatchOptions
+	// Retry controls what happens when OutputPlugin.Send() fails
+	Retry RetryOptions
+	// Route decides which events go to this output; nil means all of them
+	Route Condition
+}
+
+// BatchOptions decide when a batch of events is sent to an output.
+// Whichever limit is reached first causes the batch to be sent.
+// The zero value sends every event by itself, right away.
+type BatchOptions struct {
+	// MaxEvents is the most events in a single batch
+	MaxEvents int `yaml:"maxEvents"`
+	// MaxBytes is the most bytes in a single batch, measured by encoding each event with the output codec
+	// a single event larger than this is still sent, in a batch by itself
+	MaxBytes int `yaml:"maxBytes"`
+	// Linger is the longest time to wait for a batch to fill up
+	Linger time.Duration `yaml:"linger"`
+}
+
+func (o BatchOptions) withDefaults() BatchOptions {
+	if o.MaxEvents <= 0 {
+		if o.MaxBytes > 0 {
+			o.MaxEvents = math.MaxInt32
+		} else {
+			o.MaxEvents = 1
+		}
+	}
+	if o.Linger <= 0 {
+		o.Linger = time.Second
+	}
+	return o
 }
 
 type PipelineOptions struct {
-	StalledInputThreshold  time.Duration
-	StalledOutputThreshold time.Duration
-	MarkIngestionTime      bool
-	Schema                 SchemaModel
+	// StalledInputThreshold is how long an input can go without sending events before it's considered stalled
+	StalledInputThreshold time.Duration `yaml:"stalledInputThreshold"`
+	// StalledOutputThreshold is how long an output can have events waiting without finishing any
+	StalledOutputThreshold time.Duration `yaml:"stalledOutputThreshold"`
+	// FailedOutputThreshold is how many batches in a row an output can fail (after retries) before it's considered failed
+	FailedOutputThreshold int `yaml:"failedOutputThreshold"`
+	// StallStopAfter stops the pipeline when a stall lasts this long (after it was detected); 0 means never
+	StallStopAfter    time.Duration `yaml:"stallStopAfter"`
+	MarkIngestionTime bool          `yaml:"markIngestionTime"`
+	Schema            SchemaModel   `yaml:"schema"`
+	// DecodeFailure is what inputs do with frames they can't decode; defaults to DecodeFailureFallback
+	DecodeFailure DecodeFailurePolicy `yaml:"decodeFailure"`
+	// Queue persists events between inputs and filters; disabled by default
+	Queue QueueOptions `yaml:"queue"`
+	// DrainTimeout is how long Stop() waits for events in flight to reach outputs
+	DrainTimeout time.Duration `yaml:"drainTimeout"`
+	// AdminAddress is where to serve /metrics (eg. ":9600"); disabled by default
+	AdminAddress string `yaml:"adminAddress"`
+	// Metrics is the registry to use, so it can be shared; NewPipeline makes one if nil
+	Metrics *Metrics `yaml:"-"`
 }
 
 type inputDetail struct {
 	plugin      InputPlugin
 	filterChain []NamedEntity[FilterPlugin]
+	status      *pluginStatus
 }
 
 func (p *Pipeline) GetName() string {
 	return p.Name
 }
 
+// Metrics has counters for every plugin in the pipeline; the same ones that are served at /metrics
+func (p *Pipeline) Metrics() *Metrics {
+	return p.metrics
+}
+
 func (p *Pipeline) Run() error {
 	if len(p.outputs) == 0 {
 		return fmt.Errorf("no outputs configured")
@@ -105,61 +199,167 @@ func (p *Pipeline) Run() error {
 	log := ContextLogger(p.ctx)
 	log.Info("Starting Pipeline")
 
+	if p.opts.AdminAddress != "" {
+		admin, err := p.startAdmin(p.opts.AdminAddress)
+		if err != nil {
+			return fmt.Errorf("cannot start admin server: %w", err)
+		}
+		defer admin.Close()
+	}
+
 	// all inputs are multiplexed to a single channel before going through filters
 	preFilter := make(chan *Event)
 	// a single output channel does fan-out to all outputs
 	postFilter := make(chan *Event)
 
-	go PumpFilterList(p.ctx, p.stop, preFilter, postFilter, p.filters, true)
-	go p.runOutputs(postFilter)
-	go p.runInputs(preFilter)
+	// shutdown happens in two phases:
+	// first the inputs are stopped, and closing their channels drains each stage in turn
+	// when every output has drained, (or the drain timeout is reached) everything else is stopped
+	var finished sync.WaitGroup
+
+	// with a persistent queue, inputs are acknowledged once their events are on disk
+	filterInput := preFilter
+	if p.opts.Queue.Path != "" {
+		queue, err := openDiskQueue(p.opts.Queue)
+		if err != nil {
+			return fmt.Errorf("cannot open queue: %w", err)
+		}
+		filterInput = make(chan *Event)
+		finished.Add(1)
+		go func() {
+			defer finished.Done()
+			queue.run(p.ctx, p.stop, preFilter, filterInput)
+		}()
+	}
+
+	go func() {
+		pumpFilterList(p.ctx, p.stop, filterInput, postFilter, p.filters, p.deadLetterReporter(ackReporter(true)))
+		close(postFilter)
+	}()
+	progress := make([]outputProgress, len(p.outputs))
+	outputsDrained := make(chan struct{})
+	go func() {
+		p.runOutputs(postFilter, progress)
+		close(outputsDrained)
+	}()
+	watches := append(p.inputWatches(), p.outputWatches(progress)...)
+	go p.runInputs(preFilter, watches)
+	p.status.set(StateRunning, nil)
+
+	var err error
+	select {
+	case <-outputsDrained:
+	case <-p.ctx.Done():
+	case <-p.inputCtx.Done():
+		p.status.set(StateDraining, context.Cause(p.inputCtx))
+		log.Info("draining pipeline", "cause", context.Cause(p.inputCtx), "timeout", p.opts.DrainTimeout)
+		select {
+		case <-outputsDrained:
+			log.Info("pipeline drained")
+		case <-p.ctx.Done():
+		case <-time.After(p.opts.DrainTimeout):
+			err = p.reportLost(progress)
+		}
+	}
+
+	cause := context.Cause(p.inputCtx)
+	if cause == nil {
+		cause = fmt.Errorf("pipeline finished")
+	}
+	p.stop(cause)
+	finished.Wait()
+	p.status.set(StateStopped, context.Cause(p.ctx))
 
-	<-p.ctx.Done()
 	log.Info("Pipeline Finished", "cause", context.Cause(p.ctx))
-	return nil
+	return err
 }
 
+// Stop the pipeline gracefully. Inputs stop right away, then events already
+// in the pipeline have until DrainTimeout to reach the outputs.
 func (p *Pipeline) Stop(reason string) {
 	cause := fmt.Errorf("pipeline stop requested: %s", reason)
-	p.stop(cause)
+	p.stopInputs(cause)
 }
 
-func (p *Pipeline) runInputs(combinedInputs chan *Event) {
+// counts events going through each output, so we know what's lost if draining takes too long
+type outputProgress struct {
+	received atomic.Int64
+	finished atomic.Int64
+}
+
+func (p *Pipeline) reportLost(progress []outputProgress) error {
+	log := ContextLogger(p.ctx)
+	lost := 0
+	for i := range progress {
+		pending := int(progress[i].received.Load() - progress[i].finished.Load())
+		if pending > 0 {
+			log.Error("abandoning events after drain timeout",
+				"output", p.outputs[i].Name,
+				"count", pending)
+			lost += pending
+		}
+	}
+	// events still in filters (before fan-out) aren't counted here
+	return fmt.Errorf("drain timed out after %v; abandoned %d events in outputs", p.opts.DrainTimeout, lost)
+}
+
+func (p *Pipeline) runInputs(combinedInputs chan *Event, watches []*stallWatch) {
 	var allInputsComplete sync.WaitGroup
+	// the watchdog can inject warnings, so it must stop before combinedInputs is closed
+	stopWatchdog := p.startWatchdog(watches, combinedInputs)
 
 	for _, entity := range p.inputs {
-		pluginContext := context.WithValue(p.ctx, ContextKeyPluginName, entity.Name)
-		go func() {
-			p.runInput(pluginContext, p.stop, entity, combinedInputs)
-			allInputsComplete.Done()
-		}()
 		allInputsComplete.Add(1)
+		go func(entity NamedEntity[inputDetail]) {
+			defer allInputsComplete.Done()
+			p.runInput(entity, combinedInputs)
+		}(entity)
 	}
 
 	allInputsComplete.Wait()
-	p.stop(fmt.Errorf("all inputs complete"))
+	p.stopInputs(fmt.Errorf("all inputs complete"))
+	stopWatchdog()
+	// every input has drained into combinedInputs, so the rest of the pipeline can drain too
+	close(combinedInputs)
 }
 
-func (p *Pipeline) runInput(ctx context.Context, stop context.CancelCauseFunc, input NamedEntity[inputDetail], output chan *Event) {
+func (p *Pipeline) runInput(input NamedEntity[inputDetail], output chan *Event) {
+	// the input plugin stops when draining begins, but its filters keep going until drained
+	ctx := context.WithValue(p.inputCtx, ContextKeyPluginName, input.Name)
+	status := input.Value.status
+	ctx = context.WithValue(ctx, contextKeyStatus, status)
+	filterCtx := context.WithValue(p.ctx, ContextKeyPluginName, input.Name)
 	log := ContextLogger(ctx)
 	log.Info("Starting Input")
 
 	preFilter := make(chan *Event)
 	postFilter := output
 
-	go PumpFilterList(ctx, p.stop, preFilter, postFilter, input.Value.filterChain, false)
+	filtersDrained := make(chan struct{})
+	go func() {
+		pumpFilterList(filterCtx, p.stop, preFilter, postFilter, input.Value.filterChain, p.deadLetterReporter(nil))
+		close(filtersDrained)
+	}()
 
 	sender := NewSender(ctx, preFilter, input.Value.plugin.Extract, len(p.outputs))
+	sender.keepOriginals = p.deadLetter != nil
+	status.attachPendingBatches(sender.pendingBatches.Load)
 	err := input.Value.plugin.Run(ctx, sender)
 	if err != nil {
-		stop(fmt.Errorf("input failed: %w", err))
+		status.recordError(err)
+		status.set(StateFailed, err)
+		p.stopInputs(fmt.Errorf("input failed: %w", err))
 		log.Error("input failed", "error", err)
 	} else {
+		status.set(StateStopped, context.Cause(ctx))
 		log.Info("input stopped", "cause", context.Cause(ctx))
 	}
+
+	sender.Close()
+	<-filtersDrained
 }
 
-func (p *Pipeline) runOutputs(events chan *Event) {
+func (p *Pipeline) runOutputs(events chan *Event, progress []outputProgress) {
 	log := ContextLogger(p.ctx)
 	log.Debug("starting outputs")
 
@@ -171,37 +371,153 @@ func (p *Pipeline) runOutputs(events chan *Event) {
 		outputChannels[i] = make(chan *Event, 1)
 	}
 
-	var countOutputs uint32 = uint32(len(p.outputs))
+	var outputsDrained sync.WaitGroup
+	counters := make([]pluginCounters, len(p.outputs))
 
 	for i, namedOutput := range p.outputs {
 		pluginCtx := context.WithValue(p.ctx, ContextKeyPluginName, namedOutput.Name)
 		// TODO: create a context for the output plugin with the plugin name
 		log := ContextLogger(p.ctx)
 
+		outputName := namedOutput.Name
 		outputCfg := namedOutput.Value
-		soloChan := outputChannels[i]
+		status := outputCfg.status
+		queued := outputChannels[i]
+		soloChan := queued
+		finished := &progress[i].finished
+
+		labels := pluginLabels(pluginCtx, "output")
+		counters[i] = newPluginCounters(p.metrics, labels)
+		outputCounters := counters[i]
+		sendDuration := p.metrics.Histogram("loglang_output_send_duration_seconds", "Time for an output to send a batch, including retries", labels, DefaultBuckets)
+		p.metrics.GaugeFunc("loglang_channel_depth", "Events waiting in a channel between pipeline stages",
+			labels, func() float64 { return float64(len(queued)) })
+
+		if len(outputCfg.opts.Filters) > 0 {
+			soloChan = p.runOutputFilters(pluginCtx, soloChan, outputCfg.opts.Filters, finished, outputCounters)
+		}
+
+		// measuring batches by bytes means encoding each event an extra time
+		sizeOf := func(event *Event) int {
+			if outputCfg.codec == nil {
+				return 0
+			}
+			dat, err := outputCfg.codec.Encode(*event)
+			if err != nil {
+				return 0
+			}
+			return len(dat)
+		}
 
 		log.Info("starting output")
-		go PumpToFunction(pluginCtx, p.stop, soloChan, func(event *Event) error {
-			// TODO: this is the opportunity to buffer and send several events at once
-			err := outputCfg.output.Send(pluginCtx, []*Event{event}, outputCfg.codec, outputCfg.framing)
-			// E2E handling
-			if event.batch != nil {
+		outputsDrained.Add(1)
+		// batches are flushed when the channel closes, so nothing is left behind while draining
+		// framing (eg. gzip) is finished by every Send(), so there's nothing else to flush
+		go func() {
+			defer outputsDrained.Done()
+			status.set(StateRunning, nil)
+			defer func() {
+				status.set(StateStopped, context.Cause(p.inputCtx))
+			}()
+			PumpBatches(pluginCtx, p.stop, soloChan, outputCfg.opts.Batch, sizeOf, func(events []*Event) error {
+				start := time.Now()
+				attempts, err := Retry(pluginCtx, outputCfg.opts.Retry, func() error {
+					return outputCfg.output.Send(pluginCtx, events, outputCfg.codec, outputCfg.framing)
+				})
+				sendDuration.ObserveDuration(time.Since(start))
 				if err != nil {
-					event.batch.errorHappened <- err
+					outputCounters.errored.Add(len(events))
+					status.recordError(err)
+					status.sendFailed(err, p.opts.FailedOutputThreshold)
+				} else {
+					outputCounters.out.Add(len(events))
+					status.sendSucceeded()
 				}
-				if countOutputs == event.finishedOutputs.Add(1) {
-					event.batch.outputBurndown <- 1
+				// E2E handling
+				// every event in the batch is finished, whether it succeeded or not
+				for _, event := range events {
+					outcome := outputSent
+					if err != nil {
+						outcome = outputFailed
+						if p.sendDeadLetter(event, deadLetterFailure{
+							Stage:    "output",
+							Plugin:   outputName,
+							Err:      err,
+							Attempts: attempts,
+						}) {
+							outcome = outputDeadLettered
+						}
+					}
+					if event.batch != nil {
+						if err != nil {
+							event.batch.reportError(err)
+						}
+						event.finishOutput(outcome)
+					}
 				}
-			}
-			return err
-		})
+				finished.Add(int64(len(events)))
+				return err
+			})
+		}()
 	}
 
 	// set up fan-out replication of events
-	// TODO: reinstate StalledOutputThreshold
-	//alertThreshold := p.opts.StalledOutputThreshold
-	PumpFanOut(p.ctx, p.stop, events, outputChannels)
+	// stalled outputs are noticed by the watchdog
+	routes := make([]Condition, len(p.outputs))
+	for i, output := range p.outputs {
+		routes[i] = output.Value.opts.Route
+	}
+	pumpFanOut(p.ctx, p.stop, events, outputChannels, routes, func(i int) {
+		progress[i].received.Add(1)
+		counters[i].in.Inc()
+	})
+	outputsDrained.Wait()
+}
+
+// each output can have its own filter chain, which runs after fan-out.
+// fan-out gives every output a private copy of the event, so these filters
+// can reshape it (eg. trimming fields for Slack) without affecting other outputs.
+func (p *Pipeline) runOutputFilters(ctx context.Context, events chan *Event, filters []NamedEntity[FilterPlugin], finished *atomic.Int64, counters pluginCounters) chan *Event {
+	filtered := make(chan *Event)
+	reportToBatch := func(event *Event, _ string, dropped bool, err error) bool {
+		if dropped && err == nil {
+			finished.Add(1)
+			counters.dropped.Inc()
+		}
+		if event.batch == nil {
+			return false
+		}
+		// a drop here only means this output is finished with the event
+		if err != nil {
+			event.batch.reportError(err)
+		} else if dropped {
+			event.finishOutput(outputDropped)
+		}
+		return false
+	}
+	report := func(event *Event, filter string, dropped bool, err error) bool {
+		reportToBatch(event, filter, dropped, err)
+		if err == nil {
+			return false
+		}
+		consumed := p.sendDeadLetter(event, deadLetterFailure{
+			Stage:    "filter",
+			Plugin:   filter,
+			Err:      err,
+			Attempts: 1,
+		})
+		if consumed {
+			// this output is finished with the event
+			finished.Add(1)
+			event.finishOutput(outputDeadLettered)
+		}
+		return consumed
+	}
+	go func() {
+		pumpFilterList(ctx, p.stop, events, filtered, filters, report)
+		close(filtered)
+	}()
+	return filtered
 }
 
 func (p *Pipeline) Input(name string, plugin InputPlugin, filters ...NamedEntity[FilterPlugin]) {
@@ -210,6 +526,7 @@ func (p *Pipeline) Input(name string, plugin InputPlugin, filters ...NamedEntity
 		Value: inputDetail{
 			plugin:      plugin,
 			filterChain: filters,
+			status:      newPluginStatus(),
 		},
 	})
 }
@@ -222,8 +539,13 @@ func (p *Pipeline) Filter(name string, f FilterPlugin) {
 	})
 }
 
-// TODO: outputs should also have a filter chain
-func (p *Pipeline) Output(name string, op OutputPlugin, cp CodecPlugin, fp FramingPlugin) {
+func (p *Pipeline) Output(name string, op OutputPlugin, cp CodecPlugin, fp FramingPlugin, filters ...NamedEntity[FilterPlugin]) {
+	p.OutputWithOptions(name, op, cp, fp, OutputOptions{
+		Filters: filters,
+	})
+}
+
+func (p *Pipeline) OutputWithOptions(name string, op OutputPlugin, cp CodecPlugin, fp FramingPlugin, opts OutputOptions) {
 	// TODO: this is where we associate a Name with an output
 	p.outputs = append(p.outputs, NamedEntity[OutputConfig]{
 		Name: name,
@@ -231,6 +553,8 @@ func (p *Pipeline) Output(name string, op OutputPlugin, cp CodecPlugin, fp Frami
 			output:  op,
 			codec:   cp,
 			framing: fp,
+			opts:    opts,
+			status:  newPluginStatus(),
 		},
 	})
 }
@@ -0,0 +1,537 @@
+package loglang
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sends a fixed list of events as a single E2E batch, then stops
+type sliceInput struct {
+	BaseInputPlugin
+	events []*Event
+	result *BatchResult
+}
+
+func (p *sliceInput) Run(_ context.Context, sender Sender) error {
+	sender.SetE2E(true)
+	p.result = sender.Send(p.events...)
+	return nil
+}
+
+func (p *sliceInput) Extract(_ context.Context, _ *Event, _ io.Reader, output chan *Event) error {
+	close(output)
+	return nil
+}
+
+// sends one event from the byte stream, then fails the way a broken framing stage would
+type failingRawInput struct {
+	BaseInputPlugin
+	result *BatchResult
+	err    error
+}
+
+func (p *failingRawInput) Run(ctx context.Context, sender Sender) error {
+	sender.SetE2E(true)
+	p.result, p.err = sender.SendRaw(ctx, nil, strings.NewReader("ignored"))
+	return nil
+}
+
+func (p *failingRawInput) Extract(_ context.Context, _ *Event, _ io.Reader, output chan *Event) error {
+	defer close(output)
+	output <- messageEvents("before")[0]
+	return fmt.Errorf("framing failed: truncated")
+}
+
+// remembers every event it was asked to send
+type memoryOutput struct {
+	mutex      sync.Mutex
+	events     []*Event
+	batchSizes []int
+}
+
+func (p *memoryOutput) Send(_ context.Context, events []*Event, _ CodecPlugin, _ FramingPlugin) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.events = append(p.events, events...)
+	p.batchSizes = append(p.batchSizes, len(events))
+	return nil
+}
+
+func messageEvents(messages ...string) []*Event {
+	events := make([]*Event, 0, len(messages))
+	for _, msg := range messages {
+		evt := NewEvent()
+		evt.Field("message").SetString(msg)
+		events = append(events, &evt)
+	}
+	return events
+}
+
+func TestPipeline_OutputFilters(t *testing.T) {
+	p := NewPipeline("test", PipelineOptions{})
+	in := &sliceInput{events: messageEvents("keep", "drop")}
+	p.Input("slice", in)
+
+	full := &memoryOutput{}
+	p.Output("full", full, nil, nil)
+
+	trimmed := &memoryOutput{}
+	p.Output("trimmed", trimmed, nil, nil,
+		NamedEntity[FilterPlugin]{
+			Name: "drop some",
+			Value: func(event *Event, inject chan<- *Event, drop func()) error {
+				if event.Field("message").GetString() == "drop" {
+					drop()
+				}
+				return nil
+			},
+		},
+		NamedEntity[FilterPlugin]{
+			Name: "trim",
+			Value: func(event *Event, inject chan<- *Event, drop func()) error {
+				event.Field("@timestamp").Delete()
+				return nil
+			},
+		},
+	)
+
+	if err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(full.events) != 2 {
+		t.Errorf("expected 2 events in full output but got %d", len(full.events))
+	}
+	for _, evt := range full.events {
+		if _, err := evt.Field("@timestamp").Get(); err != nil {
+			t.Error("output filter must not modify events seen by other outputs")
+		}
+	}
+	if len(trimmed.events) != 1 {
+		t.Fatalf("expected 1 event in trimmed output but got %d", len(trimmed.events))
+	}
+	if _, err := trimmed.events[0].Field("@timestamp").Get(); err == nil {
+		t.Error("expected @timestamp to be removed by output filter")
+	}
+
+	if in.result == nil {
+		t.Fatal("expected a batch result")
+	}
+	if in.result.SuccessCount != 2 || in.result.DropCount != 0 {
+		t.Errorf("unexpected batch result: %s", in.result.Summary())
+	}
+}
+
+func TestPipeline_OutputFiltersDropEverywhere(t *testing.T) {
+	p := NewPipeline("test", PipelineOptions{})
+	in := &sliceInput{events: messageEvents("one")}
+	p.Input("slice", in)
+
+	dropAll := NamedEntity[FilterPlugin]{
+		Name: "drop all",
+		Value: func(event *Event, inject chan<- *Event, drop func()) error {
+			drop()
+			return nil
+		},
+	}
+	p.Output("first", &memoryOutput{}, nil, nil, dropAll)
+	p.Output("second", &memoryOutput{}, nil, nil, dropAll)
+
+	if err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if in.result == nil || in.result.DropCount != 1 || in.result.SuccessCount != 0 {
+		t.Errorf("expected a single drop but got %v", in.result)
+	}
+}
+
+func TestPipeline_FanOutCopies(t *testing.T) {
+	p := NewPipeline("test", PipelineOptions{})
+	in := &sliceInput{events: messageEvents("hello")}
+	p.Input("slice", in)
+
+	first := &memoryOutput{}
+	p.Output("first", first, nil, nil, NamedEntity[FilterPlugin]{
+		Name: "mutate",
+		Value: func(event *Event, inject chan<- *Event, drop func()) error {
+			event.Field("message").SetString("mutated")
+			return nil
+		},
+	})
+	second := &memoryOutput{}
+	p.Output("second", second, nil, nil)
+
+	if err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(first.events) != 1 || len(second.events) != 1 {
+		t.Fatal("expected one event in each output")
+	}
+	if first.events[0] == second.events[0] {
+		t.Error("outputs must not share the same *Event")
+	}
+	if actual := second.events[0].Field("message").GetString(); actual != "hello" {
+		t.Errorf(`Expected "hello" but got "%s"`, actual)
+	}
+	if in.result == nil || in.result.SuccessCount != 1 {
+		t.Errorf("unexpected batch result: %v", in.result)
+	}
+}
+
+func TestPipeline_OutputBatches(t *testing.T) {
+	p := NewPipeline("test", PipelineOptions{})
+	in := &sliceInput{events: messageEvents("one", "two", "three")}
+	p.Input("slice", in)
+
+	out := &memoryOutput{}
+	p.OutputWithOptions("batched", out, nil, nil, OutputOptions{
+		Batch: BatchOptions{
+			MaxEvents: 2,
+			Linger:    50 * time.Millisecond,
+		},
+	})
+
+	if err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.batchSizes) != 2 || out.batchSizes[0] != 2 || out.batchSizes[1] != 1 {
+		t.Errorf("expected batches of [2 1] but got %v", out.batchSizes)
+	}
+	if in.result == nil || in.result.SuccessCount != 3 {
+		t.Errorf("unexpected batch result: %v", in.result)
+	}
+}
+
+// always fails
+type brokenOutput struct{}
+
+func (p *brokenOutput) Send(_ context.Context, _ []*Event, _ CodecPlugin, _ FramingPlugin) error {
+	return Permanent(fmt.Errorf("broken"))
+}
+
+func TestPipeline_DeadLetter(t *testing.T) {
+	p := NewPipeline("test", PipelineOptions{})
+	in := &sliceInput{events: messageEvents("good", "poison")}
+	p.Input("slice", in)
+	p.Filter("shout", func(event *Event, inject chan<- *Event, drop func()) error {
+		event.Field("message").SetString(strings.ToUpper(event.Field("message").GetString()))
+		return nil
+	})
+	p.Filter("reject poison", func(event *Event, inject chan<- *Event, drop func()) error {
+		if event.Field("message").GetString() == "POISON" {
+			return fmt.Errorf("poison")
+		}
+		return nil
+	})
+
+	out := &memoryOutput{}
+	p.Output("memory", out, nil, nil)
+	p.Output("broken", &brokenOutput{}, nil, nil)
+
+	dead := &memoryOutput{}
+	p.DeadLetter("dlq", dead, nil, nil)
+
+	if err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(out.events) != 1 || out.events[0].Field("message").GetString() != "GOOD" {
+		t.Errorf("expected only the good event to reach outputs")
+	}
+	// the good event was dead lettered by the broken output, so it isn't a success either
+	if in.result == nil || in.result.DeadLetterCount != 2 || in.result.SuccessCount != 0 {
+		t.Errorf("unexpected batch result: %v", in.result)
+	}
+
+	dead.mutex.Lock()
+	defer dead.mutex.Unlock()
+	stages := make(map[string]string)
+	for _, evt := range dead.events {
+		stages[evt.Field("dead_letter", "stage").GetString()] = evt.Field("dead_letter", "plugin").GetString()
+		if evt.Field("dead_letter", "error").GetString() == "" {
+			t.Error("expected dead letter to include the error")
+		}
+		// replaying it will run the filters again
+		if msg := evt.Field("message").GetString(); msg != strings.ToLower(msg) {
+			t.Errorf("expected the dead letter to be the event from before the filters but got %q", msg)
+		}
+	}
+	if stages["filter"] != "reject poison" {
+		t.Errorf("expected the poison event from the filter stage but got %v", stages)
+	}
+	if stages["output"] != "broken" {
+		t.Errorf("expected the good event from the broken output but got %v", stages)
+	}
+}
+
+// sends events without waiting for them, then keeps running like a listener would
+type streamingInput struct {
+	BaseInputPlugin
+	events []*Event
+	sent   chan struct{}
+}
+
+func (p *streamingInput) Run(ctx context.Context, sender Sender) error {
+	sender.Send(p.events...)
+	close(p.sent)
+	<-ctx.Done()
+	return nil
+}
+
+// blocks until the pipeline stops for good
+type stuckOutput struct{}
+
+func (p *stuckOutput) Send(ctx context.Context, _ []*Event, _ CodecPlugin, _ FramingPlugin) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestPipeline_StopDrains(t *testing.T) {
+	p := NewPipeline("test", PipelineOptions{})
+	in := &streamingInput{events: messageEvents("one", "two", "three"), sent: make(chan struct{})}
+	p.Input("stream", in)
+	out := &memoryOutput{}
+	// without draining, a long linger would strand these events in the batch
+	p.OutputWithOptions("memory", out, nil, nil, OutputOptions{
+		Batch: BatchOptions{MaxEvents: 100, Linger: time.Hour},
+	})
+
+	go func() {
+		<-in.sent
+		p.Stop("test")
+	}()
+	if err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.events) != 3 {
+		t.Errorf("expected all 3 events to drain to the output but got %d", len(out.events))
+	}
+}
+
+func TestPipeline_DrainTimeout(t *testing.T) {
+	p := NewPipeline("test", PipelineOptions{DrainTimeout: 50 * time.Millisecond})
+	in := &streamingInput{events: messageEvents("one", "two"), sent: make(chan struct{})}
+	p.Input("stream", in)
+	p.Output("stuck", &stuckOutput{}, nil, nil)
+
+	go func() {
+		<-in.sent
+		p.Stop("test")
+	}()
+	err := p.Run()
+	if err == nil || !strings.Contains(err.Error(), "abandoned") {
+		t.Errorf("expected a report of abandoned events but got %v", err)
+	}
+}
+
+func TestPipeline_StalledOutput(t *testing.T) {
+	p := NewPipeline("test", PipelineOptions{
+		StalledOutputThreshold: 50 * time.Millisecond,
+		StallStopAfter:         100 * time.Millisecond,
+		DrainTimeout:           50 * time.Millisecond,
+	})
+	p.Input("stream", &streamingInput{events: messageEvents("one"), sent: make(chan struct{})})
+	p.Output("stuck", &stuckOutput{}, nil, nil)
+	warnings := &memoryOutput{}
+	p.StallOutput("warnings", warnings, nil, nil)
+
+	// the pipeline only stops because of the stall
+	err := p.Run()
+	if err == nil || !strings.Contains(err.Error(), "abandoned 1 events") {
+		t.Errorf("expected the stuck event to be abandoned but got %v", err)
+	}
+	if len(warnings.events) != 1 {
+		t.Fatalf("expected 1 stall warning but got %d", len(warnings.events))
+	}
+	warning := warnings.events[0]
+	if warning.Field("stall", "plugin").GetString() != "stuck" || warning.Field("stall", "pending").GetInt() != 1 {
+		t.Errorf("unexpected stall warning: %v", warning.Fields)
+	}
+}
+
+func TestPipeline_StalledInput(t *testing.T) {
+	p := NewPipeline("test", PipelineOptions{StalledInputThreshold: 50 * time.Millisecond})
+	p.Input("stream", &streamingInput{events: messageEvents("one"), sent: make(chan struct{})})
+	out := &memoryOutput{}
+	p.Output("memory", out, nil, nil)
+
+	// without a stall output, warnings go through the pipeline
+	go func() {
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			out.mutex.Lock()
+			count := len(out.events)
+			out.mutex.Unlock()
+			if count >= 2 {
+				break
+			}
+		}
+		p.Stop("test")
+	}()
+	if err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.events) != 2 {
+		t.Fatalf("expected an event and a stall warning but got %d events", len(out.events))
+	}
+	if msg := out.events[1].Field("message").GetString(); !strings.HasPrefix(msg, "input stream stalled") {
+		t.Errorf("unexpected stall warning %q", msg)
+	}
+}
+
+func TestPipeline_Routing(t *testing.T) {
+	p := NewPipeline("test", PipelineOptions{})
+	events := messageEvents("fine", "broken", "ignored")
+	events[0].Field("log", "level").SetString("info")
+	events[1].Field("log", "level").SetString("error")
+	in := &sliceInput{events: events}
+	p.Input("slice", in)
+
+	errorsOnly, err := ParseCondition(`log.level >= "error"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	levelled, err := ParseCondition("log.level")
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := &memoryOutput{}
+	p.OutputWithOptions("all levels", all, nil, nil, OutputOptions{Route: levelled})
+	alerts := &memoryOutput{}
+	p.OutputWithOptions("alerts", alerts, nil, nil, OutputOptions{Route: errorsOnly})
+
+	if err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(all.events) != 2 || len(alerts.events) != 1 {
+		t.Errorf("expected 2 and 1 events but got %d and %d", len(all.events), len(alerts.events))
+	}
+	// the event without a level went nowhere, which counts as a drop
+	if in.result == nil || in.result.SuccessCount != 2 || in.result.DropCount != 1 {
+		t.Errorf("expected 2 delivered and 1 dropped but got %v", in.result)
+	}
+}
+
+func TestPipeline_RoutingOnMetadata(t *testing.T) {
+	p := NewPipeline("test", PipelineOptions{})
+	events := messageEvents("to alerts", "elsewhere")
+	events[0].Field(MetadataField, "route").SetString("alerts")
+	p.Input("slice", &sliceInput{events: events})
+
+	route, err := ParseCondition(`[@metadata][route] == "alerts"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alerts := &memoryOutput{}
+	p.OutputWithOptions("alerts", alerts, nil, nil, OutputOptions{Route: route})
+
+	if err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts.events) != 1 || alerts.events[0].Field("message").GetString() != "to alerts" {
+		t.Fatalf("expected one routed event but got %d", len(alerts.events))
+	}
+	// outputs can still see metadata; it's only codecs that ignore it
+	if alerts.events[0].Field(MetadataField, "route").GetString() != "alerts" {
+		t.Error("expected the output to see the metadata")
+	}
+}
+
+func TestPipeline_ExtractionFailureFailsBatch(t *testing.T) {
+	p := NewPipeline("test", PipelineOptions{})
+	in := &failingRawInput{}
+	p.Input("raw", in)
+	out := &memoryOutput{}
+	p.Output("memory", out, nil, nil)
+
+	if err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if in.err == nil || !strings.Contains(in.err.Error(), "truncated") {
+		t.Errorf("expected the extraction error but got %v", in.err)
+	}
+	if in.result == nil || in.result.Ok || in.result.SuccessCount != 1 || in.result.ErrorCount != 1 {
+		t.Errorf("expected a failed batch with 1 event sent but got %+v", in.result)
+	}
+	if len(out.events) != 1 {
+		t.Errorf("events before the failure should still be sent, but got %d", len(out.events))
+	}
+}
+
+// sends a single E2E batch with its own deadline
+type deadlineInput struct {
+	BaseInputPlugin
+	deadline time.Duration
+	result   *BatchResult
+}
+
+func (p *deadlineInput) Run(_ context.Context, sender Sender) error {
+	sender.SetE2E(true)
+	sender.SetBatchDeadline(p.deadline)
+	p.result = sender.Send(messageEvents("slow")...)
+	return nil
+}
+
+// takes a while for each batch
+type slowOutput struct {
+	delay time.Duration
+}
+
+func (p *slowOutput) Send(_ context.Context, _ []*Event, _ CodecPlugin, _ FramingPlugin) error {
+	time.Sleep(p.delay)
+	return nil
+}
+
+func TestSender_BatchDeadline(t *testing.T) {
+	for _, tt := range []struct {
+		deadline time.Duration
+		ok       bool
+	}{
+		{10 * time.Millisecond, false},
+		// no deadline waits for as long as it takes
+		{0, true},
+	} {
+		p := NewPipeline("test", PipelineOptions{})
+		in := &deadlineInput{deadline: tt.deadline}
+		p.Input("deadline", in)
+		p.Output("slow", &slowOutput{delay: 100 * time.Millisecond}, nil, nil)
+		if err := p.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if in.result == nil || in.result.Ok != tt.ok {
+			t.Errorf("deadline %v: expected Ok=%t but got %v", tt.deadline, tt.ok, in.result)
+		}
+	}
+}
+
+func TestBatch_LateReportsAfterTimeout(t *testing.T) {
+	b := newBatch()
+	b.slowWarning = 0
+	b.slowDeadline = 10 * time.Millisecond
+	counted := make(chan int, 1)
+	counted <- 1
+	if _, err := b.waitForResults(context.Background(), counted); err == nil {
+		t.Fatal("expected the batch to time out")
+	}
+
+	// nothing is listening any more, so an output (eg. finishing its retries) must not get stuck
+	evt := NewEvent()
+	evt.batch = b
+	evt.routedOutputs = 1
+	reported := make(chan struct{})
+	go func() {
+		evt.batch.reportError(fmt.Errorf("late"))
+		evt.finishOutput(outputSent)
+		evt.batch.reportDeadLetter()
+		close(reported)
+	}()
+	select {
+	case <-reported:
+	case <-time.After(2 * time.Second):
+		t.Fatal("late reports blocked after the batch timed out")
+	}
+}
@@ -2,6 +2,7 @@ package loglang
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"time"
@@ -13,12 +14,65 @@ type InputPlugin interface {
 	//Extractor
 }
 
+// DecodingInput is an input that decodes byte streams, so its framing and codec can be chosen in config.
+// Inputs get this by embedding BaseInputPlugin.
+type DecodingInput interface {
+	InputPlugin
+	SetFraming(...FramingPlugin)
+	SetCodec(CodecPlugin)
+}
+
 type Extractor func(context.Context, *Event, io.Reader, chan *Event) error
 
 type BaseInputPlugin struct {
 	Framing []FramingPlugin
 	Codec   CodecPlugin
 	Schema  SchemaModel
+	// DecodeFailure overrides the pipeline's policy for frames the codec can't decode
+	DecodeFailure DecodeFailurePolicy
+}
+
+// DecodeFailurePolicy is what an input does with a frame that its codec can't decode
+type DecodeFailurePolicy string
+
+const (
+	// DecodeFailureFallback sends the raw frame as the message, tagged like _jsonparsefailure (the default)
+	DecodeFailureFallback DecodeFailurePolicy = "fallback"
+	// DecodeFailureSkip counts the frame and moves on to the next one
+	DecodeFailureSkip DecodeFailurePolicy = "skip"
+	// DecodeFailureFail stops extracting, so the whole batch fails
+	DecodeFailureFail DecodeFailurePolicy = "fail"
+)
+
+// ParseDecodeFailurePolicy reads a policy from config; empty means the default
+func ParseDecodeFailurePolicy(s string) (DecodeFailurePolicy, error) {
+	switch policy := DecodeFailurePolicy(s); policy {
+	case "":
+		return DecodeFailureFallback, nil
+	case DecodeFailureFallback, DecodeFailureSkip, DecodeFailureFail:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown decode failure policy %q (known: %s, %s, %s)",
+		s, DecodeFailureFallback, DecodeFailureSkip, DecodeFailureFail)
+}
+
+func (p *BaseInputPlugin) SetFraming(framing ...FramingPlugin) {
+	p.Framing = framing
+}
+
+func (p *BaseInputPlugin) SetCodec(codec CodecPlugin) {
+	p.Codec = codec
+}
+
+// the plugin's own policy wins over the pipeline's
+func (p *BaseInputPlugin) decodeFailurePolicy(ctx context.Context) DecodeFailurePolicy {
+	if p.DecodeFailure != "" {
+		return p.DecodeFailure
+	}
+	if policy, ok := ctx.Value(ContextKeyDecodeFailure).(DecodeFailurePolicy); ok && policy != "" {
+		return policy
+	}
+	return DecodeFailureFallback
 }
 
 func (p *BaseInputPlugin) Run(_ context.Context, _ Sender) error {
@@ -27,83 +81,93 @@ func (p *BaseInputPlugin) Run(_ context.Context, _ Sender) error {
 }
 
 // Extract runs all the framing stages and codec. Output is a channel of decoded Events.
+// The output channel is always closed when extraction finishes, even on error.
 func (p *BaseInputPlugin) Extract(ctx context.Context, template *Event, reader io.Reader, output chan *Event) error {
+	// this close() is important!
+	defer close(output)
+
 	if p.Codec == nil {
 		panic("input codec must not be nil")
 	}
+	if len(p.Framing) == 0 {
+		// TODO: should we fall back to something else? Lines()
+		panic("no framing configured on input plugin")
+	}
 
+	parentCtx := ctx
 	var stop context.CancelCauseFunc
 	ctx, stop = context.WithCancelCause(ctx)
+	defer stop(nil)
 	ctx = context.WithValue(ctx, ContextKeyPluginType, "BaseInputPlugin")
 	log := ContextLogger(ctx)
 
-	switch len(p.Framing) {
-	case 0:
-		// TODO: should we fall back to something else? Lines()
-		panic("no framing configured on input plugin")
+	// collect chunks from the reader
+	// the reader gets its own context because reaching EOF is normal
+	// and must not cancel the framing stages before they finish draining
+	readCtx, stopReading := context.WithCancelCause(ctx)
+	defer stopReading(nil)
+	chunks := make(chan []byte)
+	go PumpFromReader(readCtx, stopReading, reader, chunks)
+
+	// the framing stages will normalize those into whole frames
+	frames := PumpFraming(ctx, stop, p.Framing, chunks)
+
+	// codecs that decode differently for each schema get the pipeline's, unless the input has its own
+	codec := p.Codec
+	if schemaCodec, isSchemaCodec := codec.(SchemaCodec); isSchemaCodec {
+		schema := p.Schema
+		if schema == SchemaNotDefined {
+			schema, _ = ctx.Value(ContextKeySchema).(SchemaModel)
+		}
+		codec = schemaCodec.WithSchema(schema)
+	}
 
-	case 1:
-		// collect chunks from the reader
-		chunks := make(chan []byte)
-		go PumpFromReader(ctx, stop, reader, chunks)
-
-		// the framing stage will normalize those into whole frames
-		stage := p.Framing[0]
-		frames := make(chan []byte)
-		go func() {
-			err := stage.Extract(ctx, chunks, frames)
-			close(frames)
-			if err != nil {
-				log.Error("framing stage failed", "error", err)
-				stop(err)
-			}
-		}()
-
-		// run the decoder on those frames here in this thread
-		decoded := 0
-	decoderLoop:
-		for {
-			// should there be a timeout on this selecct?
-			select {
-			case <-ctx.Done():
-				log.Debug("decoderLoop finished by context",
-					"cause", context.Cause(ctx),
+	// run the decoder on those frames here in this thread
+	decoded := 0
+	decodeFailures := ContextMetrics(ctx).Counter("loglang_decode_failures_total", "Frames that an input codec could not decode", pluginLabels(ctx, "input"))
+	policy := p.decodeFailurePolicy(ctx)
+decoderLoop:
+	for {
+		// should there be a timeout on this selecct?
+		select {
+		case <-ctx.Done():
+			log.Debug("decoderLoop finished by context",
+				"cause", context.Cause(ctx),
+				"count", decoded)
+			break decoderLoop
+		case frame, more := <-frames:
+			if !more {
+				log.Debug("decoderLoop finished",
+					"cause", "frames channel closed",
 					"count", decoded)
 				break decoderLoop
-			case frame, more := <-frames:
-				if !more {
-					log.Debug("decoderLoop finished",
-						"cause", "frames channel closed",
-						"count", decoded)
-					break decoderLoop
-				}
-				evt, err := p.Codec.Decode(frame)
-				evt.Merge(template, false)
-				if err != nil {
+			}
+			evt, err := codec.Decode(frame)
+			if err != nil {
+				decodeFailures.Inc()
+				switch policy {
+				case DecodeFailureFail:
 					return fmt.Errorf("frame decoding failed: %w", err)
+				case DecodeFailureSkip:
+					log.Debug("skipped frame that could not be decoded", "error", err)
+					continue
 				}
-				output <- &evt
-				decoded++
-			case <-time.After(time.Second * 2):
-				log.Debug("decoderLoop timeout", "count", decoded)
+				evt = decodeFailureEvent(frame, err)
+			}
+			if template != nil {
+				evt.Merge(template, false)
 			}
+			output <- &evt
+			decoded++
+		case <-time.After(time.Second * 2):
+			log.Debug("decoderLoop timeout", "count", decoded)
 		}
-		// this close() is important!
-		close(output)
-
-	case 2, 3, 4:
-		// we should be prepared for multiple levels of framing
-		// select{} doesn't work well with arrays of channels, so the max is hardcoded
-		// TODO: write this hard code for handling variable number of stages, all in the same goroutine
-		// what about running Extract() recursively?
-		// I don't think we want to spin up multiple goroutines for *each and every event*!
-		return fmt.Errorf("not yet implemented: multiple framing stages")
-
-	default:
-		panic("too many framing stages")
-
 	}
 
+	// a failed framing stage stops our context, but the parent context does not
+	if ctx.Err() != nil && parentCtx.Err() == nil {
+		return fmt.Errorf("framing failed: %w", context.Cause(ctx))
+	}
 	return nil
 }
 
@@ -123,9 +187,52 @@ type CodecPlugin interface {
 	// so pointers aren't needed in this interface
 }
 
+// SchemaCodec is a codec that decodes into different fields depending on the schema (eg. ECS or flat)
+type SchemaCodec interface {
+	CodecPlugin
+	// WithSchema returns the codec to use for the schema; a codec configured with its own schema keeps it
+	WithSchema(SchemaModel) CodecPlugin
+}
+
+// DecodeError says which codec couldn't decode a frame, so the fallback event can be tagged for it
+type DecodeError struct {
+	Codec string
+	Err   error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s codec: %v", e.Codec, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// FailureTag is like logstash's _jsonparsefailure
+func (e *DecodeError) FailureTag() string {
+	return "_" + e.Codec + "parsefailure"
+}
+
+// decodeFailureEvent keeps the raw frame, so nothing is lost when the codec is wrong
+func decodeFailureEvent(frame []byte, err error) Event {
+	tag := "_decodefailure"
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		tag = decodeErr.FailureTag()
+	}
+	evt := NewEvent()
+	evt.Field("message").SetString(string(frame))
+	evt.Field("tags").Set([]any{tag})
+	evt.Field("error", "message").SetString(err.Error())
+	return evt
+}
+
 type FramingPlugin interface {
 	// I tried using io.Reader and io.Writer but because they use fixed buffers,
 	// they had problems if the frame size exceeded the buffer size.
+	//
+	// Both methods MUST close the output channel when they return.
+	// That's how the next stage of a framing chain knows the stream is finished.
 	Extract(ctx context.Context, input <-chan []byte, output chan<- []byte) error
 	Frameup(ctx context.Context, input <-chan []byte, output chan<- []byte) error
 }
@@ -9,15 +9,16 @@ import (
 
 // this function does nothing useful
 // anywhere it's used, that is surely a design error
+// returns when the input is closed (drained) or the context is done
 func Pump(ctx context.Context, stop context.CancelCauseFunc, input chan *Event, output chan *Event) {
-	// TODO: I think we need this, but also it causes a panic?
-	//defer close(output)
+	// output isn't closed here because several inputs can share one output channel
+	// whoever owns the output channel closes it after Pump returns
 nullPump:
 	for {
 		select {
 		case inEvt, more := <-input:
 			if !more {
-				stop(fmt.Errorf("nullPump saw closed channel"))
+				// a closed input means the pipeline is draining
 				break nullPump
 			}
 			if inEvt == nil {
@@ -35,7 +36,14 @@ nullPump:
 func PumpFilterList(ctx context.Context, stop context.CancelCauseFunc,
 	input chan *Event, output chan *Event,
 	filters []NamedEntity[FilterPlugin], ack bool) {
-	// don't need to defer close(output) here because that happens in Pump()
+	pumpFilterList(ctx, stop, input, output, filters, ackReporter(ack))
+}
+
+func pumpFilterList(ctx context.Context, stop context.CancelCauseFunc,
+	input chan *Event, output chan *Event,
+	filters []NamedEntity[FilterPlugin], report filterReporter) {
+	// output is not closed here; see Pump()
+	// closing the input drains every filter stage, then this returns
 
 	log := ContextLogger(ctx)
 	log.Debug("preparing filter chain")
@@ -48,8 +56,12 @@ func PumpFilterList(ctx context.Context, stop context.CancelCauseFunc,
 	}
 
 	// set up goroutines to pump each stage of the Pipeline
+	metrics := ContextMetrics(ctx)
 	for i, filter := range filters {
-		go PumpFilter(ctx, stop, allChannels[i], allChannels[i+1], filter, ack)
+		stage := allChannels[i+1]
+		metrics.GaugeFunc("loglang_channel_depth", "Events waiting in a channel between pipeline stages",
+			filterLabels(ctx, filter.Name), func() float64 { return float64(len(stage)) })
+		go pumpFilter(ctx, stop, allChannels[i], stage, filter, report)
 	}
 
 	log.Info(fmt.Sprintf("set up filter chain length=%d", len(filters)))
@@ -58,19 +70,54 @@ func PumpFilterList(ctx context.Context, stop context.CancelCauseFunc,
 	Pump(ctx, stop, allChannels[len(allChannels)-1], output)
 }
 
+// a filterReporter learns what happened to each event that went through a filter stage
+// this is how filter outcomes get reported for end-to-end acknowledgement
+// returns true if the reporter took the event (eg. to a dead letter output)
+// so it must not continue down the filter chain
+type filterReporter func(event *Event, filter string, dropped bool, err error) (consumed bool)
+
+func ackReporter(ack bool) filterReporter {
+	if !ack {
+		return nil
+	}
+	return func(event *Event, _ string, dropped bool, err error) bool {
+		if event.batch == nil {
+			return false
+		}
+		if err != nil {
+			event.batch.reportError(err)
+		} else if dropped {
+			event.batch.reportDrop()
+		} else {
+			event.batch.reportFiltered()
+		}
+		return false
+	}
+}
+
 func PumpFilter(ctx context.Context, stop context.CancelCauseFunc,
 	input chan *Event, output chan *Event,
 	filter NamedEntity[FilterPlugin], ack bool) {
+	pumpFilter(ctx, stop, input, output, filter, ackReporter(ack))
+}
+
+func pumpFilter(ctx context.Context, stop context.CancelCauseFunc,
+	input chan *Event, output chan *Event,
+	filter NamedEntity[FilterPlugin], report filterReporter) {
 	log := ContextLogger(ctx)
 	filterFunc := filter.Value
 	log.Debug("starting filter pump")
 	defer close(output)
+	metrics := ContextMetrics(ctx)
+	labels := filterLabels(ctx, filter.Name)
+	counters := newPluginCounters(metrics, labels)
+	latency := metrics.Histogram("loglang_filter_duration_seconds", "Time taken by a filter for each event", labels, FastBuckets)
 filterPump:
 	for {
 		select {
 		case event, more := <-input:
 			if !more {
-				stop(fmt.Errorf("filterPump saw closed channel"))
+				// drained; closing output lets the next stage drain too
 				break filterPump
 			}
 			if event == nil {
@@ -85,32 +132,34 @@ filterPump:
 					dropped = true
 				}
 			}
+			counters.in.Inc()
+			start := time.Now()
 			err := filterFunc(event, output, dropFunc)
+			latency.ObserveDuration(time.Since(start))
 			// E2E handling
-			// FIXME: is this is the right place to check ack?
-			if ack && event.batch != nil {
-				if err != nil {
-					event.batch.errorHappened <- err
-				} else if dropped {
-					event.batch.dropHappened <- true
-				} else {
-					event.batch.filterBurndown <- 1
-				}
+			consumed := false
+			if report != nil {
+				consumed = report(event, filter.Name, dropped, err)
 			}
 			// regular handling
 			if err != nil {
+				counters.errored.Inc()
 				log.Warn("filter error",
 					"error", err,
 					"filter", filter.Name,
 				)
-				// TODO: should events continue down the pipeline if a single filter stage fails?
-				// continue
+				// events only continue down the pipeline if there's no dead letter output
+				if consumed {
+					continue
+				}
 			} else if dropped {
 				// do not pass to next stage of filter pipeline
+				counters.dropped.Inc()
 				continue
 			}
 			// send it
 			output <- event
+			counters.out.Inc()
 		case <-ctx.Done():
 			break filterPump
 		}
@@ -126,7 +175,6 @@ functionPump:
 		select {
 		case event, more := <-input:
 			if !more {
-				stop(fmt.Errorf("pumpToFunction saw closed channel"))
 				break functionPump
 			}
 			if event == nil {
@@ -135,7 +183,7 @@ functionPump:
 			}
 			err := fn(event)
 			if err != nil {
-				log.Warn(fmt.Sprintf("functionPump saw error: %w", err))
+				log.Warn("functionPump saw error", "error", err)
 				// I don't think we want to stop the pipeline over this.
 				//stop(fmt.Errorf("functionPump error: %w", err))
 				//break functionPump
@@ -147,7 +195,82 @@ functionPump:
 }
 
 // intended to be run as a goroutine
+// collects events into batches, then calls fn with the whole batch
+// a batch is flushed when it reaches MaxEvents or MaxBytes, or after Linger
+// sizeOf is only used when MaxBytes is set
+func PumpBatches(ctx context.Context, stop context.CancelCauseFunc, input <-chan *Event, opts BatchOptions, sizeOf func(*Event) int, fn func([]*Event) error) {
+	log := ContextLogger(ctx)
+	log.Debug("starting pump batches")
+
+	opts = opts.withDefaults()
+	batch := make([]*Event, 0, min(opts.MaxEvents, 1024))
+	batchBytes := 0
+	var linger <-chan time.Time
+
+	flush := func(reason string) {
+		if len(batch) == 0 {
+			return
+		}
+		log.Debug("flushing batch", "reason", reason, "count", len(batch), "bytes", batchBytes)
+		err := fn(batch)
+		if err != nil {
+			log.Warn("batchPump saw error", "error", err)
+		}
+		// fn might hold on to the slice, so start a new one
+		batch = make([]*Event, 0, min(opts.MaxEvents, 1024))
+		batchBytes = 0
+		linger = nil
+	}
+
+batchPump:
+	for {
+		select {
+		case event, more := <-input:
+			if !more {
+				// draining; whatever is left goes out now
+				flush("closed")
+				break batchPump
+			}
+			if event == nil {
+				flush("nil")
+				stop(fmt.Errorf("batchPump saw nil event"))
+				break batchPump
+			}
+			size := 0
+			if opts.MaxBytes > 0 && sizeOf != nil {
+				size = sizeOf(event)
+				// don't let this event push the batch over the limit
+				if batchBytes+size > opts.MaxBytes {
+					flush("bytes")
+				}
+			}
+			batch = append(batch, event)
+			batchBytes += size
+			if len(batch) == 1 {
+				linger = time.After(opts.Linger)
+			}
+			if len(batch) >= opts.MaxEvents {
+				flush("count")
+			} else if opts.MaxBytes > 0 && batchBytes >= opts.MaxBytes {
+				flush("bytes")
+			}
+		case <-linger:
+			flush("linger")
+		case <-ctx.Done():
+			break batchPump
+		}
+	}
+}
+
+// intended to be run as a goroutine
+// outputs are closed when the input is closed, so they can drain too
 func PumpFanOut(ctx context.Context, stop context.CancelCauseFunc, input <-chan *Event, outputs []chan *Event) {
+	pumpFanOut(ctx, stop, input, outputs, nil, nil)
+}
+
+// routes[i] decides which events go to outputs[i]; a nil route (or nil routes) sends everything
+// sent is called as each event is handed to outputs[i]
+func pumpFanOut(ctx context.Context, stop context.CancelCauseFunc, input <-chan *Event, outputs []chan *Event, routes []Condition, sent func(i int)) {
 	defer func() {
 		for i := range outputs {
 			close(outputs[i])
@@ -155,21 +278,48 @@ func PumpFanOut(ctx context.Context, stop context.CancelCauseFunc, input <-chan
 	}()
 	log := ContextLogger(ctx)
 	log.Debug("starting pump fanOut")
+	unrouted := ContextMetrics(ctx).Counter("loglang_events_unrouted_total", "Events that no output's route matched", pluginLabels(ctx, "pipeline"))
+	routed := make([]int, 0, len(outputs))
 fanOut:
 	for {
 		// TODO: maybe include a timeout case?
 		select {
 		case event, more := <-input:
 			if !more {
-				stop(fmt.Errorf("fanOut saw closed channel"))
 				break fanOut
 			}
 			if event == nil {
 				stop(fmt.Errorf("fanOut saw nil event"))
 				break fanOut
 			}
+			routed = routed[:0]
 			for i := range outputs {
-				outputs[i] <- event
+				if routes == nil || routes[i] == nil || routes[i](event) {
+					routed = append(routed, i)
+				}
+			}
+			if len(routed) == 0 {
+				// nobody wanted it; as far as E2E is concerned, it was dropped
+				unrouted.Inc()
+				if event.batch != nil {
+					event.batch.reportDrop()
+				}
+				continue
+			}
+			// E2E acknowledgement waits for only the outputs it was routed to
+			event.routedOutputs = uint32(len(routed))
+			// every output gets a private copy, so an output (or its filters)
+			// can't mutate what another output will encode
+			for n, i := range routed {
+				if sent != nil {
+					sent(i)
+				}
+				if n == len(routed)-1 {
+					// the last output can have the original; nobody else is using it now
+					outputs[i] <- event
+				} else {
+					outputs[i] <- event.replica()
+				}
 			}
 		case <-ctx.Done():
 			break fanOut
@@ -177,6 +327,26 @@ fanOut:
 	}
 }
 
+// PumpFraming chains several framing stages together, each in its own goroutine.
+// Returns the channel of frames coming out of the last stage.
+// Every stage closes its output when finished, so the returned channel
+// is closed once the whole chain has drained.
+func PumpFraming(ctx context.Context, stop context.CancelCauseFunc, stages []FramingPlugin, input <-chan []byte) <-chan []byte {
+	log := ContextLogger(ctx)
+	for i, stage := range stages {
+		frames := make(chan []byte)
+		go func(i int, stage FramingPlugin, input <-chan []byte, output chan<- []byte) {
+			err := stage.Extract(ctx, input, output)
+			if err != nil {
+				log.Error("framing stage failed", "stage", i, "error", err)
+				stop(err)
+			}
+		}(i, stage, input, frames)
+		input = frames
+	}
+	return input
+}
+
 // intended to be run as a goroutine
 func PumpToWriter(ctx context.Context, stop context.CancelCauseFunc, input <-chan []byte, output io.WriteCloser) {
 	log := ContextLogger(ctx)
@@ -213,6 +383,7 @@ func PumpFromReader(ctx context.Context, stop context.CancelCauseFunc, input io.
 
 	buf := make([]byte, MaxFrameSize)
 	count := 0
+	bytesCounter := ContextMetrics(ctx).Counter("loglang_bytes_read_total", "Bytes read by an input, before framing and decoding", pluginLabels(ctx, "input"))
 
 	chunks := make(chan []byte)
 	go func() {
@@ -222,6 +393,16 @@ func PumpFromReader(ctx context.Context, stop context.CancelCauseFunc, input io.
 		for {
 			// input.Read() blocks forever
 			bytesRead, err := input.Read(buf)
+			// readers are allowed to return data and EOF at the same time (gzip does)
+			if bytesRead > 0 {
+				if bytesRead == MaxFrameSize {
+					log.Warn("reached MaxFrameSize; cannot guarantee frame integrity")
+				}
+				// why copy the frame? to avoid races with slices referencing the buffer
+				frameCopy := make([]byte, bytesRead)
+				copy(frameCopy, buf)
+				chunks <- frameCopy
+			}
 			if err != nil {
 				if err == io.EOF {
 					// EOF is very normal and expected
@@ -231,13 +412,6 @@ func PumpFromReader(ctx context.Context, stop context.CancelCauseFunc, input io.
 				}
 				return
 			}
-			if bytesRead == MaxFrameSize {
-				log.Warn("reached MaxFrameSize; cannot guarantee frame integrity")
-			}
-			// why copy the frame? to avoid races with slices referencing the buffer
-			frameCopy := make([]byte, bytesRead)
-			copy(frameCopy, buf)
-			chunks <- frameCopy
 		}
 		// this probably leaks a goroutine.
 		// can we recover without shutting down the whole process?
@@ -248,10 +422,6 @@ readLoop:
 		case <-ctx.Done():
 			log.Debug("halting PumpFromReader.readloop", "cause", context.Cause(ctx))
 			break readLoop
-		case <-time.After(100 * time.Millisecond):
-			// todo much longer timeout
-			log.Debug("reader timed out")
-			break readLoop
 		case chunk, more := <-chunks:
 			if !more {
 				stop(fmt.Errorf("end of chunks"))
@@ -259,6 +429,7 @@ readLoop:
 			}
 			output <- chunk
 			count++
+			bytesCounter.Add(len(chunk))
 		}
 	}
 	log.Debug("stopped pumping output",
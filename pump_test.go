@@ -0,0 +1,39 @@
+package loglang
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPumpBatches_MaxBytes(t *testing.T) {
+	ctx, stop := context.WithCancelCause(context.Background())
+	defer stop(nil)
+
+	input := make(chan *Event, 5)
+	for _, msg := range []string{"aaaa", "bbbb", "cccc", "dddddddddddd", "e"} {
+		evt := NewEvent()
+		evt.Field("message").SetString(msg)
+		input <- &evt
+	}
+	close(input)
+
+	sizeOf := func(event *Event) int {
+		return len(event.Field("message").GetString())
+	}
+	batchSizes := make([]int, 0)
+	PumpBatches(ctx, stop, input, BatchOptions{MaxBytes: 10}, sizeOf, func(events []*Event) error {
+		batchSizes = append(batchSizes, len(events))
+		return nil
+	})
+
+	// the oversized event is sent by itself
+	expected := []int{2, 1, 1, 1}
+	if len(batchSizes) != len(expected) {
+		t.Fatalf("expected batches of %v but got %v", expected, batchSizes)
+	}
+	for i := range expected {
+		if batchSizes[i] != expected[i] {
+			t.Fatalf("expected batches of %v but got %v", expected, batchSizes)
+		}
+	}
+}
@@ -0,0 +1,527 @@
+package loglang
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The persistent queue sits between inputs and filters.
+// Inputs get their E2E acknowledgement as soon as an event is durably written,
+// so a slow output doesn't stall inputs, and a crash doesn't lose events in flight.
+//
+// Events are appended to segment files in a directory.
+// Each record is: [length uint32][crc32 uint32][gob-encoded fields and metadata]
+// The cursor file remembers the oldest record that hasn't been delivered to every output yet.
+// On restart, everything from the cursor onwards is replayed. (at-least-once delivery)
+// Segments are deleted once the cursor has moved past them.
+
+type QueueOptions struct {
+	// Path is the directory for segment files. The queue is disabled when this is empty.
+	Path string `yaml:"path"`
+	// MaxSegmentBytes is the size at which a new segment file is started
+	MaxSegmentBytes int64 `yaml:"maxSegmentBytes"`
+}
+
+func (o QueueOptions) withDefaults() QueueOptions {
+	if o.MaxSegmentBytes <= 0 {
+		o.MaxSegmentBytes = 64 * 1024 * 1024
+	}
+	return o
+}
+
+const (
+	queueSegmentSuffix = ".seg"
+	queueCursorFile    = "cursor"
+	queueHeaderSize    = 8
+)
+
+func init() {
+	// nested fields are stored as interface values, so gob needs to know about them
+	gob.Register(map[string]any{})
+	gob.Register([]any{})
+	gob.Register(time.Time{})
+}
+
+type queuePosition struct {
+	Segment uint64 `json:"segment"`
+	Offset  int64  `json:"offset"`
+}
+
+func (a queuePosition) before(b queuePosition) bool {
+	return a.Segment < b.Segment || (a.Segment == b.Segment && a.Offset < b.Offset)
+}
+
+// queuedEvent is what each record holds; metadata is kept, since routing and outputs can depend on it
+type queuedEvent struct {
+	Fields   map[string]any
+	Metadata map[string]any
+	// Original is the event before filters, for dead letters
+	Original *queuedEvent
+}
+
+func (queued queuedEvent) event() Event {
+	evt := NewEvent()
+	if queued.Fields != nil {
+		evt.Fields = queued.Fields
+	}
+	evt.Metadata = queued.Metadata
+	if queued.Original != nil {
+		original := queued.Original.event()
+		evt.original = &original
+	}
+	return evt
+}
+
+type queueRecord struct {
+	start queuePosition
+	end   queuePosition
+	acked bool
+}
+
+type diskQueue struct {
+	opts  QueueOptions
+	mutex sync.Mutex
+
+	// writing side
+	writeFile *os.File
+	committed queuePosition // end of the last durable record
+	written   chan struct{} // nudges the reader when something new is committed
+	finished  bool          // no more writes are coming
+
+	// acknowledgement side
+	inflight    []*queueRecord // in the order they were read
+	cursor      queuePosition  // oldest record not yet delivered to every output
+	cursorDirty bool
+}
+
+func openDiskQueue(opts QueueOptions) (*diskQueue, error) {
+	opts = opts.withDefaults()
+	if err := os.MkdirAll(opts.Path, 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create queue directory: %w", err)
+	}
+	q := &diskQueue{
+		opts:    opts,
+		written: make(chan struct{}, 1),
+	}
+
+	segments, err := q.segments()
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		segments = []uint64{1}
+	}
+
+	// the last segment might end with a partial record if we crashed mid-write
+	last := segments[len(segments)-1]
+	validEnd, err := q.validLength(last)
+	if err != nil {
+		return nil, err
+	}
+	q.writeFile, err = os.OpenFile(q.segmentPath(last), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.writeFile.Truncate(validEnd); err != nil {
+		return nil, fmt.Errorf("cannot repair queue segment: %w", err)
+	}
+	if _, err := q.writeFile.Seek(validEnd, io.SeekStart); err != nil {
+		return nil, err
+	}
+	q.committed = queuePosition{Segment: last, Offset: validEnd}
+
+	q.cursor, err = q.readCursor(segments[0])
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// run is intended to be run as a goroutine
+// events from input are written to disk, then read back out to output
+func (q *diskQueue) run(ctx context.Context, stop context.CancelCauseFunc, input <-chan *Event, output chan<- *Event) {
+	log := ContextLogger(ctx)
+	log.Info("starting persistent queue", "path", q.opts.Path, "cursor", q.cursor)
+	defer q.close()
+
+	go q.readLoop(ctx, stop, output)
+
+	// the cursor is saved periodically rather than on every acknowledgement
+	saveCursor := time.NewTicker(250 * time.Millisecond)
+	defer saveCursor.Stop()
+
+writeLoop:
+	for {
+		select {
+		case <-ctx.Done():
+			break writeLoop
+		case <-saveCursor.C:
+			if err := q.saveCursor(); err != nil {
+				log.Error("failed to save queue cursor", "error", err)
+			}
+		case event, more := <-input:
+			if !more {
+				// inputs have drained; the reader delivers what's left, then closes output
+				q.finishWriting()
+				input = nil
+				continue
+			}
+			if event == nil {
+				stop(fmt.Errorf("queue saw nil event"))
+				break writeLoop
+			}
+			err := q.write(event)
+			// E2E handling
+			// as far as the input is concerned, the event is delivered once it's on disk
+			if event.batch != nil {
+				if err != nil {
+					event.batch.reportError(err)
+					event.batch.reportFailed()
+				} else {
+					event.batch.reportOutput()
+				}
+			}
+			if err != nil {
+				log.Error("failed writing to queue", "error", err)
+				stop(fmt.Errorf("queue failed: %w", err))
+				break writeLoop
+			}
+		}
+	}
+}
+
+func (q *diskQueue) write(event *Event) error {
+	var payload bytes.Buffer
+	queued := queuedEvent{Fields: event.Fields, Metadata: event.Metadata}
+	if event.original != nil {
+		queued.Original = &queuedEvent{Fields: event.original.Fields, Metadata: event.original.Metadata}
+	}
+	if err := gob.NewEncoder(&payload).Encode(queued); err != nil {
+		return fmt.Errorf("cannot encode event for queue: %w", err)
+	}
+	record := make([]byte, queueHeaderSize+payload.Len())
+	binary.BigEndian.PutUint32(record[0:4], uint32(payload.Len()))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload.Bytes()))
+	copy(record[queueHeaderSize:], payload.Bytes())
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	// start a new segment if this one is full
+	if q.committed.Offset > 0 && q.committed.Offset+int64(len(record)) > q.opts.MaxSegmentBytes {
+		next := q.committed.Segment + 1
+		f, err := os.OpenFile(q.segmentPath(next), os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o644)
+		if err != nil {
+			return err
+		}
+		_ = q.writeFile.Close()
+		q.writeFile = f
+		q.committed = queuePosition{Segment: next, Offset: 0}
+	}
+
+	if _, err := q.writeFile.Write(record); err != nil {
+		return err
+	}
+	if err := q.writeFile.Sync(); err != nil {
+		return err
+	}
+	q.committed.Offset += int64(len(record))
+
+	q.nudge()
+	return nil
+}
+
+func (q *diskQueue) finishWriting() {
+	q.mutex.Lock()
+	q.finished = true
+	q.mutex.Unlock()
+	q.nudge()
+}
+
+func (q *diskQueue) nudge() {
+	select {
+	case q.written <- struct{}{}:
+	default:
+		// the reader already knows there's something new
+	}
+}
+
+func (q *diskQueue) readLoop(ctx context.Context, stop context.CancelCauseFunc, output chan<- *Event) {
+	defer close(output)
+	log := ContextLogger(ctx)
+	q.mutex.Lock()
+	readPos := q.cursor
+	q.mutex.Unlock()
+
+	var segment *os.File
+	defer func() {
+		if segment != nil {
+			_ = segment.Close()
+		}
+	}()
+
+	for {
+		q.mutex.Lock()
+		committed := q.committed
+		finished := q.finished
+		q.mutex.Unlock()
+
+		if !readPos.before(committed) {
+			if finished {
+				// everything has been delivered, so downstream can drain
+				return
+			}
+			// caught up with the writer; wait for more
+			select {
+			case <-ctx.Done():
+				return
+			case <-q.written:
+			}
+			continue
+		}
+
+		if segment == nil {
+			var err error
+			segment, err = os.Open(q.segmentPath(readPos.Segment))
+			if err != nil {
+				stop(fmt.Errorf("queue cannot open segment: %w", err))
+				return
+			}
+		}
+
+		queued, next, err := readQueueRecord(segment, readPos.Offset)
+		if errors.Is(err, io.EOF) && readPos.Segment < committed.Segment {
+			// finished with this segment; move on to the next one
+			_ = segment.Close()
+			segment = nil
+			readPos = queuePosition{Segment: readPos.Segment + 1}
+			continue
+		}
+		if err != nil {
+			// skip the rest of a corrupt segment rather than getting stuck on it
+			log.Error("corrupt queue record; skipping rest of segment", "error", err, "position", readPos)
+			_ = segment.Close()
+			segment = nil
+			if readPos.Segment < committed.Segment {
+				readPos = queuePosition{Segment: readPos.Segment + 1}
+			} else {
+				readPos = committed
+			}
+			continue
+		}
+
+		record := &queueRecord{
+			start: readPos,
+			end:   queuePosition{Segment: readPos.Segment, Offset: next},
+		}
+		readPos = record.end
+
+		evt := queued.event()
+		evt.batch = q.track(ctx, record)
+
+		select {
+		case output <- &evt:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// track watches a record until every output is finished with it
+func (q *diskQueue) track(ctx context.Context, record *queueRecord) *publishingBatch {
+	q.mutex.Lock()
+	q.inflight = append(q.inflight, record)
+	q.mutex.Unlock()
+
+	b := newBatch()
+	// outputs can take a long time (eg. retries) but the queue is patient
+	b.slowWarning = 0
+	b.slowDeadline = 0
+	counted := make(chan int, 1)
+	counted <- 1
+	go func() {
+		result, err := b.waitForResults(ctx, counted)
+		if err != nil || !result.Ok {
+			// not acknowledged, so it will be replayed after restart
+			return
+		}
+		q.ack(record)
+	}()
+	return b
+}
+
+func (q *diskQueue) ack(record *queueRecord) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	record.acked = true
+	// the cursor can only move past records that are contiguously acknowledged
+	for len(q.inflight) > 0 && q.inflight[0].acked {
+		q.cursor = q.inflight[0].end
+		q.inflight = q.inflight[1:]
+		q.cursorDirty = true
+	}
+}
+
+func (q *diskQueue) saveCursor() error {
+	q.mutex.Lock()
+	if !q.cursorDirty {
+		q.mutex.Unlock()
+		return nil
+	}
+	cursor := q.cursor
+	writing := q.committed.Segment
+	q.cursorDirty = false
+	q.mutex.Unlock()
+
+	dat, err := json.Marshal(cursor)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(q.opts.Path, queueCursorFile+".tmp")
+	if err := os.WriteFile(tmp, dat, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(q.opts.Path, queueCursorFile)); err != nil {
+		return err
+	}
+
+	// segments before the cursor have been fully delivered
+	segments, err := q.segments()
+	if err != nil {
+		return err
+	}
+	for _, id := range segments {
+		if id < cursor.Segment && id != writing {
+			if err := os.Remove(q.segmentPath(id)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (q *diskQueue) readCursor(firstSegment uint64) (queuePosition, error) {
+	start := queuePosition{Segment: firstSegment}
+	dat, err := os.ReadFile(filepath.Join(q.opts.Path, queueCursorFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return start, nil
+	}
+	if err != nil {
+		return start, err
+	}
+	var cursor queuePosition
+	if err := json.Unmarshal(dat, &cursor); err != nil {
+		return start, fmt.Errorf("corrupt queue cursor: %w", err)
+	}
+	// the segment might have been deleted after the cursor was saved
+	if cursor.before(start) {
+		return start, nil
+	}
+	return cursor, nil
+}
+
+func (q *diskQueue) close() {
+	if err := q.saveCursor(); err != nil {
+		ContextLogger(context.Background()).Error("failed to save queue cursor", "error", err)
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	_ = q.writeFile.Close()
+}
+
+func (q *diskQueue) segmentPath(id uint64) string {
+	return filepath.Join(q.opts.Path, fmt.Sprintf("%016d%s", id, queueSegmentSuffix))
+}
+
+// segments returns the IDs of all segment files, oldest first
+func (q *diskQueue) segments() ([]uint64, error) {
+	entries, err := os.ReadDir(q.opts.Path)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint64, 0, len(entries))
+	for _, entry := range entries {
+		name, isSegment := strings.CutSuffix(entry.Name(), queueSegmentSuffix)
+		if !isSegment {
+			continue
+		}
+		id, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// validLength finds where the last complete, uncorrupted record ends
+func (q *diskQueue) validLength(id uint64) (int64, error) {
+	f, err := os.Open(q.segmentPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var offset int64
+	for {
+		_, next, err := readQueueRecord(f, offset)
+		if err != nil {
+			return offset, nil
+		}
+		offset = next
+	}
+}
+
+// returns the decoded event and the offset of the next record
+func readQueueRecord(f *os.File, offset int64) (queuedEvent, int64, error) {
+	var queued queuedEvent
+	header := make([]byte, queueHeaderSize)
+	n, err := f.ReadAt(header, offset)
+	if n == 0 && errors.Is(err, io.EOF) {
+		return queued, offset, io.EOF
+	}
+	if err != nil {
+		return queued, offset, fmt.Errorf("short record header: %w", err)
+	}
+	length := binary.BigEndian.Uint32(header[0:4])
+	checksum := binary.BigEndian.Uint32(header[4:8])
+
+	// a corrupt length could ask for gigabytes, so it must fit in what's left of the file
+	info, err := f.Stat()
+	if err != nil {
+		return queued, offset, err
+	}
+	if remaining := info.Size() - offset - queueHeaderSize; int64(length) > remaining {
+		return queued, offset, fmt.Errorf("record length %d is more than the %d bytes left in the segment", length, remaining)
+	}
+
+	payload := make([]byte, length)
+	if _, err := f.ReadAt(payload, offset+queueHeaderSize); err != nil {
+		return queued, offset, fmt.Errorf("short record payload: %w", err)
+	}
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return queued, offset, fmt.Errorf("record checksum mismatch")
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&queued); err != nil {
+		return queued, offset, fmt.Errorf("cannot decode record: %w", err)
+	}
+	return queued, offset + queueHeaderSize + int64(length), nil
+}
@@ -0,0 +1,254 @@
+package loglang
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testQueue struct {
+	q      *diskQueue
+	input  chan *Event
+	output chan *Event
+	stop   context.CancelCauseFunc
+	done   chan struct{}
+}
+
+func startTestQueue(t *testing.T, opts QueueOptions) *testQueue {
+	t.Helper()
+	q, err := openDiskQueue(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, stop := context.WithCancelCause(context.Background())
+	tq := &testQueue{
+		q:      q,
+		input:  make(chan *Event),
+		output: make(chan *Event),
+		stop:   stop,
+		done:   make(chan struct{}),
+	}
+	go func() {
+		q.run(ctx, stop, tq.input, tq.output)
+		close(tq.done)
+	}()
+	return tq
+}
+
+func (tq *testQueue) receive(t *testing.T) *Event {
+	t.Helper()
+	select {
+	case evt := <-tq.output:
+		return evt
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for queued event")
+		return nil
+	}
+}
+
+// acknowledge as if every output was done with the event, then wait for the cursor to move
+func (tq *testQueue) ack(t *testing.T, evt *Event) {
+	t.Helper()
+	tq.q.mutex.Lock()
+	before := tq.q.cursor
+	tq.q.mutex.Unlock()
+	evt.batch.reportOutput()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		tq.q.mutex.Lock()
+		after := tq.q.cursor
+		tq.q.mutex.Unlock()
+		if before.before(after) {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("queue never processed acknowledgement")
+}
+
+func (tq *testQueue) shutdown() {
+	tq.stop(nil)
+	<-tq.done
+}
+
+func TestDiskQueue_RoundTrip(t *testing.T) {
+	tq := startTestQueue(t, QueueOptions{Path: t.TempDir()})
+	defer tq.shutdown()
+
+	for _, evt := range messageEvents("one", "two", "three") {
+		evt.Fields["nested"] = map[string]any{"list": []any{"a", 1}}
+		evt.Field(MetadataField, "route").SetString("alerts")
+		evt.keepOriginal()
+		evt.Field("filtered").SetBool(true)
+		tq.input <- evt
+	}
+	for _, expected := range []string{"one", "two", "three"} {
+		evt := tq.receive(t)
+		if msg := evt.Field("message").GetString(); msg != expected {
+			t.Errorf("expected %s but got %s", expected, msg)
+		}
+		if list, _ := evt.Field("nested", "list").Get(); list == nil {
+			t.Error("expected nested fields to survive the queue")
+		}
+		if route := evt.Field(MetadataField, "route").GetString(); route != "alerts" {
+			t.Errorf("expected metadata to survive the queue but got %q", route)
+		}
+		if evt.original == nil || evt.original.Field("message").GetString() != expected {
+			t.Fatal("expected the original event to survive the queue")
+		}
+		if _, err := evt.original.Field("filtered").Get(); err == nil {
+			t.Error("expected the original event to be kept as it was")
+		}
+		tq.ack(t, evt)
+	}
+}
+
+func TestDiskQueue_ReplayUnacknowledged(t *testing.T) {
+	dir := t.TempDir()
+	tq := startTestQueue(t, QueueOptions{Path: dir})
+	for _, evt := range messageEvents("one", "two", "three") {
+		tq.input <- evt
+	}
+	tq.ack(t, tq.receive(t))
+	tq.receive(t)
+	tq.shutdown()
+
+	tq = startTestQueue(t, QueueOptions{Path: dir})
+	defer tq.shutdown()
+	for _, expected := range []string{"two", "three"} {
+		evt := tq.receive(t)
+		if msg := evt.Field("message").GetString(); msg != expected {
+			t.Errorf("expected %s to be replayed but got %s", expected, msg)
+		}
+	}
+}
+
+func TestDiskQueue_RemovesDeliveredSegments(t *testing.T) {
+	dir := t.TempDir()
+	tq := startTestQueue(t, QueueOptions{Path: dir, MaxSegmentBytes: 1})
+	for _, evt := range messageEvents("one", "two", "three") {
+		tq.input <- evt
+	}
+	for i := 0; i < 3; i++ {
+		tq.ack(t, tq.receive(t))
+	}
+	tq.shutdown()
+
+	segments, err := filepath.Glob(filepath.Join(dir, "*"+queueSegmentSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 1 {
+		t.Errorf("expected only the current segment to remain but found %v", segments)
+	}
+}
+
+func TestDiskQueue_TruncatesCorruptTail(t *testing.T) {
+	dir := t.TempDir()
+	tq := startTestQueue(t, QueueOptions{Path: dir})
+	tq.input <- messageEvents("intact")[0]
+	tq.receive(t)
+	tq.shutdown()
+
+	// simulate a crash halfway through writing a record
+	f, err := os.OpenFile(filepath.Join(dir, "0000000000000001"+queueSegmentSuffix), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.Write([]byte{0, 0, 1, 0, 0xde, 0xad})
+	_ = f.Close()
+
+	tq = startTestQueue(t, QueueOptions{Path: dir})
+	defer tq.shutdown()
+	tq.input <- messageEvents("after")[0]
+	for _, expected := range []string{"intact", "after"} {
+		evt := tq.receive(t)
+		if msg := evt.Field("message").GetString(); msg != expected {
+			t.Errorf("expected %s but got %s", expected, msg)
+		}
+	}
+}
+
+func TestPipeline_Queue(t *testing.T) {
+	p := NewPipeline("test", PipelineOptions{Queue: QueueOptions{Path: t.TempDir()}})
+	in := &sliceInput{events: messageEvents("one", "two")}
+	p.Input("slice", in)
+	out := &memoryOutput{}
+	p.Output("memory", out, nil, nil)
+
+	if err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if in.result == nil || in.result.SuccessCount != 2 {
+		t.Errorf("expected input to be acknowledged once events were queued but got %v", in.result)
+	}
+}
+
+func TestDiskQueue_RejectsCorruptLength(t *testing.T) {
+	dir := t.TempDir()
+	// a header claiming a 4 GB record, followed by a few bytes
+	record := []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 1, 2, 3}
+	if err := os.WriteFile(filepath.Join(dir, "0000000000000001"+queueSegmentSuffix), record, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filepath.Join(dir, "0000000000000001"+queueSegmentSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// rejected before anything is allocated for it
+	if _, _, err := readQueueRecord(f, 0); err == nil || !strings.Contains(err.Error(), "record length") {
+		t.Errorf("expected a length past the end of the segment to be rejected but got %v", err)
+	}
+}
+
+func TestDiskQueue_WriteFailureFailsBatch(t *testing.T) {
+	tq := startTestQueue(t, QueueOptions{Path: t.TempDir()})
+	defer tq.shutdown()
+	tq.q.mutex.Lock()
+	_ = tq.q.writeFile.Close()
+	tq.q.mutex.Unlock()
+
+	evt := messageEvents("lost")[0]
+	evt.batch = newBatch()
+	counted := make(chan int, 1)
+	counted <- 1
+	go func() { tq.input <- evt }()
+	result, err := evt.batch.waitForResults(context.Background(), counted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Ok || result.SuccessCount != 0 || result.ErrorCount != 1 || result.FailedCount != 1 {
+		t.Errorf("expected an event that wasn't stored to fail the batch but got %v", result.Summary())
+	}
+}
+
+func TestPipeline_QueueReplaysFailedOutput(t *testing.T) {
+	dir := t.TempDir()
+	p := NewPipeline("test", PipelineOptions{Queue: QueueOptions{Path: dir}})
+	p.Input("slice", &sliceInput{events: messageEvents("one", "two")})
+	p.Output("broken", &brokenOutput{}, nil, nil)
+	if err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// with no dead letter output, the events are still on disk after a restart
+	p = NewPipeline("test", PipelineOptions{Queue: QueueOptions{Path: dir}})
+	p.Input("slice", &sliceInput{})
+	out := &memoryOutput{}
+	p.Output("memory", out, nil, nil)
+	if err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.events) != 2 {
+		t.Fatalf("expected the failed events to be replayed but got %d", len(out.events))
+	}
+	for i, expected := range []string{"one", "two"} {
+		if msg := out.events[i].Field("message").GetString(); msg != expected {
+			t.Errorf("expected %s to be replayed but got %s", expected, msg)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package loglang
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Plugins register a constructor under a name so that pipelines can be built from config files.
+// Each plugin package does this in init(), so it must be imported (even if only for side effects)
+// before calling LoadPipeline().
+//
+// Options for each plugin are decoded from config into the type T taken by the constructor.
+
+type pluginFactory[P any] func(options *yaml.Node) (P, error)
+
+type pluginRegistry[P any] struct {
+	kind      string
+	mutex     sync.RWMutex
+	factories map[string]pluginFactory[P]
+	options   map[string]reflect.Type
+}
+
+func newRegistry[P any](kind string) *pluginRegistry[P] {
+	return &pluginRegistry[P]{
+		kind:      kind,
+		factories: make(map[string]pluginFactory[P]),
+		options:   make(map[string]reflect.Type),
+	}
+}
+
+var (
+	inputRegistry   = newRegistry[InputPlugin]("input")
+	filterRegistry  = newRegistry[FilterPlugin]("filter")
+	outputRegistry  = newRegistry[OutputPlugin]("output")
+	codecRegistry   = newRegistry[CodecPlugin]("codec")
+	framingRegistry = newRegistry[FramingPlugin]("framing")
+)
+
+func RegisterInput[T any](name string, constructor func(options T) (InputPlugin, error)) {
+	inputRegistry.add(name, typedFactory(constructor), reflect.TypeOf((*T)(nil)).Elem())
+}
+
+func RegisterFilter[T any](name string, constructor func(options T) (FilterPlugin, error)) {
+	filterRegistry.add(name, typedFactory(constructor), reflect.TypeOf((*T)(nil)).Elem())
+}
+
+func RegisterOutput[T any](name string, constructor func(options T) (OutputPlugin, error)) {
+	outputRegistry.add(name, typedFactory(constructor), reflect.TypeOf((*T)(nil)).Elem())
+}
+
+func RegisterCodec[T any](name string, constructor func(options T) (CodecPlugin, error)) {
+	codecRegistry.add(name, typedFactory(constructor), reflect.TypeOf((*T)(nil)).Elem())
+}
+
+func RegisterFraming[T any](name string, constructor func(options T) (FramingPlugin, error)) {
+	framingRegistry.add(name, typedFactory(constructor), reflect.TypeOf((*T)(nil)).Elem())
+}
+
+type PluginInfo struct {
+	Kind    string
+	Name    string
+	Options []PluginOption
+}
+
+type PluginOption struct {
+	Key  string
+	Type string
+}
+
+// RegisteredPlugins describes every registered plugin, ordered by kind and then name
+func RegisteredPlugins() []PluginInfo {
+	var plugins []PluginInfo
+	plugins = append(plugins, inputRegistry.describe()...)
+	plugins = append(plugins, filterRegistry.describe()...)
+	plugins = append(plugins, outputRegistry.describe()...)
+	plugins = append(plugins, codecRegistry.describe()...)
+	plugins = append(plugins, framingRegistry.describe()...)
+	return plugins
+}
+
+// BuildCodec makes a codec from config; useful for plugins that take a codec as an option
+func BuildCodec(spec PluginSpec) (CodecPlugin, error) {
+	return codecRegistry.build(spec.Type, &spec.Options)
+}
+
+// BuildFilter makes a filter from config; useful for filters that wrap other filters
+func BuildFilter(spec PluginSpec) (FilterPlugin, error) {
+	return filterRegistry.build(spec.Type, &spec.Options)
+}
+
+// BuildFraming makes a framing plugin from config; useful for plugins that take framing as an option
+func BuildFraming(spec PluginSpec) (FramingPlugin, error) {
+	return framingRegistry.build(spec.Type, &spec.Options)
+}
+
+// CheckSchema rejects unknown schemas; useful for plugins that take a schema as an option
+func CheckSchema(schema SchemaModel) error {
+	switch schema {
+	case SchemaNotDefined, SchemaNone, SchemaFlat, SchemaECS, SchemaLogstashFlat, SchemaLogstashECS:
+		return nil
+	}
+	return fmt.Errorf("unknown schema %q", schema)
+}
+
+func (r *pluginRegistry[P]) add(name string, factory pluginFactory[P], options reflect.Type) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, exists := r.factories[name]; exists {
+		panic(fmt.Sprintf("%s plugin %q is already registered", r.kind, name))
+	}
+	r.factories[name] = factory
+	r.options[name] = options
+}
+
+func (r *pluginRegistry[P]) build(name string, options *yaml.Node) (P, error) {
+	r.mutex.RLock()
+	factory, exists := r.factories[name]
+	r.mutex.RUnlock()
+	if !exists {
+		var zero P
+		if name == "" {
+			return zero, &ConfigError{Key: "type", Err: fmt.Errorf("missing %s type", r.kind)}
+		}
+		return zero, &ConfigError{
+			Key: "type",
+			Err: fmt.Errorf("unknown %s type %q (known: %s)", r.kind, name, strings.Join(r.names(), ", ")),
+		}
+	}
+	plugin, err := factory(options)
+	if err == nil {
+		return plugin, nil
+	}
+	if _, isConfigErr := err.(*ConfigError); !isConfigErr {
+		// constructors return plain errors when they reject their options
+		err = &ConfigError{Line: options.Line, Err: err}
+	}
+	return plugin, nestConfigError("options", err)
+}
+
+func (r *pluginRegistry[P]) names() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *pluginRegistry[P]) describe() []PluginInfo {
+	names := r.names()
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	plugins := make([]PluginInfo, 0, len(names))
+	for _, name := range names {
+		plugins = append(plugins, PluginInfo{
+			Kind:    r.kind,
+			Name:    name,
+			Options: describeOptions(r.options[name]),
+		})
+	}
+	return plugins
+}
+
+func describeOptions(t reflect.Type) []PluginOption {
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var options []PluginOption
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, isInline := yamlKey(field)
+		switch {
+		case key == "":
+			continue
+		case isInline:
+			options = append(options, describeOptions(field.Type)...)
+		default:
+			options = append(options, PluginOption{Key: key, Type: field.Type.String()})
+		}
+	}
+	return options
+}
+
+func typedFactory[T any, P any](constructor func(T) (P, error)) pluginFactory[P] {
+	return func(options *yaml.Node) (P, error) {
+		var opts T
+		if err := decodeStrict(options, &opts); err != nil {
+			var zero P
+			return zero, err
+		}
+		return constructor(opts)
+	}
+}
+
+// decodeStrict is like yaml.Node.Decode() but rejects unknown keys
+// because a typo in an option name should not be silently ignored
+func decodeStrict(node *yaml.Node, out any) error {
+	if node == nil || node.Kind == 0 {
+		// options were omitted entirely
+		return nil
+	}
+	if err := checkKnownKeys(node, reflect.TypeOf(out).Elem()); err != nil {
+		return err
+	}
+	if err := node.Decode(out); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			// yaml.v3 already mentions the line numbers
+			return &ConfigError{Err: errors.New(strings.Join(typeErr.Errors, "; "))}
+		}
+		return err
+	}
+	return nil
+}
+
+var nodeType = reflect.TypeOf(yaml.Node{})
+
+func checkKnownKeys(node *yaml.Node, t reflect.Type) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if node.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice {
+		for i, item := range node.Content {
+			if err := checkKnownKeys(item, t.Elem()); err != nil {
+				return nestConfigError(fmt.Sprintf("[%d]", i), err)
+			}
+		}
+		return nil
+	}
+	if node.Kind != yaml.MappingNode || t.Kind() != reflect.Struct || t == nodeType {
+		// anything goes in a yaml.Node; it will get checked later
+		return nil
+	}
+	known := make(map[string]reflect.Type)
+	collectKeys(t, known)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		fieldType, isKnown := known[key.Value]
+		if !isKnown {
+			return &ConfigError{Line: key.Line, Key: key.Value, Err: fmt.Errorf("unknown key")}
+		}
+		if err := checkKnownKeys(node.Content[i+1], fieldType); err != nil {
+			return nestConfigError(key.Value, err)
+		}
+	}
+	return nil
+}
+
+func collectKeys(t reflect.Type, known map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, isInline := yamlKey(field)
+		switch {
+		case key == "":
+			continue
+		case isInline:
+			collectKeys(field.Type, known)
+		default:
+			known[key] = field.Type
+		}
+	}
+}
+
+// yamlKey finds the config key for a struct field, the same way yaml.v3 does
+// an empty key means the field isn't part of the config
+func yamlKey(field reflect.StructField) (key string, isInline bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+	key, flags, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if key == "-" {
+		return "", false
+	}
+	if flags == "inline" && field.Type.Kind() == reflect.Struct {
+		return field.Name, true
+	}
+	if key == "" {
+		key = strings.ToLower(field.Name)
+	}
+	return key, false
+}
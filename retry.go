@@ -0,0 +1,116 @@
+package loglang
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// PermanentError marks an error that will never succeed by trying again.
+// (eg. bad credentials, or an event that can't be encoded)
+// Outputs should wrap errors with Permanent() to skip the retry policy.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
+
+// RetryOptions decide what happens when OutputPlugin.Send() fails.
+// The zero value tries exactly once.
+type RetryOptions struct {
+	// MaxAttempts includes the first attempt
+	MaxAttempts int `yaml:"maxAttempts"`
+	// InitialBackoff is the delay before the second attempt
+	InitialBackoff time.Duration `yaml:"initialBackoff"`
+	// MaxBackoff caps the exponential growth of the delay
+	MaxBackoff time.Duration `yaml:"maxBackoff"`
+	// Multiplier is how much the delay grows after each attempt
+	Multiplier float64 `yaml:"multiplier"`
+}
+
+func (o RetryOptions) withDefaults() RetryOptions {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 1
+	}
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = 100 * time.Millisecond
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 30 * time.Second
+	}
+	if o.Multiplier < 1 {
+		o.Multiplier = 2
+	}
+	return o
+}
+
+// backoff for the given attempt (starting at 1) with "equal jitter"
+// half the delay is fixed, and the other half is random
+// so that many outputs failing together don't retry in lockstep
+func (o RetryOptions) backoff(attempt int) time.Duration {
+	delay := float64(o.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		delay *= o.Multiplier
+		if delay >= float64(o.MaxBackoff) {
+			delay = float64(o.MaxBackoff)
+			break
+		}
+	}
+	half := time.Duration(delay / 2)
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// Retry calls fn until it succeeds, returns a permanent error, or runs out of attempts.
+// Returns the last error, and how many attempts were made.
+func Retry(ctx context.Context, opts RetryOptions, fn func() error) (int, error) {
+	log := ContextLogger(ctx)
+	opts = opts.withDefaults()
+	attempt := 1
+	for {
+		err := fn()
+		if err == nil {
+			return attempt, nil
+		}
+		if IsPermanent(err) {
+			return attempt, err
+		}
+		if attempt >= opts.MaxAttempts {
+			if opts.MaxAttempts > 1 {
+				return attempt, fmt.Errorf("gave up after %d attempts: %w", attempt, err)
+			}
+			return attempt, err
+		}
+		delay := opts.backoff(attempt)
+		log.Warn("retrying after error",
+			"error", err,
+			"attempt", attempt,
+			"delay", delay,
+		)
+		select {
+		case <-ctx.Done():
+			return attempt, fmt.Errorf("stopped retrying: %w", err)
+		case <-time.After(delay):
+		}
+		attempt++
+	}
+}
@@ -0,0 +1,80 @@
+package loglang
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRetry_EventuallySucceeds(t *testing.T) {
+	calls := 0
+	attempts, err := Retry(context.Background(), RetryOptions{
+		MaxAttempts:    5,
+		InitialBackoff: time.Millisecond,
+	}, func() error {
+		calls++
+		if calls < 3 {
+			return fmt.Errorf("not yet")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts but got %d", attempts)
+	}
+}
+
+func TestRetry_GivesUp(t *testing.T) {
+	failure := fmt.Errorf("always broken")
+	attempts, err := Retry(context.Background(), RetryOptions{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+	}, func() error {
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("expected the last error but got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts but got %d", attempts)
+	}
+}
+
+func TestRetry_PermanentError(t *testing.T) {
+	calls := 0
+	attempts, err := Retry(context.Background(), RetryOptions{
+		MaxAttempts:    5,
+		InitialBackoff: time.Millisecond,
+	}, func() error {
+		calls++
+		return Permanent(fmt.Errorf("bad credentials"))
+	})
+	if !IsPermanent(err) {
+		t.Errorf("expected a permanent error but got %v", err)
+	}
+	if attempts != 1 || calls != 1 {
+		t.Errorf("permanent errors must not be retried (attempts=%d)", attempts)
+	}
+}
+
+func TestRetryOptions_Backoff(t *testing.T) {
+	opts := RetryOptions{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+	}.withDefaults()
+	for attempt, expected := range map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		2:  200 * time.Millisecond,
+		3:  400 * time.Millisecond,
+		10: time.Second,
+	} {
+		actual := opts.backoff(attempt)
+		if actual < expected/2 || actual > expected {
+			t.Errorf("attempt %d: expected between %v and %v but got %v", attempt, expected/2, expected, actual)
+		}
+	}
+}
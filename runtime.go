@@ -0,0 +1,158 @@
+package loglang
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Runtime hosts several pipelines in one process, so they can be linked
+// with PipelineOutput and PipelineInput instead of copy-pasting filters.
+//
+// Pipelines are stopped upstream first: a pipeline is only stopped once every pipeline
+// that sends to it has finished, so events in flight between pipelines are not lost.
+type Runtime struct {
+	mutex     sync.Mutex
+	pipelines []*Pipeline
+	// closed when a pipeline's Run() returns
+	finished map[*Pipeline]chan struct{}
+	stopping bool
+}
+
+func NewRuntime() *Runtime {
+	return &Runtime{finished: make(map[*Pipeline]chan struct{})}
+}
+
+func (r *Runtime) Add(p *Pipeline) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.pipelines = append(r.pipelines, p)
+	r.finished[p] = make(chan struct{})
+}
+
+// Pipeline finds a pipeline by name, or returns nil
+func (r *Runtime) Pipeline(name string) *Pipeline {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, p := range r.pipelines {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// Run every pipeline until they have all finished
+func (r *Runtime) Run() error {
+	r.mutex.Lock()
+	pipelines := append([]*Pipeline(nil), r.pipelines...)
+	r.mutex.Unlock()
+
+	if len(pipelines) == 0 {
+		return fmt.Errorf("no pipelines configured")
+	}
+	if err := checkLinks(pipelines); err != nil {
+		return err
+	}
+
+	errs := make([]error, len(pipelines))
+	var allFinished sync.WaitGroup
+	for i, p := range pipelines {
+		allFinished.Add(1)
+		go func(i int, p *Pipeline) {
+			defer allFinished.Done()
+			defer close(r.finished[p])
+			if err := p.Run(); err != nil {
+				errs[i] = fmt.Errorf("pipeline %s: %w", p.Name, err)
+			}
+		}(i, p)
+	}
+	allFinished.Wait()
+	return errors.Join(errs...)
+}
+
+// Stop every pipeline gracefully, upstream pipelines first
+func (r *Runtime) Stop(reason string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.stopping {
+		return
+	}
+	r.stopping = true
+	for _, p := range r.pipelines {
+		var upstream []chan struct{}
+		for _, other := range r.pipelines {
+			if other != p && sendsTo(other, p) {
+				upstream = append(upstream, r.finished[other])
+			}
+		}
+		go func(p *Pipeline, upstream []chan struct{}) {
+			for _, finished := range upstream {
+				<-finished
+			}
+			p.Stop(reason)
+		}(p, upstream)
+	}
+}
+
+// checkLinks rejects duplicate names, and cycles that could never be stopped in order
+func checkLinks(pipelines []*Pipeline) error {
+	names := make(map[string]bool)
+	for _, p := range pipelines {
+		if names[p.Name] {
+			return fmt.Errorf("duplicate pipeline name %q", p.Name)
+		}
+		names[p.Name] = true
+	}
+
+	// depth-first search for a cycle
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[*Pipeline]int)
+	var visit func(p *Pipeline, path []string) error
+	visit = func(p *Pipeline, path []string) error {
+		path = append(path, p.Name)
+		switch state[p] {
+		case visiting:
+			return fmt.Errorf("pipelines are linked in a cycle: %v", path)
+		case visited:
+			return nil
+		}
+		state[p] = visiting
+		for _, next := range pipelines {
+			if sendsTo(p, next) {
+				if err := visit(next, path); err != nil {
+					return err
+				}
+			}
+		}
+		state[p] = visited
+		return nil
+	}
+	for _, p := range pipelines {
+		if err := visit(p, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendsTo is true if upstream has a PipelineOutput for an address that downstream listens on
+func sendsTo(upstream *Pipeline, downstream *Pipeline) bool {
+	for _, output := range upstream.outputs {
+		out, isLink := output.Value.output.(*pipelineOutput)
+		if !isLink {
+			continue
+		}
+		for _, input := range downstream.inputs {
+			in, isLink := input.Value.plugin.(*pipelineInput)
+			if isLink && in.address == out.address {
+				return true
+			}
+		}
+	}
+	return false
+}
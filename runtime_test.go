@@ -0,0 +1,123 @@
+package loglang
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRuntime_Link(t *testing.T) {
+	ingest := NewPipeline("ingest", PipelineOptions{})
+	in := &sliceInput{events: messageEvents("alert: disk full", "hello", "alert: cpu hot")}
+	ingest.Input("slice", in)
+	ingest.Output("to alerts", PipelineOutput("test-alerts"), nil, nil)
+	ingest.Output("to archive", PipelineOutput("test-archive"), nil, nil)
+
+	alerts := NewPipeline("alerts", PipelineOptions{})
+	alerts.Input("from ingest", PipelineInput("test-alerts"))
+	alerts.Filter("only alerts", func(event *Event, inject chan<- *Event, drop func()) error {
+		if !strings.HasPrefix(event.Field("message").GetString(), "alert") {
+			drop()
+		}
+		return nil
+	})
+	alerted := &memoryOutput{}
+	alerts.Output("memory", alerted, nil, nil)
+
+	archive := NewPipeline("archive", PipelineOptions{})
+	archive.Input("from ingest", PipelineInput("test-archive"))
+	archived := &memoryOutput{}
+	archive.Output("memory", archived, nil, nil)
+
+	r := NewRuntime()
+	r.Add(alerts)
+	r.Add(archive)
+	r.Add(ingest)
+	// downstream pipelines must not stop until ingest has delivered everything
+	r.Stop("test")
+	if err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if in.result == nil || in.result.SuccessCount != 3 {
+		t.Errorf("expected the ingest batch to be acknowledged after delivery but got %v", in.result)
+	}
+	if len(alerted.events) != 2 {
+		t.Errorf("expected 2 alerts but got %d", len(alerted.events))
+	}
+	if len(archived.events) != 3 {
+		t.Errorf("expected 3 archived events but got %d", len(archived.events))
+	}
+}
+
+func TestRuntime_LinkPropagatesErrors(t *testing.T) {
+	upstream := NewPipeline("upstream", PipelineOptions{})
+	in := &sliceInput{events: messageEvents("one")}
+	upstream.Input("slice", in)
+	upstream.Output("link", PipelineOutput("test-broken"), nil, nil)
+
+	downstream := NewPipeline("downstream", PipelineOptions{})
+	downstream.Input("link", PipelineInput("test-broken"))
+	downstream.Output("broken", &brokenOutput{}, nil, nil)
+
+	r := NewRuntime()
+	r.Add(upstream)
+	r.Add(downstream)
+	r.Stop("test")
+	if err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if in.result == nil || in.result.ErrorCount != 1 {
+		t.Errorf("expected the downstream error to reach the upstream input but got %v", in.result)
+	}
+}
+
+func TestRuntime_LinkDeliveredDespiteErrors(t *testing.T) {
+	upstream := NewPipeline("upstream", PipelineOptions{})
+	in := &sliceInput{events: messageEvents("one")}
+	upstream.Input("slice", in)
+	upstream.OutputWithOptions("link", PipelineOutput("test-delivered"), nil, nil, OutputOptions{
+		Retry: RetryOptions{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+	})
+
+	downstream := NewPipeline("downstream", PipelineOptions{})
+	downstream.Input("link", PipelineInput("test-delivered"))
+	// the error is reported, but the event carries on to the output
+	downstream.Filter("complain", func(event *Event, inject chan<- *Event, drop func()) error {
+		return fmt.Errorf("not quite right")
+	})
+	out := &memoryOutput{}
+	downstream.Output("memory", out, nil, nil)
+
+	r := NewRuntime()
+	r.Add(upstream)
+	r.Add(downstream)
+	r.Stop("test")
+	if err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if in.result == nil || !in.result.Ok || in.result.SuccessCount != 1 {
+		t.Errorf("expected the delivered event to succeed upstream but got %v", in.result)
+	}
+	if len(out.events) != 1 {
+		t.Errorf("expected the event to be delivered once, not retried, but got %d", len(out.events))
+	}
+}
+
+func TestRuntime_Cycle(t *testing.T) {
+	a := NewPipeline("a", PipelineOptions{})
+	a.Input("from b", PipelineInput("test-b-to-a"))
+	a.Output("to b", PipelineOutput("test-a-to-b"), nil, nil)
+	b := NewPipeline("b", PipelineOptions{})
+	b.Input("from a", PipelineInput("test-a-to-b"))
+	b.Output("to a", PipelineOutput("test-b-to-a"), nil, nil)
+
+	r := NewRuntime()
+	r.Add(a)
+	r.Add(b)
+	err := r.Run()
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("expected a cycle to be rejected but got %v", err)
+	}
+}
@@ -2,8 +2,11 @@ package loglang
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,43 +22,114 @@ type Sender interface {
 	SendRaw(context.Context, *Event, io.Reader) (*BatchResult, error)
 	// send byte stream, but prescribe a specific framing and codec strategy
 	SendWithFramingCodec(context.Context, *Event, FramingPlugin, CodecPlugin, io.Reader) (*BatchResult, error)
+	// like SendWithFramingCodec, but with several framing stages, outermost first (eg. gzip then lines)
+	SendWithFramingChain(context.Context, *Event, []FramingPlugin, CodecPlugin, io.Reader) (*BatchResult, error)
 	// Opt-in for end-to-end acknowledgement
 	SetE2E(bool)
+	// how long an E2E batch can take before it fails; 0 waits for as long as it takes
+	SetBatchDeadline(time.Duration)
 }
 
+// DefaultBatchDeadline is how long an E2E batch can take, unless the input chooses otherwise
+const DefaultBatchDeadline = 10 * time.Second
+
+// ErrSenderClosed is returned when an input tries to send after its pipeline started shutting down
+var ErrSenderClosed = errors.New("sender is closed; pipeline is shutting down")
+
 type SimpleSender struct {
-	e2e     bool
-	events  chan *Event
-	extract Extractor
-	ctx     context.Context
-	fanout  int
+	e2e           bool
+	batchDeadline time.Duration
+	// keepOriginals is set when the pipeline has a dead letter output
+	keepOriginals bool
+	events        chan *Event
+	extract       Extractor
+	ctx           context.Context
+	fanout        int
+
+	// sends can still be happening in other goroutines (eg. HTTP handlers)
+	// after the input plugin's Run() returns, so Close() waits for them
+	mutex    sync.RWMutex
+	closed   bool
+	inflight sync.WaitGroup
+
+	sent           *Counter
+	batchDuration  *Histogram
+	status         *pluginStatus
+	pendingBatches atomic.Int64
 }
 
 func NewSender(ctx context.Context, events chan *Event, extract Extractor, fanout int) *SimpleSender {
+	metrics := ContextMetrics(ctx)
+	labels := pluginLabels(ctx, "input")
+	status, _ := ctx.Value(contextKeyStatus).(*pluginStatus)
 	return &SimpleSender{
-		e2e:     false,
-		events:  events,
-		extract: extract,
-		ctx:     ctx,
-		fanout:  fanout,
+		e2e:           false,
+		batchDeadline: DefaultBatchDeadline,
+		events:        events,
+		extract:       extract,
+		ctx:           ctx,
+		fanout:        fanout,
+
+		sent:          metrics.Counter("loglang_events_out_total", helpEventsOut, labels),
+		batchDuration: metrics.Histogram("loglang_batch_duration_seconds", "Time for an input batch to be acknowledged end-to-end", labels, DefaultBuckets),
+		status:        status,
+	}
+}
+
+func (s *SimpleSender) push(event *Event) {
+	// sending anything at all means the input has started
+	s.status.ready()
+	if s.keepOriginals {
+		event.keepOriginal()
+	}
+	s.events <- event
+	s.sent.Inc()
+}
+
+// wait for an E2E batch, keeping track of how many are pending
+func (s *SimpleSender) wait(ctx context.Context, b *publishingBatch, counted chan int) (*BatchResult, error) {
+	s.pendingBatches.Add(1)
+	defer s.pendingBatches.Add(-1)
+	result, err := b.waitForResults(ctx, counted)
+	if result != nil {
+		if !result.Finish.IsZero() {
+			s.batchDuration.ObserveDuration(result.Finish.Sub(result.Start))
+		}
+		for _, batchErr := range result.Errors {
+			s.status.recordError(batchErr)
+		}
 	}
+	s.status.recordError(err)
+	return result, err
 }
 
 func (s *SimpleSender) Send(events ...*Event) *BatchResult {
+	if !s.begin() {
+		return &BatchResult{Ok: false, ErrorCount: len(events), Errors: []error{ErrSenderClosed}}
+	}
+	defer s.inflight.Done()
+
 	if s.e2e {
 		b := newBatch()
-		for _, event := range events {
-			event.batch = b
-			s.events <- event
-		}
+		b.slowDeadline = s.batchDeadline
 		counted := make(chan int, 1)
-		counted <- len(events)
+		// the batch must be monitored while events are being sent
+		// otherwise filters and outputs get stuck reporting progress
+		s.inflight.Add(1)
+		go func() {
+			defer s.inflight.Done()
+			for _, event := range events {
+				event.batch = b
+				s.push(event)
+			}
+			counted <- len(events)
+		}()
 		// TODO: maybe don't ignore this error? log it?
-		result, _ := b.waitForResults(context.TODO(), counted)
+		result, _ := s.wait(context.TODO(), b, counted)
 		return result
 	} else {
 		for _, event := range events {
-			s.events <- event
+			s.push(event)
 		}
 		return nil
 	}
@@ -69,179 +143,149 @@ func (s *SimpleSender) Send(events ...*Event) *BatchResult {
 // function is named SendRaw because it's sending raw byte stream reader
 // deferring the framing and codec decisions to the pipeline configuration
 func (s *SimpleSender) SendRaw(ctx context.Context, template *Event, byteStream io.Reader) (*BatchResult, error) {
-	log := ContextLogger(ctx)
+	if !s.begin() {
+		return nil, ErrSenderClosed
+	}
+	defer s.inflight.Done()
 
 	// FIXME: make better decisions about what framing/codec to use
 	// like, it should be a variable on the struct
-	//return s.SendWithFramingCodec(template, framing.Lines(), codec.Auto(), byteStream)
-	//	type Extractor func(context.Context, *Event, io.Reader, chan *Event) error
-
-	// err := s.extract(s.ctx, template, byteStream, events)
+	return s.sendExtracted(ctx, s.extract, template, byteStream)
+}
 
-	if s.e2e {
-		// prepare the batch
-		b := newBatch()
-		count := 0
+func (s *SimpleSender) SendWithFramingCodec(ctx context.Context, template *Event, f FramingPlugin, c CodecPlugin, byteStream io.Reader) (*BatchResult, error) {
+	return s.SendWithFramingChain(ctx, template, []FramingPlugin{f}, c, byteStream)
+}
 
-		// get started for real
-		events := make(chan *Event)
-		go func() {
-			// TODO: use request context or sender context?
-			//err := s.extract(s.ctx, template, byteStream, events)
-			err := s.extract(ctx, template, byteStream, events)
-			if err != nil {
-				log.Error("error", "error", err)
-			}
-		}()
+func (s *SimpleSender) SendWithFramingChain(ctx context.Context, template *Event, f []FramingPlugin, c CodecPlugin, byteStream io.Reader) (*BatchResult, error) {
+	if !s.begin() {
+		return nil, ErrSenderClosed
+	}
+	defer s.inflight.Done()
+	ctx = context.WithValue(ctx, ContextKeyPluginType, "SimpleSender")
 
-		counted := make(chan int, 1)
+	// same framing chain and decoding as an input plugin would use
+	extractor := BaseInputPlugin{Framing: f, Codec: c}
+	return s.sendExtracted(ctx, extractor.Extract, template, byteStream)
+}
 
-		// this needs to run in a goroutine to keep the channels open
-		// otherwise we'll deadlock (stuck channels)
-		go func() {
-			for evt := range events {
-				evt.Merge(template, false)
-				evt.batch = b
-				s.events <- evt
-				count++
-			}
-			counted <- count
-		}()
+// sendExtracted sends everything the extractor gets out of the byte stream.
+// If extraction fails part way, the events before that are still sent, but the batch fails.
+func (s *SimpleSender) sendExtracted(ctx context.Context, extract Extractor, template *Event, byteStream io.Reader) (*BatchResult, error) {
+	log := ContextLogger(ctx)
 
-		result, err := b.waitForResults(ctx, counted)
-		close(s.events)
-		return result, err
-	} else {
-		// get started for real
-		events := make(chan *Event)
-		go func() {
-			// TODO: use request context or sender context?
-			//err := s.extract(s.ctx, template, byteStream, events)
-			err := s.extract(ctx, template, byteStream, events)
-			if err != nil {
-				log.Error("error", "error", err)
-			}
-			close(events)
-		}()
+	events := make(chan *Event)
+	extractErr := make(chan error, 1)
+	go func() {
+		// no need to close(events) because extractors do that
+		extractErr <- extract(ctx, template, byteStream, events)
+	}()
 
+	if !s.e2e {
 		for evt := range events {
-			if evt == nil {
-				log.Error("SendRaw saw nil event")
-				continue
-			}
-			evt.Merge(template, false)
-			select {
-			case s.events <- evt:
-				// this space left intentionally blank
-				// this is an unconditional non-blocking write
-				// but... why?
-			case <-time.After(100 * time.Millisecond):
-				log.Warn("timeout")
-			}
+			s.push(evt)
 		}
-		close(s.events)
-
-		return nil, nil
+		return nil, <-extractErr
 	}
-}
 
-func (s *SimpleSender) SendWithFramingCodec(ctx context.Context, template *Event, f FramingPlugin, c CodecPlugin, byteStream io.Reader) (*BatchResult, error) {
-	var stop context.CancelCauseFunc
-	ctx, stop = context.WithCancelCause(ctx)
-	ctx = context.WithValue(ctx, ContextKeyPluginType, "SimpleSender")
-	log := ContextLogger(ctx)
+	// prepare the batch
+	b := newBatch()
+	b.slowDeadline = s.batchDeadline
+	counted := make(chan int, 1)
 
-	if s.e2e {
-		// prepare the batch
-		b := newBatch()
+	// this needs to run in a goroutine to keep the channels open
+	// otherwise we'll deadlock (stuck channels)
+	s.inflight.Add(1)
+	go func() {
+		defer s.inflight.Done()
 		count := 0
-
-		// collect chunks from the reader
-		chunks := make(chan []byte)
-		go PumpFromReader(ctx, stop, byteStream, chunks)
-
-		// the framing stage will normalize those into whole frames
-		stage := f
-		frames := make(chan []byte)
-
-		go func() {
-			err := stage.Extract(ctx, chunks, frames)
-			if err != nil {
-				log.Error("framing stage failed", "error", err)
-				stop(err)
-			}
-		}()
-
-		go func() {
-			for frameData := range frames {
-				evt, err := c.Decode(frameData)
-				if err != nil {
-					//failed <- err
-				}
-				evt.Merge(template, false)
-				evt.batch = b
-				s.events <- &evt
-				count++
-			}
-		}()
-
-		cc := make(chan int, 1)
-		cc <- count
-		return b.waitForResults(ctx, cc)
-	} else {
-		// collect chunks from the reader
-		chunks := make(chan []byte)
-		go PumpFromReader(ctx, stop, byteStream, chunks)
-
-		// the framing stage will normalize those into whole frames
-		stage := f
-		frames := make(chan []byte)
-		go func() {
-			err := stage.Extract(ctx, chunks, frames)
-			if err != nil {
-				log.Error("framing stage failed", "error", err)
-				stop(err)
-			}
-		}()
-
-		//go func() {
-		for frameData := range frames {
-			evt, err := c.Decode(frameData)
-			if err != nil {
-				//failed <- err
-			}
-			evt.Merge(template, false)
-			s.events <- &evt
+		for evt := range events {
+			evt.batch = b
+			s.push(evt)
+			count++
 		}
-		//}()
+		counted <- count
+	}()
 
-		return nil, nil
+	result, err := s.wait(ctx, b, counted)
+	if err != nil {
+		// timed out; extraction might still be stuck on a slow reader
+		return result, err
 	}
+	// the count only arrives after the extractor is finished, so this doesn't block for long
+	if err := <-extractErr; err != nil {
+		log.Error("extraction failed", "error", err)
+		s.status.recordError(err)
+		result.Ok = false
+		result.ErrorCount++
+		result.Errors = append(result.Errors, err)
+		return result, err
+	}
+	return result, nil
 }
 
 func (s *SimpleSender) SetE2E(e2e bool) {
 	s.e2e = e2e
 }
 
+func (s *SimpleSender) SetBatchDeadline(deadline time.Duration) {
+	s.batchDeadline = deadline
+}
+
+func (s *SimpleSender) begin() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.closed {
+		return false
+	}
+	s.inflight.Add(1)
+	return true
+}
+
+// Close stops accepting new sends, waits for the ones in progress, then closes the events channel.
+// Closing the channel is how the rest of the pipeline knows this input has drained.
+func (s *SimpleSender) Close() {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return
+	}
+	s.closed = true
+	s.mutex.Unlock()
+	s.inflight.Wait()
+	close(s.events)
+}
+
 type publishingBatch struct {
 	filterBurndown chan int
 	outputBurndown chan int
 	dropHappened   chan bool
 	errorHappened  chan error
-	outputFanout   int
-	slowWarning    time.Duration
-	slowDeadline   time.Duration
+	// the event failed and went to the dead letter output instead
+	deadLetterHappened chan bool
+	// the event couldn't be delivered (eg. an output gave up on it)
+	// the error itself is reported separately
+	failHappened chan bool
+	// closed when the waiter stops listening (eg. after the deadline)
+	// so that late reports don't block filters and outputs forever
+	done         chan struct{}
+	outputFanout int
+	slowWarning  time.Duration
+	slowDeadline time.Duration
 }
 
 func newBatch() *publishingBatch {
 	return &publishingBatch{
-		filterBurndown: make(chan int),
-		outputBurndown: make(chan int),
-		dropHappened:   make(chan bool),
-		errorHappened:  make(chan error),
-		// TODO: make these customizable
+		filterBurndown:     make(chan int),
+		outputBurndown:     make(chan int),
+		dropHappened:       make(chan bool),
+		errorHappened:      make(chan error),
+		deadLetterHappened: make(chan bool),
+		failHappened:       make(chan bool),
+		done:               make(chan struct{}),
+		// TODO: make the warning customizable
 		slowWarning:  3 * time.Second,
-		slowDeadline: 10 * time.Second,
+		slowDeadline: DefaultBatchDeadline,
 	}
 }
 
@@ -250,12 +294,19 @@ func newBatch() *publishingBatch {
 // that's why we need a channel to get the final count
 func (b *publishingBatch) waitForResults(ctx context.Context, counted chan int) (*BatchResult, error) {
 	log := ContextLogger(ctx)
+	defer close(b.done)
 
 	countFilterMarked := 0
 	countOutputMarked := 0
 
-	slowWarning := time.After(b.slowWarning)
-	slowDeadline := time.After(b.slowDeadline)
+	// a zero duration means wait forever
+	var slowWarning, slowDeadline <-chan time.Time
+	if b.slowWarning > 0 {
+		slowWarning = time.After(b.slowWarning)
+	}
+	if b.slowDeadline > 0 {
+		slowDeadline = time.After(b.slowDeadline)
+	}
 
 	result := &BatchResult{
 		//TotalCount:   b.batchSize,
@@ -288,6 +339,15 @@ func (b *publishingBatch) waitForResults(ctx context.Context, counted chan int)
 			countFilterMarked += 1
 			countOutputMarked += 1
 			result.DropCount++
+		case <-b.deadLetterHappened:
+			countFilterMarked += 1
+			countOutputMarked += 1
+			result.DeadLetterCount++
+		case <-b.failHappened:
+			countFilterMarked += 1
+			countOutputMarked += 1
+			result.Ok = false
+			result.FailedCount++
 		case err := <-b.errorHappened:
 			// TODO: should we set the Ok status or not?
 			//result.Ok = false
@@ -307,18 +367,39 @@ func (b *publishingBatch) waitForResults(ctx context.Context, counted chan int)
 	return result, nil
 }
 
+// every report gives up once the waiter has stopped listening
+func report[T any](b *publishingBatch, progress chan T, value T) {
+	select {
+	case progress <- value:
+	case <-b.done:
+	}
+}
+
+func (b *publishingBatch) reportFiltered()   { report(b, b.filterBurndown, 1) }
+func (b *publishingBatch) reportOutput()     { report(b, b.outputBurndown, 1) }
+func (b *publishingBatch) reportDrop()       { report(b, b.dropHappened, true) }
+func (b *publishingBatch) reportDeadLetter() { report(b, b.deadLetterHappened, true) }
+func (b *publishingBatch) reportError(err error) {
+	report(b, b.errorHappened, err)
+}
+func (b *publishingBatch) reportFailed() { report(b, b.failHappened, true) }
+
 type BatchResult struct {
 	TotalCount   int
 	DropCount    int
 	ErrorCount   int
 	SuccessCount int
-	Ok           bool
-	Errors       []error
-	Start        time.Time
-	Finish       time.Time
+	// DeadLetterCount is how many events went to the dead letter output instead of finishing
+	DeadLetterCount int
+	// FailedCount is how many events weren't delivered (eg. an output failed with no dead letter output)
+	FailedCount int
+	Ok          bool
+	Errors      []error
+	Start       time.Time
+	Finish      time.Time
 }
 
 func (r *BatchResult) Summary() string {
-	return fmt.Sprintf("Ok=%t TotalCount=%d SuccessCount=%d DropCount=%d ErrorCount=%d",
-		r.Ok, r.TotalCount, r.SuccessCount, r.DropCount, r.ErrorCount)
+	return fmt.Sprintf("Ok=%t TotalCount=%d SuccessCount=%d DropCount=%d ErrorCount=%d DeadLetterCount=%d FailedCount=%d",
+		r.Ok, r.TotalCount, r.SuccessCount, r.DropCount, r.ErrorCount, r.DeadLetterCount, r.FailedCount)
 }
@@ -0,0 +1,110 @@
+package loglang
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Timestamps are stored in events as time.Time, with full precision.
+// Each codec decides how to write them on the wire.
+
+// TimeFormat is how a codec writes (and reads) timestamps
+type TimeFormat string
+
+const (
+	TimeFormatRFC3339Nano TimeFormat = "rfc3339nano"
+	TimeFormatEpochMillis TimeFormat = "epoch_millis"
+	// TimeFormatSyslog is Mmm dd hh:mm:ss, from RFC 3164; it has no year or time zone
+	TimeFormatSyslog TimeFormat = "syslog"
+)
+
+// the layout of TimeFormatSyslog; single-digit days are padded with a space
+const syslogTimeLayout = "Jan _2 15:04:05"
+
+// ParseTimeFormat reads a time format from config; empty means RFC 3339
+func ParseTimeFormat(s string) (TimeFormat, error) {
+	switch f := TimeFormat(s); f {
+	case "":
+		return TimeFormatRFC3339Nano, nil
+	case TimeFormatRFC3339Nano, TimeFormatEpochMillis, TimeFormatSyslog:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown time format %q (known: %s, %s, %s)",
+		s, TimeFormatRFC3339Nano, TimeFormatEpochMillis, TimeFormatSyslog)
+}
+
+// Format a timestamp for the wire; epoch millis is a number, the others are strings
+func (f TimeFormat) Format(t time.Time) any {
+	switch f {
+	case TimeFormatEpochMillis:
+		return t.UnixMilli()
+	case TimeFormatSyslog:
+		return t.Format(syslogTimeLayout)
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// Parse a timestamp from the wire; values that are already a time.Time are accepted too
+func (f TimeFormat) Parse(value any) (time.Time, error) {
+	if t, isTime := value.(time.Time); isTime {
+		return t, nil
+	}
+	if f == TimeFormatEpochMillis {
+		var millis int64
+		switch v := value.(type) {
+		case int:
+			millis = int64(v)
+		case int64:
+			millis = v
+		case float64:
+			millis = int64(v)
+		case string:
+			var err error
+			if millis, err = strconv.ParseInt(v, 10, 64); err != nil {
+				return time.Time{}, fmt.Errorf("bad epoch millis %q", v)
+			}
+		default:
+			return time.Time{}, fmt.Errorf("expected epoch millis but got %T", value)
+		}
+		return time.UnixMilli(millis), nil
+	}
+	s, isString := value.(string)
+	if !isString {
+		return time.Time{}, fmt.Errorf("expected a timestamp but got %T", value)
+	}
+	if f == TimeFormatSyslog {
+		return ParseSyslogTime(s, time.Now())
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// ParseSyslogTime reads Mmm dd hh:mm:ss in now's location, guessing the year since syslog timestamps don't have one.
+// A timestamp more than a day in the future must be from last year (eg. read just after new year).
+func ParseSyslogTime(s string, now time.Time) (time.Time, error) {
+	t, err := time.ParseInLocation(syslogTimeLayout, s, now.Location())
+	if err != nil {
+		return time.Time{}, err
+	}
+	t = t.AddDate(now.Year(), 0, 0)
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t, nil
+}
+
+// toTime understands time.Time, and strings in any of the text formats
+func toTime(value any) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t, true
+		}
+		if t, err := ParseSyslogTime(v, time.Now()); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,91 @@
+package loglang
+
+import (
+	"testing"
+	"time"
+)
+
+func TestField_TimeKeepsPrecision(t *testing.T) {
+	ts := time.Date(2024, time.March, 5, 9, 7, 3, 123456789, time.UTC)
+	evt := NewEvent()
+	evt.Field("@timestamp").SetTime(ts)
+
+	if actual := evt.Field("@timestamp").GetTime(); !actual.Equal(ts) {
+		t.Errorf("expected %s but got %s", ts, actual)
+	}
+	if actual := evt.Field("@timestamp").GetString(); actual != "2024-03-05T09:07:03.123456789Z" {
+		t.Errorf("unexpected %s", actual)
+	}
+	if cp := evt.Copy(); !cp.Field("@timestamp").GetTime().Equal(ts) {
+		t.Error("expected the copy to keep the timestamp")
+	}
+
+	evt.Field("text").SetString("2024-03-05T09:07:03.5Z")
+	if actual := evt.Field("text").GetTime(); actual.Nanosecond() != 500000000 {
+		t.Errorf("expected a string timestamp to be parsed but got %s", actual)
+	}
+	if actual := evt.Field("missing").GetTime(); !actual.IsZero() {
+		t.Errorf("expected the zero time but got %s", actual)
+	}
+
+	// numeric getters read a timestamp as seconds since the epoch
+	if actual := evt.Field("@timestamp").GetFloat(); actual != 1709629623.123456789 {
+		t.Errorf("unexpected %f", actual)
+	}
+	if actual := evt.Field("@timestamp").GetInt(); actual != 1709629623 {
+		t.Errorf("unexpected %d", actual)
+	}
+	evt.Field("millis").Set(ts.UnixMilli())
+	if actual := evt.Field("millis").GetInt(); actual != 1709629623123 {
+		t.Errorf("expected int64 to be read but got %d", actual)
+	}
+}
+
+func TestTimeFormat(t *testing.T) {
+	ts := time.Date(2024, time.March, 5, 9, 7, 3, 123456789, time.UTC)
+	tests := []struct {
+		format   TimeFormat
+		expected any
+	}{
+		{TimeFormatRFC3339Nano, "2024-03-05T09:07:03.123456789Z"},
+		{TimeFormatEpochMillis, int64(1709629623123)},
+		{TimeFormatSyslog, "Mar  5 09:07:03"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			actual := tt.format.Format(ts)
+			if actual != tt.expected {
+				t.Errorf("expected %#v but got %#v", tt.expected, actual)
+			}
+			parsed, err := tt.format.Parse(actual)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Format("Jan _2 15:04:05") != "Mar  5 09:07:03" {
+				t.Errorf("unexpected %s", parsed)
+			}
+		})
+	}
+
+	if _, err := ParseTimeFormat("nope"); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+}
+
+func TestParseSyslogTime_GuessesYear(t *testing.T) {
+	now := time.Date(2025, time.January, 1, 0, 5, 0, 0, time.UTC)
+	actual, err := ParseSyslogTime("Dec 31 23:59:00", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual.Year() != 2024 {
+		t.Errorf("expected last year but got %s", actual)
+	}
+	actual, err = ParseSyslogTime("Jan  1 00:04:00", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual.Year() != 2025 {
+		t.Errorf("expected this year but got %s", actual)
+	}
+}
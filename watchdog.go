@@ -0,0 +1,210 @@
+package loglang
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The watchdog notices when an input or output stops making progress.
+// An input is stalled when it hasn't sent anything for StalledInputThreshold.
+// An output is stalled when it has events waiting but hasn't finished any for StalledOutputThreshold.
+//
+// Each stall is logged and turned into a warning event with a [stall] object describing it.
+// Warning events go to the StallOutput if there is one, or else through the pipeline like any other event.
+
+// StallOutput configures where stall warnings go, instead of through the pipeline.
+// This is useful when the stalled output is the one that would have received the warning.
+func (p *Pipeline) StallOutput(name string, op OutputPlugin, cp CodecPlugin, fp FramingPlugin) {
+	p.stallOutput = &NamedEntity[OutputConfig]{
+		Name: name,
+		Value: OutputConfig{
+			output:  op,
+			codec:   cp,
+			framing: fp,
+		},
+	}
+}
+
+type stallWatch struct {
+	kind      string
+	name      string
+	threshold time.Duration
+	// progress increases whenever the plugin does something useful
+	progress func() int64
+	// pending is how many events are waiting; nil means the plugin can be idle without being stalled
+	pending func() int64
+
+	lastProgress int64
+	lastActive   time.Time
+	stalledSince time.Time
+	stalled      atomic.Bool
+}
+
+func (p *Pipeline) inputWatches() []*stallWatch {
+	watches := make([]*stallWatch, 0, len(p.inputs))
+	for _, input := range p.inputs {
+		// the same counter the sender uses
+		ctx := context.WithValue(p.ctx, ContextKeyPluginName, input.Name)
+		sent := p.metrics.Counter("loglang_events_out_total", helpEventsOut, pluginLabels(ctx, "input"))
+		w := &stallWatch{
+			kind:      "input",
+			name:      input.Name,
+			threshold: p.opts.StalledInputThreshold,
+			progress:  sent.Value,
+		}
+		input.Value.status.attachWatch(w)
+		watches = append(watches, w)
+	}
+	return watches
+}
+
+func (p *Pipeline) outputWatches(progress []outputProgress) []*stallWatch {
+	watches := make([]*stallWatch, 0, len(p.outputs))
+	for i, output := range p.outputs {
+		counts := &progress[i]
+		w := &stallWatch{
+			kind:      "output",
+			name:      output.Name,
+			threshold: p.opts.StalledOutputThreshold,
+			progress:  counts.finished.Load,
+			pending: func() int64 {
+				return counts.received.Load() - counts.finished.Load()
+			},
+		}
+		output.Value.status.attachWatch(w)
+		watches = append(watches, w)
+	}
+	return watches
+}
+
+// watch runs until ctx is done. Warnings are sent to inject when there's no StallOutput.
+func (p *Pipeline) watch(ctx context.Context, watches []*stallWatch, inject chan<- *Event) {
+	log := ContextLogger(ctx)
+	if len(watches) == 0 {
+		return
+	}
+
+	interval := time.Second
+	now := time.Now()
+	for _, w := range watches {
+		w.lastActive = now
+		w.lastProgress = w.progress()
+		interval = min(interval, w.threshold/4)
+		w := w
+		p.metrics.GaugeFunc("loglang_stalled", "Whether a plugin is stalled (1) or not (0)",
+			Labels{"pipeline": p.Name, "kind": w.kind, "plugin": w.name}, func() float64 {
+				if w.stalled.Load() {
+					return 1
+				}
+				return 0
+			})
+	}
+	interval = max(interval, 10*time.Millisecond)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now = <-ticker.C:
+		}
+		for _, w := range watches {
+			if !w.check(now) {
+				continue
+			}
+			if w.stalled.Load() {
+				event := w.warning(now)
+				log.Warn("stalled", "kind", w.kind, "plugin", w.name,
+					"idle", now.Sub(w.lastActive).Truncate(time.Millisecond),
+					"pending", event.Field("stall", "pending").GetInt())
+				p.sendStallWarning(ctx, event, inject)
+			} else {
+				log.Info("no longer stalled", "kind", w.kind, "plugin", w.name)
+			}
+		}
+		if p.opts.StallStopAfter > 0 {
+			for _, w := range watches {
+				if w.stalled.Load() && now.Sub(w.stalledSince) >= p.opts.StallStopAfter {
+					p.stopInputs(fmt.Errorf("%s %s stalled for %v", w.kind, w.name, now.Sub(w.lastActive).Truncate(time.Millisecond)))
+					return
+				}
+			}
+		}
+	}
+}
+
+// check returns true when the watch becomes stalled, or stops being stalled
+func (w *stallWatch) check(now time.Time) bool {
+	progress := w.progress()
+	// an output with nothing to do isn't stalled, just idle
+	idle := w.pending != nil && w.pending() == 0
+	if progress != w.lastProgress || idle {
+		w.lastProgress = progress
+		w.lastActive = now
+		return w.stalled.CompareAndSwap(true, false)
+	}
+	if w.stalled.Load() || now.Sub(w.lastActive) < w.threshold {
+		return false
+	}
+	w.stalled.Store(true)
+	w.stalledSince = now
+	return true
+}
+
+func (w *stallWatch) warning(now time.Time) *Event {
+	var pending int64
+	if w.pending != nil {
+		pending = w.pending()
+	}
+	idle := now.Sub(w.lastActive).Truncate(time.Millisecond)
+	evt := NewEvent()
+	if w.pending != nil {
+		evt.Field("message").SetString(fmt.Sprintf("%s %s stalled: no progress for %v with %d events pending", w.kind, w.name, idle, pending))
+	} else {
+		evt.Field("message").SetString(fmt.Sprintf("%s %s stalled: no events for %v", w.kind, w.name, idle))
+	}
+	evt.Field("stall", "kind").SetString(w.kind)
+	evt.Field("stall", "plugin").SetString(w.name)
+	evt.Field("stall", "idle").SetString(idle.String())
+	evt.Field("stall", "pending").SetInt(int(pending))
+	evt.Field("stall", "last_active").SetString(w.lastActive.Format(time.RFC3339Nano))
+	return &evt
+}
+
+func (p *Pipeline) sendStallWarning(ctx context.Context, event *Event, inject chan<- *Event) {
+	if p.stallOutput == nil {
+		select {
+		case inject <- event:
+		case <-ctx.Done():
+		}
+		return
+	}
+	// like dead letters, warnings are sent synchronously, one at a time
+	p.stallOutputMutex.Lock()
+	defer p.stallOutputMutex.Unlock()
+	outputCfg := p.stallOutput.Value
+	outputCtx := context.WithValue(p.ctx, ContextKeyPluginName, p.stallOutput.Name)
+	err := outputCfg.output.Send(outputCtx, []*Event{event}, outputCfg.codec, outputCfg.framing)
+	if err != nil {
+		ContextLogger(outputCtx).Error("lost a stall warning", "error", err)
+	}
+}
+
+// startWatchdog runs the watchdog until the returned function is called
+func (p *Pipeline) startWatchdog(watches []*stallWatch, inject chan<- *Event) (stopWatchdog func()) {
+	ctx, cancel := context.WithCancel(p.ctx)
+	var stopped sync.WaitGroup
+	stopped.Add(1)
+	go func() {
+		defer stopped.Done()
+		p.watch(ctx, watches, inject)
+	}()
+	return func() {
+		cancel()
+		stopped.Wait()
+	}
+}